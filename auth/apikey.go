@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"REST_API/problem"
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scopes limit what API keys and service accounts may change. Reading
+// events needs no credentials, so there is no scope for it.
+const (
+	ScopeEventsWrite        = "events:write"
+	ScopeRegistrationsWrite = "registrations:write"
+)
+
+// APIKeyValidator resolves a presented API key to its ID, owner and scopes.
+// It lives outside this package because keys are stored by models, which
+// already depends on auth.
+type APIKeyValidator func(ctx context.Context, key string) (keyId, userId int64, scopes []string, err error)
+
+var (
+	apiKeyMu        sync.RWMutex
+	apiKeyValidator APIKeyValidator
+)
+
+func SetAPIKeyValidator(v APIKeyValidator) {
+	apiKeyMu.Lock()
+	defer apiKeyMu.Unlock()
+	apiKeyValidator = v
+}
+
+func validateAPIKey(ctx context.Context, key string) (int64, int64, []string, bool) {
+	apiKeyMu.RLock()
+	v := apiKeyValidator
+	apiKeyMu.RUnlock()
+
+	if v == nil {
+		return 0, 0, nil, false
+	}
+
+	keyId, userId, scopes, err := v(ctx, key)
+	if err != nil {
+		return 0, 0, nil, false
+	}
+	return keyId, userId, scopes, true
+}
+
+// RequireScope rejects API-key requests whose key lacks scope. Requests
+// authenticated with a user token are not restricted.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			unauthorized(c, "Bearer", "", "")
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			c.Header("WWW-Authenticate", `ApiKey realm="`+realm+`", error="insufficient_scope", scope="`+scope+`"`)
+			problem.Abort(c, http.StatusForbidden, "API key lacks scope "+scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUserSession rejects API-key and service account requests. It
+// guards account management, which scripts should never be able to reach.
+func RequireUserSession(c *gin.Context) {
+	principal, ok := CurrentPrincipal(c)
+	if !ok {
+		unauthorized(c, "Bearer", "", "")
+		return
+	}
+
+	if principal.Method != MethodToken {
+		problem.Abort(c, http.StatusForbidden, "Only signed-in users can access this resource")
+		return
+	}
+	c.Next()
+}
@@ -1,25 +1,157 @@
 package auth
 
 import (
+	"REST_API/problem"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const realm = "rest-api"
+
+// Authenticate rejects requests without valid credentials.
 func Authenticate(c *gin.Context) {
-	token := c.Request.Header.Get("Authorization")
+	authenticate(c, true)
+}
 
-	if token == "" {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
+// AuthenticateOptional lets requests without credentials through without
+// a principal, for endpoints that serve anonymous callers and decide per
+// operation whether to require one. Invalid credentials are still
+// rejected.
+func AuthenticateOptional(c *gin.Context) {
+	authenticate(c, false)
+}
+
+func authenticate(c *gin.Context, required bool) {
+	scheme, credentials := credentialsFromRequest(c.Request)
 
-	userId, err := ValidateToken(token)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
+	switch scheme {
+	case "apikey":
+		keyId, userId, scopes, ok := validateAPIKey(c.Request.Context(), credentials)
+		if !ok {
+			unauthorized(c, "ApiKey", "invalid_token", "invalid API key")
+			return
+		}
+
+		setPrincipal(c, &Principal{
+			UserID: userId,
+			Method: MethodAPIKey,
+			KeyID:  keyId,
+			Scopes: scopes,
+		})
+	case "bearer":
+		claims, err := ParseToken(credentials)
+		if err != nil {
+			unauthorized(c, "Bearer", "invalid_token", tokenErrorDescription(err))
+			return
+		}
+
+		userId, _ := claims.UserID()
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		setPrincipal(c, &Principal{
+			UserID:   userId,
+			Email:    claims.Email,
+			Method:   MethodToken,
+			TokenID:  claims.ID,
+			IssuedAt: issuedAt,
+		})
+	default:
+		if !authenticateClientCert(c) && required {
+			unauthorized(c, "Bearer", "", "")
+			return
+		}
 	}
 
-	c.Set("userId", userId)
 	c.Next()
 }
+
+// authenticateClientCert accepts a client certificate verified during the
+// TLS handshake when no other credentials were sent.
+func authenticateClientCert(c *gin.Context) bool {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return false
+	}
+
+	cert := state.VerifiedChains[0][0]
+	userId, scopes, ok := validateClientCert(c.Request.Context(), cert)
+	if !ok {
+		return false
+	}
+
+	setPrincipal(c, &Principal{
+		UserID:         userId,
+		Method:         MethodClientCert,
+		ServiceAccount: cert.Subject.CommonName,
+		Scopes:         scopes,
+	})
+	return true
+}
+
+// credentialsFromRequest accepts "Authorization: Bearer <jwt>",
+// "Authorization: ApiKey <key>", "X-API-Key: <key>" and, for older
+// clients, a bare JWT in the Authorization header. The scheme is returned
+// in lower case, or "" if there are no usable credentials.
+func credentialsFromRequest(r *http.Request) (string, string) {
+	key := r.Header.Get("X-API-Key")
+	if key != "" {
+		return "apikey", key
+	}
+
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if header == "" {
+		return "", ""
+	}
+
+	scheme, credentials, found := strings.Cut(header, " ")
+	if !found {
+		return "bearer", header
+	}
+
+	credentials = strings.TrimSpace(credentials)
+	if credentials == "" {
+		return "", ""
+	}
+
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return "bearer", credentials
+	case "apikey":
+		return "apikey", credentials
+	default:
+		return "", ""
+	}
+}
+
+func tokenErrorDescription(err error) string {
+	switch {
+	case errors.Is(err, ErrExpiredToken):
+		return "the token has expired"
+	case errors.Is(err, ErrInvalidClaims):
+		return "the token claims are invalid"
+	default:
+		return "the token is malformed or has an invalid signature"
+	}
+}
+
+// unauthorized aborts with 401 and an RFC 6750 challenge. errorCode and
+// description are omitted when no credentials were sent at all.
+func unauthorized(c *gin.Context, scheme, errorCode, description string) {
+	challenge := scheme + ` realm="` + realm + `"`
+	if errorCode != "" {
+		challenge += `, error="` + errorCode + `", error_description="` + description + `"`
+	}
+
+	if description == "" {
+		description = "Authentication required"
+	}
+
+	c.Header("WWW-Authenticate", challenge)
+	problem.Abort(c, http.StatusUnauthorized, description)
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// signTestToken signs arbitrary claims with the real secret
+func signTestToken(t *testing.T, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secretKey)
+	if err != nil {
+		t.Fatalf("Failed to sign test token: %v", err)
+	}
+	return token
+}
+
+// validClaims returns claims that pass validation, for tests to break
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":   "42",
+		"email": "test@example.com",
+		"iss":   issuer,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"nbf":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"jti":   "test-jti",
+	}
+}
+
+func TestGenerateToken_RoundTrip(t *testing.T) {
+	token, err := GenerateToken("test@example.com", 42)
+	assert.NoError(t, err)
+
+	claims, err := ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "42", claims.Subject)
+	assert.Equal(t, "test@example.com", claims.Email)
+	assert.Equal(t, issuer, claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{audience}, claims.Audience)
+	assert.NotEmpty(t, claims.ID)
+	assert.NotNil(t, claims.IssuedAt)
+	assert.NotNil(t, claims.NotBefore)
+
+	userId, err := ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), userId)
+
+	other, err := GenerateToken("test@example.com", 42)
+	assert.NoError(t, err)
+	otherClaims, err := ParseToken(other)
+	assert.NoError(t, err)
+	assert.NotEqual(t, claims.ID, otherClaims.ID, "every token gets a unique jti")
+}
+
+func TestParseToken_Rejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(jwt.MapClaims)
+		wantErr error
+	}{
+		{"Missing subject", func(c jwt.MapClaims) { delete(c, "sub") }, ErrInvalidClaims},
+		{"Non-numeric subject", func(c jwt.MapClaims) { c["sub"] = "abc" }, ErrInvalidClaims},
+		{"Legacy id claim only", func(c jwt.MapClaims) { delete(c, "sub"); c["id"] = 42 }, ErrInvalidClaims},
+		{"Wrong issuer", func(c jwt.MapClaims) { c["iss"] = "someone-else" }, ErrInvalidClaims},
+		{"Wrong audience", func(c jwt.MapClaims) { c["aud"] = "another-api" }, ErrInvalidClaims},
+		{"Missing expiry", func(c jwt.MapClaims) { delete(c, "exp") }, ErrInvalidClaims},
+		{"Not yet valid", func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }, ErrInvalidClaims},
+		{"Expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, ErrExpiredToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.mutate(claims)
+
+			assert.NotPanics(t, func() {
+				_, err := ValidateToken(signTestToken(t, claims))
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		})
+	}
+
+	t.Run("Wrong signature", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("otherKey"))
+		assert.NoError(t, err)
+
+		_, err = ParseToken(token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("Unsigned token", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		assert.NoError(t, err)
+
+		_, err = ParseToken(token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("Garbage", func(t *testing.T) {
+		_, err := ParseToken("not a token")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/protected", Authenticate, func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		assert.True(t, ok)
+		c.JSON(http.StatusOK, gin.H{
+			"user_id": principal.UserID,
+			"email":   principal.Email,
+			"method":  principal.Method,
+			"legacy":  c.GetInt64("userId"),
+		})
+	})
+
+	request := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	token, err := GenerateToken("test@example.com", 42)
+	assert.NoError(t, err)
+
+	t.Run("Bearer scheme", func(t *testing.T) {
+		w := request("Bearer " + token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"user_id":42,"email":"test@example.com","method":"token","legacy":42}`, w.Body.String())
+	})
+
+	t.Run("Scheme is case insensitive", func(t *testing.T) {
+		w := request("bearer " + token)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Bare token is still accepted", func(t *testing.T) {
+		w := request(token)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Missing credentials", func(t *testing.T) {
+		w := request("")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="rest-api"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Unknown scheme", func(t *testing.T) {
+		w := request("Basic dXNlcjpwYXNz")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="rest-api"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		claims := validClaims()
+		claims["exp"] = time.Now().Add(-time.Minute).Unix()
+
+		w := request("Bearer " + signTestToken(t, claims))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "expired")
+	})
+
+	t.Run("Non-numeric subject does not panic", func(t *testing.T) {
+		claims := validClaims()
+		claims["sub"] = "abc"
+
+		w := request("Bearer " + signTestToken(t, claims))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+	})
+}
+
+func TestAuthenticateOptional(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/optional", AuthenticateOptional, func(c *gin.Context) {
+		_, ok := CurrentPrincipal(c)
+		c.JSON(http.StatusOK, gin.H{"authenticated": ok})
+	})
+
+	request := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/optional", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	token, err := GenerateToken("test@example.com", 42)
+	assert.NoError(t, err)
+
+	t.Run("Anonymous", func(t *testing.T) {
+		w := request("")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"authenticated":false}`, w.Body.String())
+	})
+
+	t.Run("Valid token", func(t *testing.T) {
+		w := request("Bearer " + token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"authenticated":true}`, w.Body.String())
+	})
+
+	t.Run("Invalid token is rejected", func(t *testing.T) {
+		w := request("Bearer not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+	})
+}
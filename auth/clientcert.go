@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"sync"
+)
+
+// ClientCertValidator maps a verified TLS client certificate to the
+// service account it belongs to. Like APIKeyValidator it is provided by
+// models, which knows the users the accounts act as.
+type ClientCertValidator func(ctx context.Context, cert *x509.Certificate) (userId int64, scopes []string, err error)
+
+var (
+	clientCertMu        sync.RWMutex
+	clientCertValidator ClientCertValidator
+)
+
+// SetClientCertValidator enables client certificate authentication. With
+// no validator set, certificates are ignored.
+func SetClientCertValidator(v ClientCertValidator) {
+	clientCertMu.Lock()
+	defer clientCertMu.Unlock()
+	clientCertValidator = v
+}
+
+func validateClientCert(ctx context.Context, cert *x509.Certificate) (int64, []string, bool) {
+	clientCertMu.RLock()
+	v := clientCertValidator
+	clientCertMu.RUnlock()
+
+	if v == nil {
+		return 0, nil, false
+	}
+
+	userId, scopes, err := v(ctx, cert)
+	if err != nil {
+		return 0, nil, false
+	}
+	return userId, scopes, true
+}
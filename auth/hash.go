@@ -1,13 +1,199 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher hashes and verifies passwords in one self-describing format.
+// Handles reports whether a stored hash was produced by this scheme, and
+// NeedsRehash whether it was produced with weaker parameters than the
+// hasher is currently configured with.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hashedPassword string) bool
+	Handles(hashedPassword string) bool
+	NeedsRehash(hashedPassword string) bool
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.cost())
 	return string(hashedPassword), err
 }
 
-func CheckPasswordHash(password, hashedPassword string) bool {
+func (h BcryptHasher) Verify(password, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
+
+func (h BcryptHasher) Handles(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$2a$") ||
+		strings.HasPrefix(hashedPassword, "$2b$") ||
+		strings.HasPrefix(hashedPassword, "$2y$")
+}
+
+func (h BcryptHasher) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	if err != nil {
+		return true
+	}
+	return cost < h.cost()
+}
+
+func (h BcryptHasher) cost() int {
+	if h.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return h.Cost
+}
+
+// Argon2idHasher stores hashes in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2idHasher uses the OWASP recommended minimum parameters.
+func DefaultArgon2idHasher() Argon2idHasher {
+	return Argon2idHasher{
+		Time:    2,
+		Memory:  19 * 1024,
+		Threads: 1,
+		SaltLen: 16,
+		KeyLen:  32,
+	}
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(password, hashedPassword string) bool {
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return false
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, otherKey) == 1
+}
+
+func (h Argon2idHasher) Handles(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$argon2id$")
+}
+
+func (h Argon2idHasher) NeedsRehash(hashedPassword string) bool {
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return true
+	}
+
+	return params.Time < h.Time ||
+		params.Memory < h.Memory ||
+		params.Threads < h.Threads ||
+		uint32(len(salt)) < h.SaltLen ||
+		uint32(len(key)) < h.KeyLen
+}
+
+func decodeArgon2id(hashedPassword string) (Argon2idHasher, []byte, []byte, error) {
+	var params Argon2idHasher
+
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2id version")
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil || params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errors.New("invalid argon2id salt")
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, errors.New("invalid argon2id key")
+	}
+
+	return params, salt, key, nil
+}
+
+var (
+	hasherMu      sync.RWMutex
+	currentHasher Hasher = DefaultArgon2idHasher()
+)
+
+// knownHashers can verify every format we have ever stored, regardless of
+// which one is currently used for new hashes.
+var knownHashers = []Hasher{Argon2idHasher{}, BcryptHasher{}}
+
+// SetPasswordHasher changes the hasher used for new hashes. Existing hashes
+// in other formats keep verifying and are reported by NeedsRehash.
+func SetPasswordHasher(h Hasher) {
+	hasherMu.Lock()
+	defer hasherMu.Unlock()
+	currentHasher = h
+}
+
+func passwordHasher() Hasher {
+	hasherMu.RLock()
+	defer hasherMu.RUnlock()
+	return currentHasher
+}
+
+func HashPassword(password string) (string, error) {
+	return passwordHasher().Hash(password)
+}
+
+func CheckPasswordHash(password, hashedPassword string) bool {
+	for _, h := range knownHashers {
+		if h.Handles(hashedPassword) {
+			return h.Verify(password, hashedPassword)
+		}
+	}
+	return false
+}
+
+// NeedsRehash reports whether hashedPassword should be replaced with a hash
+// from the current hasher, either because it uses another scheme or because
+// its parameters are weaker than the configured ones.
+func NeedsRehash(hashedPassword string) bool {
+	h := passwordHasher()
+	return !h.Handles(hashedPassword) || h.NeedsRehash(hashedPassword)
+}
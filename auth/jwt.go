@@ -1,47 +1,114 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-const secretKey = "superSecretKey"
+const (
+	issuer   = "rest-api"
+	audience = "rest-api"
+)
+
+var (
+	secretKey = []byte("superSecretKey")
+	tokenTTL  = time.Hour * 12
+)
+
+// Configure sets the signing secret and token lifetime. It must be called
+// before the server starts handling requests.
+func Configure(secret string, ttl time.Duration) {
+	secretKey = []byte(secret)
+	tokenTTL = ttl
+}
+
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrExpiredToken  = errors.New("token expired")
+	ErrInvalidClaims = errors.New("invalid token claims")
+)
+
+// Claims are the claims of the tokens we issue. The user ID is carried in
+// the standard subject claim as a decimal string.
+type Claims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// UserID parses the subject claim.
+func (c *Claims) UserID() (int64, error) {
+	userId, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil || userId <= 0 {
+		return 0, ErrInvalidClaims
+	}
+	return userId, nil
+}
 
 func GenerateToken(email string, userId int64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"email": email,
-		"id":    userId,
-		"exp":   time.Now().Add(time.Hour * 12).Unix(),
+	jti := make([]byte, 16)
+	_, err := rand.Read(jti)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(userId, 10),
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+			ID:        base64.RawURLEncoding.EncodeToString(jti),
+		},
 	})
 
-	return token.SignedString([]byte(secretKey))
+	return token.SignedString(secretKey)
 }
 
-func ValidateToken(token string) (int64, error) {
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
+// ParseToken verifies the signature, issuer, audience and time claims and
+// returns the typed claims. Errors are ErrExpiredToken, ErrInvalidClaims
+// or ErrInvalidToken so callers can report them without leaking details.
+func ParseToken(token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (any, error) {
+		return secretKey, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, ErrExpiredToken
+	case errors.Is(err, jwt.ErrTokenInvalidClaims):
+		return nil, ErrInvalidClaims
+	case err != nil:
+		return nil, ErrInvalidToken
+	}
 
-		if !ok {
-			return nil, errors.New("invalid token")
-		}
-		return []byte(secretKey), nil
-	})
+	_, err = claims.UserID()
 	if err != nil {
-		return 0, errors.New("invalid token")
+		return nil, err
 	}
 
-	tokenIsValid := parsedToken.Valid
-	if !tokenIsValid {
-		return 0, errors.New("invalid token")
-	}
+	return &claims, nil
+}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, errors.New("invalid token")
+func ValidateToken(token string) (int64, error) {
+	claims, err := ParseToken(token)
+	if err != nil {
+		return 0, err
 	}
 
-	userId := int64(claims["id"].(float64))
-	return userId, nil
+	return claims.UserID()
 }
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Method string
+
+const (
+	MethodToken      Method = "token"
+	MethodAPIKey     Method = "api_key"
+	MethodClientCert Method = "client_cert"
+)
+
+const principalKey = "principal"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID int64
+	Email  string
+	Method Method
+	// TokenID is the jti of the token used, empty for API keys.
+	TokenID string
+	// IssuedAt is when the token used was issued, which is when the user
+	// logged in; zero for API keys and client certificates.
+	IssuedAt time.Time
+	// KeyID is the ID of the API key used, zero for tokens.
+	KeyID int64
+	// ServiceAccount is the common name of the client certificate used,
+	// empty for tokens and API keys.
+	ServiceAccount string
+	// Scopes restrict API keys and service accounts; tokens are not
+	// restricted.
+	Scopes []string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return p.Method == MethodToken || slices.Contains(p.Scopes, scope)
+}
+
+// CurrentPrincipal returns the principal stored by Authenticate.
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+func setPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+	// Handlers read the user ID directly; keep it available under the
+	// key they have always used.
+	c.Set("userId", principal.UserID)
+}
@@ -0,0 +1,227 @@
+// Package cache provides an in-process LRU cache whose entries expire.
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Stats are counters since the cache was created.
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	// Loads counts calls of a loader; concurrent misses of one key share
+	// a load.
+	Loads     uint64
+	Evictions uint64
+	Entries   int
+}
+
+// LRU holds up to capacity entries for at most ttl each, evicting the
+// least recently used entry when full.
+//
+// Writers call Invalidate after changing the underlying data. Loads of the
+// key that started before an invalidation are neither stored nor shared
+// with later callers, so a read racing a write cannot put the old value
+// back; loads of other keys are not affected.
+type LRU[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+	flights  singleflight.Group
+
+	mu    sync.Mutex
+	items map[K]*list.Element
+	order *list.List // front is most recently used
+	// loads tracks the keys being loaded, so that Invalidate only
+	// discards the loads of its key. cleared changes with every Clear.
+	loads   map[K]*loadState
+	cleared uint64
+	stats   Stats
+}
+
+// loadState is kept while at least one caller loads its key.
+type loadState struct {
+	// generation changes with every invalidation of the key.
+	generation uint64
+	callers    int
+}
+
+// generation identifies the data a load reads, to tell whether it is
+// still current when the load finishes.
+type generation struct {
+	cleared, key uint64
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// New returns a cache. A capacity of zero or less stores nothing, but
+// concurrent loads of a key are still shared.
+func New[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+		loads:    make(map[K]*loadState),
+	}
+}
+
+// Get returns the cached value for key.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.lookup(key)
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	return value, ok
+}
+
+// GetOrLoad returns the cached value for key, or calls load and caches
+// its result. Errors are returned to every waiting caller but not cached.
+func (c *LRU[K, V]) GetOrLoad(key K, load func() (V, error)) (V, error) {
+	c.mu.Lock()
+	value, ok := c.lookup(key)
+	if ok {
+		c.stats.Hits++
+		c.mu.Unlock()
+		return value, nil
+	}
+	c.stats.Misses++
+	state, ok := c.loads[key]
+	if !ok {
+		state = &loadState{}
+		c.loads[key] = state
+	}
+	state.callers++
+	current := generation{c.cleared, state.generation}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		state.callers--
+		if state.callers == 0 {
+			delete(c.loads, key)
+		}
+		c.mu.Unlock()
+	}()
+
+	flight := fmt.Sprint(current.cleared, "/", current.key, "/", key)
+	result, err, _ := c.flights.Do(flight, func() (any, error) {
+		// A flight for key may have finished just after the miss above.
+		c.mu.Lock()
+		if value, ok := c.lookup(key); ok {
+			c.mu.Unlock()
+			return value, nil
+		}
+		c.stats.Loads++
+		c.mu.Unlock()
+
+		value, err := load()
+		if err != nil {
+			return value, err
+		}
+		c.add(key, value, current)
+		return value, nil
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return result.(V), nil
+}
+
+// Invalidate removes key. Call it after the underlying data has changed.
+func (c *LRU[K, V]) Invalidate(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+	if state, ok := c.loads[key]; ok {
+		state.generation++
+	}
+}
+
+// Clear removes every entry, for changes that affect many keys.
+func (c *LRU[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.items)
+	c.order.Init()
+	c.cleared++
+}
+
+func (c *LRU[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+// lookup must be called with mu held.
+func (c *LRU[K, V]) lookup(key K) (V, bool) {
+	element, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	e := element.Value.(*entry[K, V])
+	if !c.now().Before(e.expiresAt) {
+		c.remove(element)
+		var zero V
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	return e.value, true
+}
+
+// add stores value unless key was invalidated or the cache cleared since
+// loaded. It is called while the loading caller is counted in c.loads.
+func (c *LRU[K, V]) add(key K, value V, loaded generation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.loads[key]
+	if loaded != (generation{c.cleared, state.generation}) || c.capacity <= 0 {
+		return
+	}
+
+	expiresAt := c.now().Add(c.ttl)
+	if element, ok := c.items[key]; ok {
+		element.Value = &entry[K, V]{key, value, expiresAt}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key, value, expiresAt})
+	if c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove must be called with mu held.
+func (c *LRU[K, V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCache returns a cache whose clock only moves when advance is called
+func newTestCache(capacity int) (*LRU[int, string], func(time.Duration)) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New[int, string](capacity, time.Minute)
+	c.now = func() time.Time { return now }
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+// constant returns a loader that always yields value
+func constant(value string) func() (string, error) {
+	return func() (string, error) { return value, nil }
+}
+
+func TestLRU(t *testing.T) {
+	t.Run("Loads once and then hits", func(t *testing.T) {
+		c, _ := newTestCache(2)
+
+		for range 3 {
+			value, err := c.GetOrLoad(1, constant("one"))
+			assert.NoError(t, err)
+			assert.Equal(t, "one", value)
+		}
+		assert.Equal(t, Stats{Hits: 2, Misses: 1, Loads: 1, Entries: 1}, c.Stats())
+	})
+
+	t.Run("Evicts the least recently used entry", func(t *testing.T) {
+		c, _ := newTestCache(2)
+		_, _ = c.GetOrLoad(1, constant("one"))
+		_, _ = c.GetOrLoad(2, constant("two"))
+		_, _ = c.Get(1)
+		_, _ = c.GetOrLoad(3, constant("three"))
+
+		_, ok := c.Get(2)
+		assert.False(t, ok)
+		_, ok = c.Get(1)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(1), c.Stats().Evictions)
+		assert.Equal(t, 2, c.Stats().Entries)
+	})
+
+	t.Run("Entries expire", func(t *testing.T) {
+		c, advance := newTestCache(2)
+		_, _ = c.GetOrLoad(1, constant("one"))
+
+		advance(59 * time.Second)
+		_, ok := c.Get(1)
+		assert.True(t, ok)
+
+		advance(time.Second)
+		_, ok = c.Get(1)
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Stats().Entries)
+	})
+
+	t.Run("Errors are not cached", func(t *testing.T) {
+		c, _ := newTestCache(2)
+		failure := errors.New("not found")
+
+		_, err := c.GetOrLoad(1, func() (string, error) { return "", failure })
+		assert.ErrorIs(t, err, failure)
+
+		value, err := c.GetOrLoad(1, constant("one"))
+		assert.NoError(t, err)
+		assert.Equal(t, "one", value)
+	})
+
+	t.Run("Invalidate and Clear", func(t *testing.T) {
+		c, _ := newTestCache(2)
+		_, _ = c.GetOrLoad(1, constant("one"))
+		_, _ = c.GetOrLoad(2, constant("two"))
+
+		c.Invalidate(1)
+		value, _ := c.GetOrLoad(1, constant("new one"))
+		assert.Equal(t, "new one", value)
+
+		c.Clear()
+		assert.Equal(t, 0, c.Stats().Entries)
+	})
+
+	t.Run("Zero capacity stores nothing", func(t *testing.T) {
+		c, _ := newTestCache(0)
+		_, _ = c.GetOrLoad(1, constant("one"))
+		_, _ = c.GetOrLoad(1, constant("one"))
+		assert.Equal(t, uint64(2), c.Stats().Loads)
+	})
+}
+
+func TestLRU_Concurrency(t *testing.T) {
+	t.Run("Concurrent misses share one load", func(t *testing.T) {
+		c, _ := newTestCache(10)
+		release := make(chan struct{})
+		var loads atomic.Int32
+
+		var wg sync.WaitGroup
+		values := make([]string, 50)
+		for i := range values {
+			wg.Go(func() {
+				values[i], _ = c.GetOrLoad(1, func() (string, error) {
+					loads.Add(1)
+					<-release
+					return "one", nil
+				})
+			})
+		}
+
+		// Let every goroutine reach the cache before the load finishes
+		assert.Eventually(t, func() bool { return c.Stats().Misses == 50 }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), loads.Load())
+		for _, value := range values {
+			assert.Equal(t, "one", value)
+		}
+	})
+
+	t.Run("A load racing an invalidation is not stored", func(t *testing.T) {
+		c, _ := newTestCache(10)
+		loading := make(chan struct{})
+		release := make(chan struct{})
+
+		done := make(chan string)
+		go func() {
+			value, _ := c.GetOrLoad(1, func() (string, error) {
+				close(loading)
+				<-release
+				return "old", nil
+			})
+			done <- value
+		}()
+
+		<-loading
+		// The row changes while the old one is being read
+		c.Invalidate(1)
+
+		value, err := c.GetOrLoad(1, constant("new"))
+		assert.NoError(t, err)
+		assert.Equal(t, "new", value, "a caller after the invalidation does not join the old load")
+
+		close(release)
+		assert.Equal(t, "old", <-done)
+
+		value, ok := c.Get(1)
+		assert.True(t, ok)
+		assert.Equal(t, "new", value, "the old load did not overwrite the new value")
+	})
+
+	t.Run("Invalidating another key keeps a load", func(t *testing.T) {
+		c, _ := newTestCache(10)
+		loading := make(chan struct{})
+		release := make(chan struct{})
+
+		done := make(chan struct{})
+		go func() {
+			_, _ = c.GetOrLoad(1, func() (string, error) {
+				close(loading)
+				<-release
+				return "one", nil
+			})
+			close(done)
+		}()
+
+		<-loading
+		c.Invalidate(2)
+		close(release)
+		<-done
+
+		value, ok := c.Get(1)
+		assert.True(t, ok, "the load of key 1 is stored")
+		assert.Equal(t, "one", value)
+	})
+
+	t.Run("Readers and writers", func(t *testing.T) {
+		c := New[int, int](4, time.Minute)
+		var mu sync.Mutex
+		source := map[int]int{}
+		read := func(key int) func() (int, error) {
+			return func() (int, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				return source[key], nil
+			}
+		}
+
+		var wg sync.WaitGroup
+		for writer := range 4 {
+			wg.Go(func() {
+				for i := range 200 {
+					key := (writer + i) % 8
+					mu.Lock()
+					source[key]++
+					mu.Unlock()
+					c.Invalidate(key)
+				}
+			})
+		}
+		for reader := range 8 {
+			wg.Go(func() {
+				for i := range 500 {
+					_, _ = c.GetOrLoad((reader+i)%8, read((reader+i)%8))
+				}
+			})
+		}
+		wg.Wait()
+
+		for key := range 8 {
+			value, err := c.GetOrLoad(key, read(key))
+			assert.NoError(t, err)
+			assert.Equal(t, source[key], value, "key %d", key)
+		}
+	})
+}
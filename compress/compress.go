@@ -0,0 +1,220 @@
+// Package compress negotiates gzip or brotli compression of responses.
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+type Config struct {
+	Enabled bool
+	// MinSize is the smallest body worth compressing, in bytes. Smaller
+	// responses are sent as they are.
+	MinSize int
+}
+
+// brotliLevel trades some ratio for speed; the default level 6 is meant
+// for static assets compressed once.
+const brotliLevel = 4
+
+// compressibleTypes are Content-Type prefixes worth compressing.
+var compressibleTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"image/svg+xml",
+	"text/",
+}
+
+var (
+	gzipWriters   = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	brotliWriters = sync.Pool{New: func() any { return brotli.NewWriterLevel(io.Discard, brotliLevel) }}
+)
+
+// Middleware compresses responses with the encoding the client prefers,
+// brotli on a tie. Responses that are small, already encoded or not text
+// are left alone.
+func Middleware(config Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Enabled || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &writer{
+			ResponseWriter: c.Writer,
+			encoding:       negotiate(c.GetHeader("Accept-Encoding")),
+			minSize:        config.MinSize,
+		}
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
+
+// writer holds back the first minSize bytes to decide whether compressing
+// is worth it.
+type writer struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+
+	decided bool
+	buf     []byte
+	encoder interface {
+		io.WriteCloser
+		Flush() error
+	}
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.decided {
+		if !w.compressible() {
+			w.decided = true
+			return w.ResponseWriter.Write(b)
+		}
+
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+		err := w.start()
+		return len(b), err
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends what is buffered so far, for streamed responses.
+func (w *writer) Flush() {
+	if !w.decided && len(w.buf) > 0 {
+		_ = w.start()
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap lets http.ResponseController reach the connection, for example to
+// lift the write deadline of a stream.
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the response may be compressed, and adds
+// Vary for caches if it depends on Accept-Encoding.
+func (w *writer) compressible() bool {
+	header := w.Header()
+	if w.ResponseWriter.Written() || header.Get("Content-Encoding") != "" {
+		return false
+	}
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			header.Add("Vary", "Accept-Encoding")
+			return w.encoding != ""
+		}
+	}
+	return false
+}
+
+// start writes the buffered bytes, compressed.
+func (w *writer) start() error {
+	w.decided = true
+
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+
+	switch w.encoding {
+	case "br":
+		encoder := brotliWriters.Get().(*brotli.Writer)
+		encoder.Reset(w.ResponseWriter)
+		w.encoder = encoder
+	default:
+		encoder := gzipWriters.Get().(*gzip.Writer)
+		encoder.Reset(w.ResponseWriter)
+		w.encoder = encoder
+	}
+
+	_, err := w.encoder.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// close sends a response that stayed below minSize as it is, or finishes
+// the compressed stream.
+func (w *writer) close() {
+	if !w.decided {
+		if len(w.buf) > 0 {
+			_, _ = w.ResponseWriter.Write(w.buf)
+		}
+		return
+	}
+	if w.encoder == nil {
+		return
+	}
+
+	_ = w.encoder.Close()
+	switch encoder := w.encoder.(type) {
+	case *brotli.Writer:
+		brotliWriters.Put(encoder)
+	case *gzip.Writer:
+		gzipWriters.Put(encoder)
+	}
+	w.encoder = nil
+}
+
+// negotiate picks br or gzip from an Accept-Encoding header, or "" for no
+// compression.
+func negotiate(acceptEncoding string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		weights[strings.ToLower(strings.TrimSpace(coding))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"br", "gzip"} {
+		q, ok := weights[coding]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,140 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupRouter serves a large and a small JSON body, an image and a body
+// that is already gzip encoded
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(Config{Enabled: true, MinSize: 1024}))
+
+	large := strings.Repeat(`{"name":"Team Meeting"},`, 100)
+	router.GET("/large", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(large))
+	})
+	router.GET("/small", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	router.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", []byte(large))
+	})
+	router.GET("/encoded", func(c *gin.Context) {
+		c.Header("Content-Encoding", "gzip")
+		c.Data(http.StatusOK, "application/json", []byte(large))
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		_, _ = c.Writer.WriteString("data: first\n\n")
+		c.Writer.Flush()
+		_, _ = c.Writer.WriteString("data: second\n\n")
+	})
+	return router
+}
+
+// get requests url with the given Accept-Encoding
+func get(router *gin.Engine, method, url, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decode decompresses a response body according to its Content-Encoding
+func decode(t *testing.T, w *httptest.ResponseRecorder) string {
+	var reader io.Reader = w.Body
+	switch w.Header().Get("Content-Encoding") {
+	case "gzip":
+		gz, err := gzip.NewReader(w.Body)
+		assert.NoError(t, err)
+		reader = gz
+	case "br":
+		reader = brotli.NewReader(w.Body)
+	}
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestMiddleware(t *testing.T) {
+	router := setupRouter()
+	large := strings.Repeat(`{"name":"Team Meeting"},`, 100)
+
+	t.Run("Negotiated encodings", func(t *testing.T) {
+		for acceptEncoding, expected := range map[string]string{
+			"gzip":                   "gzip",
+			"gzip, deflate, br":      "br",
+			"br;q=0.5, gzip":         "gzip",
+			"*":                      "br",
+			"br;q=0, *":              "gzip",
+			"identity":               "",
+			"":                       "",
+			"gzip;q=0, br;q=0, zstd": "",
+		} {
+			w := get(router, http.MethodGet, "/large", acceptEncoding)
+
+			assert.Equal(t, http.StatusOK, w.Code, acceptEncoding)
+			assert.Equal(t, expected, w.Header().Get("Content-Encoding"), acceptEncoding)
+			assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding", acceptEncoding)
+			assert.Equal(t, large, decode(t, w), acceptEncoding)
+		}
+	})
+
+	t.Run("Compressed body is smaller", func(t *testing.T) {
+		w := get(router, http.MethodGet, "/large", "br")
+		assert.Less(t, w.Body.Len(), len(large)/10)
+	})
+
+	t.Run("Left alone", func(t *testing.T) {
+		for _, url := range []string{"/small", "/image", "/encoded"} {
+			w := get(router, http.MethodGet, url, "br, gzip")
+			assert.NotEqual(t, "br", w.Header().Get("Content-Encoding"), url)
+		}
+
+		w := get(router, http.MethodGet, "/small", "gzip")
+		assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+		assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding", "size does not change the encoding caches must vary on")
+
+		w = get(router, http.MethodGet, "/encoded", "br")
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "encoded bodies are not encoded twice")
+		assert.Equal(t, large, w.Body.String())
+	})
+
+	t.Run("HEAD requests", func(t *testing.T) {
+		w := get(router, http.MethodHead, "/large", "gzip")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+	})
+
+	t.Run("Flushed streams are compressed as they go", func(t *testing.T) {
+		w := get(router, http.MethodGet, "/stream", "gzip")
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.True(t, w.Flushed)
+		assert.Equal(t, "data: first\n\ndata: second\n\n", decode(t, w))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		router := gin.New()
+		router.Use(Middleware(Config{MinSize: 1024}))
+		router.GET("/large", func(c *gin.Context) {
+			c.Data(http.StatusOK, "application/json", []byte(large))
+		})
+
+		w := get(router, http.MethodGet, "/large", "gzip")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+}
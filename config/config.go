@@ -0,0 +1,937 @@
+// Package config loads the service configuration. Values are layered with
+// this precedence, lowest first:
+//
+//  1. built-in defaults
+//  2. a YAML or TOML file given by -config or CONFIG_FILE
+//  3. environment variables
+//  4. command line flags
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultJWTSecret is only meant for local development; startup warns
+// when it is still in use.
+const DefaultJWTSecret = "superSecretKey"
+
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	OIDC      OIDCConfig      `yaml:"oidc" toml:"oidc"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+	Logging   LoggingConfig   `yaml:"logging" toml:"logging"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	CORS      CORSConfig      `yaml:"cors" toml:"cors"`
+	Security  SecurityConfig  `yaml:"security" toml:"security"`
+	TLS       TLSConfig       `yaml:"tls" toml:"tls"`
+	HTTP      HTTPConfig      `yaml:"http" toml:"http"`
+	GraphQL   GraphQLConfig   `yaml:"graphql" toml:"graphql"`
+	GRPC      GRPCConfig      `yaml:"grpc" toml:"grpc"`
+	Metrics   MetricsConfig   `yaml:"metrics" toml:"metrics"`
+}
+
+type ServerConfig struct {
+	Addr              string   `yaml:"addr" toml:"addr"`
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	MaxHeaderBytes    int      `yaml:"max_header_bytes" toml:"max_header_bytes"`
+	// MaxBodyBytes is the largest request body the API reads. Larger
+	// bodies are rejected with 413.
+	MaxBodyBytes    int      `yaml:"max_body_bytes" toml:"max_body_bytes"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// DrainDelay is how long /readyz reports 503 on shutdown before the
+	// listeners close, so load balancers stop sending new requests first.
+	DrainDelay Duration `yaml:"drain_delay" toml:"drain_delay"`
+	// IdempotencyTTL is how long responses are kept for replay to retries
+	// with the same Idempotency-Key.
+	IdempotencyTTL Duration `yaml:"idempotency_ttl" toml:"idempotency_ttl"`
+	// TrustedProxies lists the IPs and CIDRs whose X-Forwarded-For header
+	// is believed. Empty trusts no proxy, so the client IP is the peer
+	// address.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+type DatabaseConfig struct {
+	Path         string `yaml:"path" toml:"path"`
+	MaxOpenConns int    `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns int    `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	// EventCacheSize is how many events GetEventByID keeps in memory; 0
+	// disables the cache.
+	EventCacheSize int      `yaml:"event_cache_size" toml:"event_cache_size"`
+	EventCacheTTL  Duration `yaml:"event_cache_ttl" toml:"event_cache_ttl"`
+}
+
+type AuthConfig struct {
+	JWTSecret string   `yaml:"jwt_secret" toml:"jwt_secret"`
+	TokenTTL  Duration `yaml:"token_ttl" toml:"token_ttl"`
+	// Hasher is the scheme of new password hashes: argon2id or bcrypt.
+	// Hashes in the other scheme keep working and are replaced on the
+	// next login, as are hashes made with weaker parameters.
+	Hasher     string         `yaml:"hasher" toml:"hasher"`
+	BcryptCost int            `yaml:"bcrypt_cost" toml:"bcrypt_cost"`
+	Argon2id   Argon2idConfig `yaml:"argon2id" toml:"argon2id"`
+}
+
+type Argon2idConfig struct {
+	Time int `yaml:"time" toml:"time"`
+	// Memory is in KiB.
+	Memory  int `yaml:"memory" toml:"memory"`
+	Threads int `yaml:"threads" toml:"threads"`
+}
+
+type OIDCConfig struct {
+	StateTTL  Duration       `yaml:"state_ttl" toml:"state_ttl"`
+	Providers []OIDCProvider `yaml:"providers" toml:"providers"`
+}
+
+type OIDCProvider struct {
+	Name         string   `yaml:"name" toml:"name"`
+	Issuer       string   `yaml:"issuer" toml:"issuer"`
+	ClientID     string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" toml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url" toml:"redirect_url"`
+	Scopes       []string `yaml:"scopes" toml:"scopes"`
+}
+
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" toml:"exporter"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" toml:"otlp_endpoint"`
+	SampleRatio  float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+type LoggingConfig struct {
+	Format string `yaml:"format" toml:"format"`
+	Level  string `yaml:"level" toml:"level"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" toml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods" toml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers" toml:"allowed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials"`
+	MaxAge           Duration `yaml:"max_age" toml:"max_age"`
+}
+
+type HTTPConfig struct {
+	Compression        bool     `yaml:"compression" toml:"compression"`
+	CompressionMinSize int      `yaml:"compression_min_size" toml:"compression_min_size"`
+	CacheMaxAge        Duration `yaml:"cache_max_age" toml:"cache_max_age"`
+	// UnversionedRoutes keeps the API reachable without the /v1 prefix,
+	// with deprecation headers, until UnversionedSunset.
+	UnversionedRoutes bool `yaml:"unversioned_routes" toml:"unversioned_routes"`
+	UnversionedSunset Date `yaml:"unversioned_sunset" toml:"unversioned_sunset"`
+}
+
+// GraphQLConfig limits the queries /graphql accepts; 0 disables a limit.
+type GraphQLConfig struct {
+	MaxDepth      int `yaml:"max_depth" toml:"max_depth"`
+	MaxComplexity int `yaml:"max_complexity" toml:"max_complexity"`
+}
+
+// GRPCConfig configures the gRPC server that runs next to the HTTP one.
+type GRPCConfig struct {
+	// Addr is the listen address; empty disables gRPC.
+	Addr string `yaml:"addr" toml:"addr"`
+}
+
+// MetricsConfig protects /metrics.
+type MetricsConfig struct {
+	// Token is the bearer token scrapers send; empty disables /metrics.
+	Token string `yaml:"token" toml:"token"`
+	// MaxEventSeries is how many of the most recently active events keep
+	// their own registration series; 0 turns them off.
+	MaxEventSeries int `yaml:"max_event_series" toml:"max_event_series"`
+}
+
+type SecurityConfig struct {
+	HSTSMaxAge Duration `yaml:"hsts_max_age" toml:"hsts_max_age"`
+}
+
+// TLSConfig enables HTTPS when a certificate and key are given.
+type TLSConfig struct {
+	CertFile   string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile    string `yaml:"key_file" toml:"key_file"`
+	MinVersion string `yaml:"min_version" toml:"min_version"`
+	// RedirectAddr starts a plain HTTP listener that redirects to HTTPS.
+	RedirectAddr string `yaml:"redirect_addr" toml:"redirect_addr"`
+	ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file"`
+	// ServiceAccounts can only be set in the config file.
+	ServiceAccounts []ServiceAccount `yaml:"service_accounts" toml:"service_accounts"`
+}
+
+// serviceAccountScopes are the API key scopes of package auth.
+var serviceAccountScopes = []string{"events:write", "registrations:write"}
+
+// ServiceAccount maps a client certificate's common name to a user.
+type ServiceAccount struct {
+	Subject string   `yaml:"subject" toml:"subject"`
+	Email   string   `yaml:"email" toml:"email"`
+	Scopes  []string `yaml:"scopes" toml:"scopes"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// RateLimitConfig holds a policy per route group; see routes.RateLimits.
+type RateLimitConfig struct {
+	Enabled bool            `yaml:"enabled" toml:"enabled"`
+	Public  RateLimitPolicy `yaml:"public" toml:"public"`
+	Auth    RateLimitPolicy `yaml:"auth" toml:"auth"`
+	Events  RateLimitPolicy `yaml:"events" toml:"events"`
+	Account RateLimitPolicy `yaml:"account" toml:"account"`
+	GraphQL RateLimitPolicy `yaml:"graphql" toml:"graphql"`
+}
+
+type RateLimitPolicy struct {
+	Anonymous Rate `yaml:"anonymous" toml:"anonymous"`
+	User      Rate `yaml:"user" toml:"user"`
+	APIKey    Rate `yaml:"api_key" toml:"api_key"`
+}
+
+// Rate accepts "requests/period" strings such as "60/1m" in files. "0"
+// means unlimited.
+type Rate struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	s := string(text)
+	if s == "" || s == "0" {
+		*r = Rate{}
+		return nil
+	}
+
+	requests, period, found := strings.Cut(s, "/")
+	if !found {
+		return fmt.Errorf("rate %q must look like 60/1m", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return fmt.Errorf("rate %q: requests must be a positive number", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d < time.Millisecond {
+		return fmt.Errorf("rate %q: period must be at least 1ms", s)
+	}
+	*r = Rate{Requests: n, Period: d}
+	return nil
+}
+
+func (r Rate) MarshalText() ([]byte, error) {
+	if r.Requests == 0 {
+		return []byte("0"), nil
+	}
+	return []byte(strconv.Itoa(r.Requests) + "/" + r.Period.String()), nil
+}
+
+// Duration accepts Go duration strings such as "12h" in files.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Date accepts calendar dates such as "2027-04-30", meaning midnight UTC.
+// The empty string is the zero Date.
+type Date time.Time
+
+func (d *Date) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := time.Parse(time.DateOnly, string(text))
+	if err != nil {
+		return fmt.Errorf("date %q must look like 2027-04-30", text)
+	}
+	*d = Date(parsed)
+	return nil
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	if time.Time(d).IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(time.Time(d).Format(time.DateOnly)), nil
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadTimeout:       Duration(15 * time.Second),
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(120 * time.Second),
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      1 << 20,
+			ShutdownTimeout:   Duration(20 * time.Second),
+			IdempotencyTTL:    Duration(24 * time.Hour),
+		},
+		Database: DatabaseConfig{
+			Path:           "api.db",
+			MaxOpenConns:   10,
+			MaxIdleConns:   5,
+			EventCacheSize: 1000,
+			EventCacheTTL:  Duration(time.Minute),
+		},
+		Auth: AuthConfig{
+			JWTSecret:  DefaultJWTSecret,
+			TokenTTL:   Duration(12 * time.Hour),
+			Hasher:     "argon2id",
+			BcryptCost: 10,
+			Argon2id: Argon2idConfig{
+				Time:    2,
+				Memory:  19 * 1024,
+				Threads: 1,
+			},
+		},
+		OIDC: OIDCConfig{
+			StateTTL: Duration(10 * time.Minute),
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+		Logging: LoggingConfig{
+			Format: "json",
+			Level:  "info",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Public: RateLimitPolicy{
+				Anonymous: Rate{120, time.Minute},
+				User:      Rate{600, time.Minute},
+				APIKey:    Rate{600, time.Minute},
+			},
+			Auth: RateLimitPolicy{
+				Anonymous: Rate{10, time.Minute},
+				User:      Rate{10, time.Minute},
+				APIKey:    Rate{10, time.Minute},
+			},
+			Events: RateLimitPolicy{
+				User:   Rate{60, time.Minute},
+				APIKey: Rate{300, time.Minute},
+			},
+			Account: RateLimitPolicy{
+				User: Rate{60, time.Minute},
+			},
+			GraphQL: RateLimitPolicy{
+				Anonymous: Rate{60, time.Minute},
+				User:      Rate{300, time.Minute},
+				APIKey:    Rate{300, time.Minute},
+			},
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "Idempotency-Key"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		Security: SecurityConfig{
+			HSTSMaxAge: Duration(365 * 24 * time.Hour),
+		},
+		TLS: TLSConfig{
+			MinVersion: "1.2",
+		},
+		HTTP: HTTPConfig{
+			Compression:        true,
+			CompressionMinSize: 1024,
+			UnversionedRoutes:  true,
+			UnversionedSunset:  Date(time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)),
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      10,
+			MaxComplexity: 1000,
+		},
+		Metrics: MetricsConfig{
+			MaxEventSeries: 1000,
+		},
+	}
+}
+
+// setting is a scalar value that can be overridden from the environment
+// and the command line.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	// boolean flags may be given without a value, as in -rate-limit.
+	boolean bool
+	get     func() string
+	set     func(string) error
+}
+
+func stringSetting(key, env, flag, usage string, secret bool, p *string) setting {
+	return setting{key, env, flag, usage, secret, false,
+		func() string { return *p },
+		func(v string) error { *p = v; return nil },
+	}
+}
+
+func intSetting(key, env, flag, usage string, p *int) setting {
+	return setting{key, env, flag, usage, false, false,
+		func() string { return strconv.Itoa(*p) },
+		func(v string) error {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			*p = parsed
+			return nil
+		},
+	}
+}
+
+func floatSetting(key, env, flag, usage string, p *float64) setting {
+	return setting{key, env, flag, usage, false, false,
+		func() string { return strconv.FormatFloat(*p, 'g', -1, 64) },
+		func(v string) error {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return err
+			}
+			*p = parsed
+			return nil
+		},
+	}
+}
+
+// listSetting takes comma separated values from the environment and
+// flags.
+func listSetting(key, env, flag, usage string, p *[]string) setting {
+	return setting{key, env, flag, usage, false, false,
+		func() string { return strings.Join(*p, ",") },
+		func(v string) error {
+			*p = nil
+			for _, item := range strings.Split(v, ",") {
+				item = strings.TrimSpace(item)
+				if item != "" {
+					*p = append(*p, item)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func boolSetting(key, env, flag, usage string, p *bool) setting {
+	return setting{key, env, flag, usage, false, true,
+		func() string { return strconv.FormatBool(*p) },
+		func(v string) error {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			*p = parsed
+			return nil
+		},
+	}
+}
+
+func rateSetting(key, env, flag, usage string, p *Rate) setting {
+	return setting{key, env, flag, usage, false, false,
+		func() string { text, _ := p.MarshalText(); return string(text) },
+		func(v string) error { return p.UnmarshalText([]byte(v)) },
+	}
+}
+
+func durationSetting(key, env, flag, usage string, p *Duration) setting {
+	return setting{key, env, flag, usage, false, false,
+		func() string { return time.Duration(*p).String() },
+		func(v string) error { return p.UnmarshalText([]byte(v)) },
+	}
+}
+
+func dateSetting(key, env, flag, usage string, p *Date) setting {
+	return setting{key, env, flag, usage, false, false,
+		func() string { text, _ := p.MarshalText(); return string(text) },
+		func(v string) error { return p.UnmarshalText([]byte(v)) },
+	}
+}
+
+func (c *Config) settings() []setting {
+	settings := []setting{
+		stringSetting("server.addr", "SERVER_ADDR", "addr", "HTTP listen address", false, &c.Server.Addr),
+		durationSetting("server.read_timeout", "SERVER_READ_TIMEOUT", "read-timeout", "maximum time to read a whole request", &c.Server.ReadTimeout),
+		durationSetting("server.read_header_timeout", "SERVER_READ_HEADER_TIMEOUT", "read-header-timeout", "maximum time to read request headers", &c.Server.ReadHeaderTimeout),
+		durationSetting("server.write_timeout", "SERVER_WRITE_TIMEOUT", "write-timeout", "maximum time to write a response", &c.Server.WriteTimeout),
+		durationSetting("server.idle_timeout", "SERVER_IDLE_TIMEOUT", "idle-timeout", "how long idle keep-alive connections stay open", &c.Server.IdleTimeout),
+		intSetting("server.max_header_bytes", "SERVER_MAX_HEADER_BYTES", "max-header-bytes", "maximum size of request headers", &c.Server.MaxHeaderBytes),
+		intSetting("server.max_body_bytes", "SERVER_MAX_BODY_BYTES", "max-body-bytes", "maximum size of request bodies", &c.Server.MaxBodyBytes),
+		durationSetting("server.shutdown_timeout", "SERVER_SHUTDOWN_TIMEOUT", "shutdown-timeout", "time allowed to drain requests on shutdown", &c.Server.ShutdownTimeout),
+		durationSetting("server.drain_delay", "SERVER_DRAIN_DELAY", "drain-delay", "how long to report not ready before closing the listeners on shutdown", &c.Server.DrainDelay),
+		durationSetting("server.idempotency_ttl", "IDEMPOTENCY_TTL", "idempotency-ttl", "how long responses are replayed for a repeated Idempotency-Key", &c.Server.IdempotencyTTL),
+		listSetting("server.trusted_proxies", "TRUSTED_PROXIES", "trusted-proxies", "comma separated proxy IPs and CIDRs whose X-Forwarded-For is trusted", &c.Server.TrustedProxies),
+		stringSetting("database.path", "DB_PATH", "db-path", "SQLite database file", false, &c.Database.Path),
+		intSetting("database.max_open_conns", "DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections", &c.Database.MaxOpenConns),
+		intSetting("database.max_idle_conns", "DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", &c.Database.MaxIdleConns),
+		intSetting("database.event_cache_size", "EVENT_CACHE_SIZE", "event-cache-size", "events kept in memory for lookups by ID; 0 disables the cache", &c.Database.EventCacheSize),
+		durationSetting("database.event_cache_ttl", "EVENT_CACHE_TTL", "event-cache-ttl", "how long a cached event is served", &c.Database.EventCacheTTL),
+		stringSetting("auth.jwt_secret", "JWT_SECRET", "jwt-secret", "HMAC secret for signing tokens", true, &c.Auth.JWTSecret),
+		durationSetting("auth.token_ttl", "TOKEN_TTL", "token-ttl", "lifetime of issued tokens", &c.Auth.TokenTTL),
+		stringSetting("auth.hasher", "PASSWORD_HASHER", "password-hasher", "scheme of new password hashes: argon2id or bcrypt", false, &c.Auth.Hasher),
+		intSetting("auth.bcrypt_cost", "BCRYPT_COST", "bcrypt-cost", "bcrypt cost of new password hashes", &c.Auth.BcryptCost),
+		intSetting("auth.argon2id.time", "ARGON2ID_TIME", "argon2id-time", "Argon2id passes over memory", &c.Auth.Argon2id.Time),
+		intSetting("auth.argon2id.memory", "ARGON2ID_MEMORY", "argon2id-memory", "Argon2id memory in KiB", &c.Auth.Argon2id.Memory),
+		intSetting("auth.argon2id.threads", "ARGON2ID_THREADS", "argon2id-threads", "Argon2id parallelism", &c.Auth.Argon2id.Threads),
+		durationSetting("oidc.state_ttl", "OIDC_STATE_TTL", "oidc-state-ttl", "time allowed to complete a single sign-on login", &c.OIDC.StateTTL),
+		stringSetting("tracing.exporter", "TRACING_EXPORTER", "tracing-exporter", "trace exporter: none, stdout or otlp", false, &c.Tracing.Exporter),
+		stringSetting("tracing.otlp_endpoint", "TRACING_OTLP_ENDPOINT", "tracing-otlp-endpoint", "OTLP/HTTP collector URL", false, &c.Tracing.OTLPEndpoint),
+		floatSetting("tracing.sample_ratio", "TRACING_SAMPLE_RATIO", "tracing-sample-ratio", "fraction of new traces to record", &c.Tracing.SampleRatio),
+		stringSetting("logging.format", "LOG_FORMAT", "log-format", "log format: json or text", false, &c.Logging.Format),
+		stringSetting("logging.level", "LOG_LEVEL", "log-level", "minimum log level: debug, info, warn or error", false, &c.Logging.Level),
+		boolSetting("rate_limit.enabled", "RATE_LIMIT_ENABLED", "rate-limit", "enable rate limiting", &c.RateLimit.Enabled),
+		listSetting("cors.allowed_origins", "CORS_ALLOWED_ORIGINS", "cors-allowed-origins", "comma separated origins allowed to call the API", &c.CORS.AllowedOrigins),
+		listSetting("cors.allowed_methods", "CORS_ALLOWED_METHODS", "cors-allowed-methods", "comma separated methods allowed across origins", &c.CORS.AllowedMethods),
+		listSetting("cors.allowed_headers", "CORS_ALLOWED_HEADERS", "cors-allowed-headers", "comma separated request headers allowed across origins", &c.CORS.AllowedHeaders),
+		boolSetting("cors.allow_credentials", "CORS_ALLOW_CREDENTIALS", "cors-allow-credentials", "allow cookies and credentials across origins", &c.CORS.AllowCredentials),
+		durationSetting("cors.max_age", "CORS_MAX_AGE", "cors-max-age", "how long browsers cache preflight responses", &c.CORS.MaxAge),
+		durationSetting("security.hsts_max_age", "HSTS_MAX_AGE", "hsts-max-age", "Strict-Transport-Security max-age, 0 to disable", &c.Security.HSTSMaxAge),
+		boolSetting("http.compression", "HTTP_COMPRESSION", "compression", "compress responses with gzip or brotli", &c.HTTP.Compression),
+		intSetting("http.compression_min_size", "HTTP_COMPRESSION_MIN_SIZE", "compression-min-size", "smallest response body to compress, in bytes", &c.HTTP.CompressionMinSize),
+		durationSetting("http.cache_max_age", "HTTP_CACHE_MAX_AGE", "cache-max-age", "how long caches may serve event responses without revalidating", &c.HTTP.CacheMaxAge),
+		boolSetting("http.unversioned_routes", "HTTP_UNVERSIONED_ROUTES", "unversioned-routes", "serve the API without the /v1 prefix, marked deprecated", &c.HTTP.UnversionedRoutes),
+		dateSetting("http.unversioned_sunset", "HTTP_UNVERSIONED_SUNSET", "unversioned-sunset", "date announced in the Sunset header of unversioned routes", &c.HTTP.UnversionedSunset),
+		intSetting("graphql.max_depth", "GRAPHQL_MAX_DEPTH", "graphql-max-depth", "deepest selection nesting a GraphQL query may use; 0 is unlimited", &c.GraphQL.MaxDepth),
+		intSetting("graphql.max_complexity", "GRAPHQL_MAX_COMPLEXITY", "graphql-max-complexity", "most fields a GraphQL query may resolve; 0 is unlimited", &c.GraphQL.MaxComplexity),
+		stringSetting("grpc.addr", "GRPC_ADDR", "grpc-addr", "gRPC listen address; empty disables gRPC", false, &c.GRPC.Addr),
+		stringSetting("metrics.token", "METRICS_TOKEN", "metrics-token", "bearer token required to scrape /metrics; empty disables it", true, &c.Metrics.Token),
+		intSetting("metrics.max_event_series", "METRICS_MAX_EVENT_SERIES", "metrics-max-event-series", "recently active events with their own registration series; 0 disables them", &c.Metrics.MaxEventSeries),
+		stringSetting("tls.cert_file", "TLS_CERT_FILE", "tls-cert-file", "PEM certificate file; enables HTTPS", false, &c.TLS.CertFile),
+		stringSetting("tls.key_file", "TLS_KEY_FILE", "tls-key-file", "PEM private key file", false, &c.TLS.KeyFile),
+		stringSetting("tls.min_version", "TLS_MIN_VERSION", "tls-min-version", "minimum TLS version: 1.2 or 1.3", false, &c.TLS.MinVersion),
+		stringSetting("tls.redirect_addr", "TLS_REDIRECT_ADDR", "tls-redirect-addr", "HTTP listen address that redirects to HTTPS", false, &c.TLS.RedirectAddr),
+		stringSetting("tls.client_ca_file", "TLS_CLIENT_CA_FILE", "tls-client-ca-file", "PEM CA certificates for verifying client certificates", false, &c.TLS.ClientCAFile),
+	}
+	return append(settings, c.RateLimit.settings()...)
+}
+
+// settings covers every group and caller, for example
+// rate_limit.public.anonymous, RATE_LIMIT_PUBLIC_ANONYMOUS and
+// -rate-limit-public-anonymous.
+func (r *RateLimitConfig) settings() []setting {
+	groups := []struct {
+		name   string
+		policy *RateLimitPolicy
+	}{
+		{"public", &r.Public},
+		{"auth", &r.Auth},
+		{"events", &r.Events},
+		{"account", &r.Account},
+		{"graphql", &r.GraphQL},
+	}
+
+	var settings []setting
+	for _, g := range groups {
+		callers := []struct {
+			name  string
+			usage string
+			rate  *Rate
+		}{
+			{"anonymous", "per client IP", &g.policy.Anonymous},
+			{"user", "per user", &g.policy.User},
+			{"api_key", "per API key", &g.policy.APIKey},
+		}
+		for _, caller := range callers {
+			key := g.name + "." + caller.name
+			settings = append(settings, rateSetting(
+				"rate_limit."+key,
+				"RATE_LIMIT_"+strings.ToUpper(strings.ReplaceAll(key, ".", "_")),
+				"rate-limit-"+strings.ReplaceAll(strings.ReplaceAll(key, ".", "-"), "_", "-"),
+				fmt.Sprintf("%s rate limit %s, e.g. 60/1m (0 is unlimited)", g.name, caller.usage),
+				caller.rate,
+			))
+		}
+	}
+	return settings
+}
+
+// Load builds the configuration from args (without the program name) and
+// lookupEnv, which is os.LookupEnv outside of tests. A variable that is
+// set overrides the file even when it is empty, so GRPC_ADDR= turns gRPC
+// off.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+	getenv := func(key string) string {
+		v, _ := lookupEnv(key)
+		return v
+	}
+
+	fs := flag.NewFlagSet("rest-api", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "YAML or TOML configuration file (env CONFIG_FILE)")
+
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		name := s.flag
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		record := func(v string) error {
+			flagValues[name] = v
+			return nil
+		}
+		if s.boolean {
+			fs.BoolFunc(name, usage, record)
+		} else {
+			fs.Func(name, usage, record)
+		}
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		err = cfg.loadFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		v, ok := lookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		err = s.set(v)
+		if err != nil {
+			return nil, fmt.Errorf("config: %s: invalid value %q: %w", s.env, v, err)
+		}
+	}
+	cfg.OIDC.Providers = oidcProvidersFromEnv(lookupEnv, cfg.OIDC.Providers)
+
+	for _, s := range settings {
+		v, ok := flagValues[s.flag]
+		if !ok {
+			continue
+		}
+		err = s.set(v)
+		if err != nil {
+			return nil, fmt.Errorf("config: -%s: invalid value %q: %w", s.flag, v, err)
+		}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, c)
+	case ".toml":
+		err = toml.Unmarshal(content, c)
+	default:
+		return fmt.Errorf("config: unsupported file type %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// oidcProvidersFromEnv replaces the providers from the file when
+// OIDC_PROVIDERS is set; set but empty removes them. Each listed name is
+// configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET,
+// _REDIRECT_URL and optionally _SCOPES (space separated).
+func oidcProvidersFromEnv(lookupEnv func(string) (string, bool), fromFile []OIDCProvider) []OIDCProvider {
+	names, ok := lookupEnv("OIDC_PROVIDERS")
+	if !ok {
+		return fromFile
+	}
+	getenv := func(key string) string {
+		v, _ := lookupEnv(key)
+		return v
+	}
+
+	var providers []OIDCProvider
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:         name,
+			Issuer:       getenv(prefix + "ISSUER"),
+			ClientID:     getenv(prefix + "CLIENT_ID"),
+			ClientSecret: getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(getenv(prefix + "SCOPES")),
+		})
+	}
+
+	return providers
+}
+
+// Validate reports every invalid value at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty"))
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.ReadHeaderTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	}
+	if c.Server.ReadHeaderTimeout > c.Server.ReadTimeout {
+		errs = append(errs, errors.New("server.read_header_timeout must not exceed read_timeout"))
+	}
+	if c.Server.MaxHeaderBytes < 1024 {
+		errs = append(errs, errors.New("server.max_header_bytes must be at least 1024"))
+	}
+	if c.Server.MaxBodyBytes < 1024 {
+		errs = append(errs, errors.New("server.max_body_bytes must be at least 1024"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, errors.New("server.drain_delay must not be negative"))
+	}
+	if c.Server.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("server.idempotency_ttl must be positive"))
+	}
+	if c.Database.Path == "" {
+		errs = append(errs, errors.New("database.path must not be empty"))
+	}
+	if c.Database.MaxOpenConns < 1 {
+		errs = append(errs, errors.New("database.max_open_conns must be at least 1"))
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.max_idle_conns must be between 0 and max_open_conns"))
+	}
+	if c.Database.EventCacheSize < 0 {
+		errs = append(errs, errors.New("database.event_cache_size must not be negative"))
+	}
+	if c.Database.EventCacheTTL <= 0 {
+		errs = append(errs, errors.New("database.event_cache_ttl must be positive"))
+	}
+	if c.Auth.JWTSecret == "" {
+		errs = append(errs, errors.New("auth.jwt_secret must not be empty"))
+	}
+	if c.Auth.TokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.token_ttl must be positive"))
+	}
+	switch c.Auth.Hasher {
+	case "argon2id", "bcrypt":
+	default:
+		errs = append(errs, fmt.Errorf("auth.hasher: unknown hasher %q", c.Auth.Hasher))
+	}
+	// bcrypt allows costs from 4, but new hashes should not be weaker
+	// than its default of 10.
+	if c.Auth.BcryptCost < 10 || c.Auth.BcryptCost > 31 {
+		errs = append(errs, errors.New("auth.bcrypt_cost must be between 10 and 31"))
+	}
+	if c.Auth.Argon2id.Time < 1 || int64(c.Auth.Argon2id.Time) > math.MaxUint32 {
+		errs = append(errs, errors.New("auth.argon2id.time must be positive"))
+	}
+	if c.Auth.Argon2id.Threads < 1 || c.Auth.Argon2id.Threads > math.MaxUint8 {
+		errs = append(errs, errors.New("auth.argon2id.threads must be between 1 and 255"))
+	}
+	// Argon2 needs 8 KiB per thread.
+	if c.Auth.Argon2id.Memory < 8*c.Auth.Argon2id.Threads || int64(c.Auth.Argon2id.Memory) > math.MaxUint32 {
+		errs = append(errs, errors.New("auth.argon2id.memory must be at least 8 KiB per thread"))
+	}
+	if c.OIDC.StateTTL <= 0 {
+		errs = append(errs, errors.New("oidc.state_ttl must be positive"))
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, errors.New("tracing.exporter must be none, stdout or otlp"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+
+	if c.Logging.Format != "json" && c.Logging.Format != "text" {
+		errs = append(errs, errors.New("logging.format must be json or text"))
+	}
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, errors.New("logging.level must be debug, info, warn or error"))
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if !validIPOrCIDR(proxy) {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies: %q is not an IP address or CIDR", proxy))
+		}
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if !validOrigin(origin) {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins: %q must be * or scheme://host[:port]", origin))
+		}
+	}
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		errs = append(errs, errors.New("cors.allow_credentials cannot be combined with the * origin"))
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max_age must not be negative"))
+	}
+	if c.HTTP.CompressionMinSize < 0 {
+		errs = append(errs, errors.New("http.compression_min_size must not be negative"))
+	}
+	if c.HTTP.CacheMaxAge < 0 {
+		errs = append(errs, errors.New("http.cache_max_age must not be negative"))
+	}
+	if c.GraphQL.MaxDepth < 0 || c.GraphQL.MaxComplexity < 0 {
+		errs = append(errs, errors.New("graphql.max_depth and graphql.max_complexity must not be negative"))
+	}
+	if c.Metrics.MaxEventSeries < 0 {
+		errs = append(errs, errors.New("metrics.max_event_series must not be negative"))
+	}
+	if c.Security.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("security.hsts_max_age must not be negative"))
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls.cert_file and tls.key_file must be set together"))
+	}
+	if c.TLS.MinVersion != "1.2" && c.TLS.MinVersion != "1.3" {
+		errs = append(errs, errors.New("tls.min_version must be 1.2 or 1.3"))
+	}
+	if !c.TLS.Enabled() && (c.TLS.RedirectAddr != "" || c.TLS.ClientCAFile != "") {
+		errs = append(errs, errors.New("tls.redirect_addr and tls.client_ca_file require tls.cert_file"))
+	}
+	if c.TLS.RedirectAddr != "" && c.TLS.RedirectAddr == c.Server.Addr {
+		errs = append(errs, errors.New("tls.redirect_addr must differ from server.addr"))
+	}
+	if c.GRPC.Addr != "" && (c.GRPC.Addr == c.Server.Addr || c.GRPC.Addr == c.TLS.RedirectAddr) {
+		errs = append(errs, errors.New("grpc.addr must differ from server.addr and tls.redirect_addr"))
+	}
+	if len(c.TLS.ServiceAccounts) > 0 && c.TLS.ClientCAFile == "" {
+		errs = append(errs, errors.New("tls.service_accounts require tls.client_ca_file"))
+	}
+	subjects := map[string]bool{}
+	for i, a := range c.TLS.ServiceAccounts {
+		if a.Subject == "" || subjects[a.Subject] {
+			errs = append(errs, fmt.Errorf("tls.service_accounts[%d]: subject must be unique and not empty", i))
+		}
+		subjects[a.Subject] = true
+		if a.Email == "" {
+			errs = append(errs, fmt.Errorf("tls.service_accounts[%d]: email is required", i))
+		}
+		if len(a.Scopes) == 0 {
+			errs = append(errs, fmt.Errorf("tls.service_accounts[%d]: scopes are required", i))
+		}
+		for _, scope := range a.Scopes {
+			if !slices.Contains(serviceAccountScopes, scope) {
+				errs = append(errs, fmt.Errorf("tls.service_accounts[%d]: unknown scope %q", i, scope))
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, p := range c.OIDC.Providers {
+		if p.Name == "" || seen[p.Name] {
+			errs = append(errs, fmt.Errorf("oidc.providers[%d]: name must be unique and not empty", i))
+		}
+		seen[p.Name] = true
+		if p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+			errs = append(errs, fmt.Errorf("oidc.providers[%d]: issuer, client_id and redirect_url are required", i))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func validIPOrCIDR(s string) bool {
+	_, err := netip.ParsePrefix(s)
+	if err == nil {
+		return true
+	}
+	_, err = netip.ParseAddr(s)
+	return err == nil
+}
+
+// validOrigin accepts "*" and origins such as "https://app.example.com" or
+// "https://*.example.com", without a path.
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.User == nil
+}
+
+// Redacted renders the effective configuration, one "key = value" per line,
+// with secrets masked.
+func (c *Config) Redacted() string {
+	var b strings.Builder
+
+	for _, s := range c.settings() {
+		value := s.get()
+		if s.secret {
+			value = redact(value)
+		}
+		fmt.Fprintf(&b, "%s = %s\n", s.key, value)
+	}
+
+	for _, p := range c.OIDC.Providers {
+		fmt.Fprintf(&b, "oidc.providers.%s = issuer=%s client_id=%s client_secret=%s redirect_url=%s scopes=%s\n",
+			p.Name, p.Issuer, p.ClientID, redact(p.ClientSecret), p.RedirectURL, strings.Join(p.Scopes, " "))
+	}
+
+	for _, a := range c.TLS.ServiceAccounts {
+		fmt.Fprintf(&b, "tls.service_accounts.%s = email=%s scopes=%s\n", a.Subject, a.Email, strings.Join(a.Scopes, " "))
+	}
+
+	return b.String()
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
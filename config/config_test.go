@@ -0,0 +1,402 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// envMap returns a lookupEnv function backed by a map
+func envMap(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+// writeFile writes content to name in a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil, envMap(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), *cfg)
+	assert.Empty(t, cfg.GRPC.Addr, "gRPC is off until an address is set")
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+server:
+  addr: ":9000"
+database:
+  path: file.db
+  max_open_conns: 20
+auth:
+  token_ttl: 1h
+  argon2id:
+    memory: 65536
+`)
+
+	t.Run("File overrides defaults", func(t *testing.T) {
+		cfg, err := Load([]string{"-config", yamlFile}, envMap(nil))
+		assert.NoError(t, err)
+		assert.Equal(t, ":9000", cfg.Server.Addr)
+		assert.Equal(t, "file.db", cfg.Database.Path)
+		assert.Equal(t, 20, cfg.Database.MaxOpenConns)
+		assert.Equal(t, 5, cfg.Database.MaxIdleConns, "unset keys keep their default")
+		assert.Equal(t, Duration(time.Hour), cfg.Auth.TokenTTL)
+		assert.Equal(t, Argon2idConfig{Time: 2, Memory: 65536, Threads: 1}, cfg.Auth.Argon2id)
+	})
+
+	t.Run("Environment overrides file", func(t *testing.T) {
+		cfg, err := Load(nil, envMap(map[string]string{
+			"CONFIG_FILE": yamlFile,
+			"DB_PATH":     "env.db",
+			"TOKEN_TTL":   "30m",
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, ":9000", cfg.Server.Addr)
+		assert.Equal(t, "env.db", cfg.Database.Path)
+		assert.Equal(t, Duration(30*time.Minute), cfg.Auth.TokenTTL)
+	})
+
+	t.Run("Empty environment variables override the file", func(t *testing.T) {
+		grpcFile := writeFile(t, "grpc.yaml", `
+grpc:
+  addr: ":9443"
+`)
+		cfg, err := Load([]string{"-config", grpcFile}, envMap(nil))
+		assert.NoError(t, err)
+		assert.Equal(t, ":9443", cfg.GRPC.Addr)
+
+		cfg, err = Load([]string{"-config", grpcFile}, envMap(map[string]string{"GRPC_ADDR": ""}))
+		assert.NoError(t, err)
+		assert.Empty(t, cfg.GRPC.Addr)
+
+		_, err = Load(nil, envMap(map[string]string{"TOKEN_TTL": ""}))
+		assert.ErrorContains(t, err, "TOKEN_TTL")
+	})
+
+	t.Run("Flags override environment", func(t *testing.T) {
+		cfg, err := Load(
+			[]string{"-config", yamlFile, "-db-path", "flag.db", "-addr", ":7000"},
+			envMap(map[string]string{"DB_PATH": "env.db", "SERVER_ADDR": ":6000"}),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, ":7000", cfg.Server.Addr)
+		assert.Equal(t, "flag.db", cfg.Database.Path)
+	})
+}
+
+func TestLoad_TOML(t *testing.T) {
+	tomlFile := writeFile(t, "config.toml", `
+[server]
+addr = ":9100"
+
+[auth]
+jwt_secret = "from-toml"
+token_ttl = "2h"
+
+[[oidc.providers]]
+name = "corp"
+issuer = "https://sso.example.com"
+client_id = "client"
+client_secret = "secret"
+redirect_url = "http://localhost:8080/auth/corp/callback"
+`)
+
+	cfg, err := Load([]string{"-config", tomlFile}, envMap(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, ":9100", cfg.Server.Addr)
+	assert.Equal(t, "from-toml", cfg.Auth.JWTSecret)
+	assert.Equal(t, Duration(2*time.Hour), cfg.Auth.TokenTTL)
+	assert.Len(t, cfg.OIDC.Providers, 1)
+	assert.Equal(t, "corp", cfg.OIDC.Providers[0].Name)
+}
+
+func TestLoad_OIDCProvidersFromEnv(t *testing.T) {
+	cfg, err := Load(nil, envMap(map[string]string{
+		"OIDC_PROVIDERS":            "google",
+		"OIDC_GOOGLE_ISSUER":        "https://accounts.google.com",
+		"OIDC_GOOGLE_CLIENT_ID":     "id",
+		"OIDC_GOOGLE_CLIENT_SECRET": "secret",
+		"OIDC_GOOGLE_REDIRECT_URL":  "http://localhost:8080/auth/google/callback",
+		"OIDC_GOOGLE_SCOPES":        "openid email",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []OIDCProvider{{
+		Name:         "google",
+		Issuer:       "https://accounts.google.com",
+		ClientID:     "id",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/auth/google/callback",
+		Scopes:       []string{"openid", "email"},
+	}}, cfg.OIDC.Providers)
+}
+
+func TestLoad_RateLimits(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+rate_limit:
+  public:
+    anonymous: 30/10s
+  auth:
+    user: "0"
+`)
+
+	cfg, err := Load(
+		[]string{"-config", yamlFile, "-rate-limit-events-api-key", "5/1s"},
+		envMap(map[string]string{"RATE_LIMIT_ACCOUNT_USER": "100/1h", "RATE_LIMIT_ENABLED": "false"}),
+	)
+	assert.NoError(t, err)
+	assert.False(t, cfg.RateLimit.Enabled)
+	assert.Equal(t, Rate{30, 10 * time.Second}, cfg.RateLimit.Public.Anonymous)
+	assert.Equal(t, Rate{600, time.Minute}, cfg.RateLimit.Public.User, "unset rates keep their default")
+	assert.Equal(t, Rate{}, cfg.RateLimit.Auth.User)
+	assert.Equal(t, Rate{5, time.Second}, cfg.RateLimit.Events.APIKey)
+	assert.Equal(t, Rate{100, time.Hour}, cfg.RateLimit.Account.User)
+	assert.Equal(t, Rate{60, time.Minute}, cfg.RateLimit.GraphQL.Anonymous)
+	assert.Contains(t, cfg.Redacted(), "rate_limit.public.anonymous = 30/10s")
+
+	for _, invalid := range []string{"60", "x/1m", "0/1m", "60/soon", "60/-1m", "10/1ns"} {
+		_, err = Load(nil, envMap(map[string]string{"RATE_LIMIT_PUBLIC_ANONYMOUS": invalid}))
+		assert.ErrorContains(t, err, "RATE_LIMIT_PUBLIC_ANONYMOUS", invalid)
+	}
+}
+
+func TestLoad_CORSAndProxies(t *testing.T) {
+	cfg, err := Load([]string{"-cors-allow-credentials"}, envMap(map[string]string{
+		"CORS_ALLOWED_ORIGINS": "https://app.example.com, https://*.example.org",
+		"TRUSTED_PROXIES":      "10.0.0.0/8,192.0.2.1",
+		"HSTS_MAX_AGE":         "0s",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://app.example.com", "https://*.example.org"}, cfg.CORS.AllowedOrigins)
+	assert.True(t, cfg.CORS.AllowCredentials)
+	assert.Equal(t, []string{"GET", "POST", "PUT", "PATCH", "DELETE"}, cfg.CORS.AllowedMethods, "unset lists keep their default")
+	assert.Equal(t, []string{"10.0.0.0/8", "192.0.2.1"}, cfg.Server.TrustedProxies)
+	assert.Equal(t, Duration(0), cfg.Security.HSTSMaxAge)
+	assert.Contains(t, cfg.Redacted(), "server.trusted_proxies = 10.0.0.0/8,192.0.2.1")
+}
+
+func TestLoad_TLS(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+tls:
+  cert_file: /etc/api/tls.crt
+  key_file: /etc/api/tls.key
+  client_ca_file: /etc/api/clients.pem
+  service_accounts:
+    - subject: billing-service
+      email: billing@example.com
+      scopes: [registrations:write]
+`)
+
+	cfg, err := Load([]string{"-config", yamlFile, "-tls-min-version", "1.3"}, envMap(map[string]string{"TLS_REDIRECT_ADDR": ":80"}))
+	assert.NoError(t, err)
+	assert.True(t, cfg.TLS.Enabled())
+	assert.Equal(t, "/etc/api/tls.crt", cfg.TLS.CertFile)
+	assert.Equal(t, "1.3", cfg.TLS.MinVersion)
+	assert.Equal(t, ":80", cfg.TLS.RedirectAddr)
+	assert.Equal(t, []ServiceAccount{{Subject: "billing-service", Email: "billing@example.com", Scopes: []string{"registrations:write"}}}, cfg.TLS.ServiceAccounts)
+	assert.Contains(t, cfg.Redacted(), "tls.service_accounts.billing-service = email=billing@example.com scopes=registrations:write")
+
+	cfg, err = Load(nil, envMap(nil))
+	assert.NoError(t, err)
+	assert.False(t, cfg.TLS.Enabled(), "plain HTTP by default")
+	assert.Equal(t, "1.2", cfg.TLS.MinVersion)
+}
+
+func TestLoad_UnversionedRoutes(t *testing.T) {
+	sunset := func(cfg *Config) string {
+		return time.Time(cfg.HTTP.UnversionedSunset).Format(time.DateOnly)
+	}
+
+	cfg, err := Load(nil, envMap(nil))
+	assert.NoError(t, err)
+	assert.True(t, cfg.HTTP.UnversionedRoutes)
+	assert.Equal(t, "2027-04-30", sunset(cfg))
+
+	yamlFile := writeFile(t, "config.yaml", `
+http:
+  unversioned_sunset: "2027-12-31"
+`)
+	cfg, err = Load([]string{"-config", yamlFile}, envMap(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "2027-12-31", sunset(cfg))
+	assert.Contains(t, cfg.Redacted(), "http.unversioned_sunset = 2027-12-31")
+
+	tomlFile := writeFile(t, "config.toml", `
+[http]
+unversioned_routes = false
+unversioned_sunset = "2027-06-30"
+`)
+	cfg, err = Load([]string{"-config", tomlFile}, envMap(nil))
+	assert.NoError(t, err)
+	assert.False(t, cfg.HTTP.UnversionedRoutes)
+	assert.Equal(t, "2027-06-30", sunset(cfg))
+
+	cfg, err = Load([]string{"-unversioned-sunset", ""}, envMap(nil))
+	assert.NoError(t, err)
+	assert.True(t, time.Time(cfg.HTTP.UnversionedSunset).IsZero())
+
+	_, err = Load(nil, envMap(map[string]string{"HTTP_UNVERSIONED_SUNSET": "30/04/2027"}))
+	assert.ErrorContains(t, err, "HTTP_UNVERSIONED_SUNSET")
+}
+
+func TestLoad_Errors(t *testing.T) {
+	t.Run("All validation errors are reported", func(t *testing.T) {
+		_, err := Load([]string{"-db-max-open-conns", "0", "-addr", ""}, envMap(map[string]string{"TOKEN_TTL": "-1h"}))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "server.addr")
+		assert.Contains(t, err.Error(), "database.max_open_conns")
+		assert.Contains(t, err.Error(), "auth.token_ttl")
+	})
+
+	t.Run("Password hasher", func(t *testing.T) {
+		_, err := Load([]string{"-password-hasher", "md5", "-bcrypt-cost", "4", "-argon2id-time", "0"},
+			envMap(map[string]string{"ARGON2ID_THREADS": "4", "ARGON2ID_MEMORY": "16"}))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "auth.hasher")
+		assert.Contains(t, err.Error(), "auth.bcrypt_cost")
+		assert.Contains(t, err.Error(), "auth.argon2id.time")
+		assert.Contains(t, err.Error(), "auth.argon2id.memory")
+		assert.NotContains(t, err.Error(), "auth.argon2id.threads")
+	})
+
+	t.Run("Event cache", func(t *testing.T) {
+		_, err := Load([]string{"-event-cache-size", "-1"}, envMap(map[string]string{"EVENT_CACHE_TTL": "0s"}))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "database.event_cache_size")
+		assert.Contains(t, err.Error(), "database.event_cache_ttl")
+	})
+
+	t.Run("Server limits", func(t *testing.T) {
+		_, err := Load([]string{"-read-timeout", "2s", "-read-header-timeout", "5s", "-max-header-bytes", "10", "-max-body-bytes", "0", "-shutdown-timeout", "0s", "-drain-delay", "-1s"}, envMap(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "server.read_header_timeout")
+		assert.Contains(t, err.Error(), "server.max_header_bytes")
+		assert.Contains(t, err.Error(), "server.max_body_bytes")
+		assert.Contains(t, err.Error(), "server.shutdown_timeout")
+		assert.Contains(t, err.Error(), "server.drain_delay")
+	})
+
+	t.Run("Tracing", func(t *testing.T) {
+		_, err := Load([]string{"-tracing-exporter", "jaeger", "-tracing-sample-ratio", "1.5"}, envMap(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "tracing.exporter")
+		assert.Contains(t, err.Error(), "tracing.sample_ratio")
+	})
+
+	t.Run("Logging", func(t *testing.T) {
+		_, err := Load(nil, envMap(map[string]string{"LOG_FORMAT": "xml", "LOG_LEVEL": "verbose"}))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "logging.format")
+		assert.Contains(t, err.Error(), "logging.level")
+	})
+
+	t.Run("CORS and proxies", func(t *testing.T) {
+		_, err := Load([]string{"-cors-allow-credentials", "-cors-max-age", "-1s"}, envMap(map[string]string{
+			"CORS_ALLOWED_ORIGINS": "*,app.example.com,https://example.com/path",
+			"TRUSTED_PROXIES":      "10.0.0.0/8,proxy.internal",
+		}))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `"proxy.internal" is not an IP address or CIDR`)
+		assert.Contains(t, err.Error(), `"app.example.com" must be`)
+		assert.Contains(t, err.Error(), `"https://example.com/path" must be`)
+		assert.Contains(t, err.Error(), "cors.allow_credentials cannot be combined")
+		assert.Contains(t, err.Error(), "cors.max_age")
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		_, err := Load([]string{"-compression-min-size", "-1", "-cache-max-age", "-1m"}, envMap(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "http.compression_min_size")
+		assert.Contains(t, err.Error(), "http.cache_max_age")
+	})
+
+	t.Run("GraphQL", func(t *testing.T) {
+		_, err := Load([]string{"-graphql-max-depth", "-1"}, envMap(map[string]string{"GRAPHQL_MAX_COMPLEXITY": "-5"}))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "graphql.max_depth")
+		assert.Contains(t, err.Error(), "graphql.max_complexity")
+	})
+
+	t.Run("gRPC", func(t *testing.T) {
+		_, err := Load([]string{"-grpc-addr", ":8080"}, envMap(nil))
+		assert.ErrorContains(t, err, "grpc.addr must differ")
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		yamlFile := writeFile(t, "config.yaml", `
+tls:
+  min_version: "1.1"
+  redirect_addr: ":80"
+  service_accounts:
+    - subject: billing-service
+    - subject: billing-service
+      email: billing@example.com
+      scopes: [events:read]
+`)
+		_, err := Load([]string{"-config", yamlFile, "-tls-key-file", "tls.key"}, envMap(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "tls.cert_file and tls.key_file must be set together")
+		assert.Contains(t, err.Error(), "tls.min_version")
+		assert.Contains(t, err.Error(), "tls.redirect_addr and tls.client_ca_file require tls.cert_file")
+		assert.Contains(t, err.Error(), "tls.service_accounts require tls.client_ca_file")
+		assert.Contains(t, err.Error(), "tls.service_accounts[0]: email is required")
+		assert.Contains(t, err.Error(), "tls.service_accounts[1]: subject must be unique")
+		assert.Contains(t, err.Error(), "tls.service_accounts[0]: scopes are required")
+		assert.Contains(t, err.Error(), `tls.service_accounts[1]: unknown scope "events:read"`)
+	})
+
+	t.Run("Unparsable value", func(t *testing.T) {
+		_, err := Load(nil, envMap(map[string]string{"DB_MAX_OPEN_CONNS": "many"}))
+		assert.ErrorContains(t, err, "DB_MAX_OPEN_CONNS")
+	})
+
+	t.Run("Unknown flag", func(t *testing.T) {
+		_, err := Load([]string{"-nope"}, envMap(nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("Unsupported file type", func(t *testing.T) {
+		path := writeFile(t, "config.json", "{}")
+		_, err := Load([]string{"-config", path}, envMap(nil))
+		assert.ErrorContains(t, err, "unsupported file type")
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := Load([]string{"-config", "/does/not/exist.yaml"}, envMap(nil))
+		assert.Error(t, err)
+	})
+}
+
+func TestRedacted(t *testing.T) {
+	cfg, err := Load([]string{"-jwt-secret", "top-secret-value"}, envMap(map[string]string{
+		"OIDC_PROVIDERS":          "corp",
+		"OIDC_CORP_ISSUER":        "https://sso.example.com",
+		"OIDC_CORP_CLIENT_ID":     "client",
+		"OIDC_CORP_CLIENT_SECRET": "client-secret-value",
+		"OIDC_CORP_REDIRECT_URL":  "http://localhost/cb",
+		"METRICS_TOKEN":           "metrics-token-value",
+	}))
+	assert.NoError(t, err)
+
+	out := cfg.Redacted()
+	assert.NotContains(t, out, "top-secret-value")
+	assert.NotContains(t, out, "client-secret-value")
+	assert.NotContains(t, out, "metrics-token-value")
+	assert.Contains(t, out, "auth.jwt_secret = [REDACTED]")
+	assert.Contains(t, out, "server.addr = :8080")
+	assert.True(t, strings.Contains(out, "oidc.providers.corp"))
+}
@@ -1,64 +1,66 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+)
 import _ "github.com/mattn/go-sqlite3"
 
 var DB *sql.DB
 
-func InitDB() {
-	var err error
-	DB, err = sql.Open("sqlite3", "api.db")
-
-	if err != nil {
-		panic("Could not connect to db.")
-	}
-
-	DB.SetMaxOpenConns(10)
-	DB.SetMaxIdleConns(5)
-
-	createTables()
+type Config struct {
+	Path         string
+	MaxOpenConns int
+	MaxIdleConns int
 }
 
-func createTables() {
-	createUsersTable := `
-		CREATE TABLE IF NOT EXISTS users (
-		    id INTEGER PRIMARY KEY AUTOINCREMENT,
-		    email TEXT NOT NULL UNIQUE,
-		    password TEXT NOT NULL
-		)`
+// Open opens a SQLite database whose queries are traced. Statements run
+// with a request context become child spans of the request.
+func Open(dsn string) (*sql.DB, error) {
+	return otelsql.Open("sqlite3", dsn,
+		otelsql.WithAttributes(semconv.DBSystemNameSQLite),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			OmitConnectorConnect: true,
+		}),
+	)
+}
 
-	_, err := DB.Exec(createUsersTable)
-	if err != nil {
-		panic("Could not create users table.")
+func InitDB(config Config) {
+	// Foreign keys are off by default in SQLite and must be enabled on
+	// every connection, which the driver does for us through the DSN.
+	dsn := config.Path
+	if strings.Contains(dsn, "?") {
+		dsn += "&_foreign_keys=on"
+	} else {
+		dsn += "?_foreign_keys=on"
 	}
 
-	createEventsTable := `
-		CREATE TABLE IF NOT EXISTS events (
-		    id INTEGER PRIMARY KEY AUTOINCREMENT,
-		    name TEXT NOT NULL,
-		    description TEXT NOT NULL,
-		    location TEXT NOT NULL,
-		    date_time DATETIME NOT NULL,
-		    user_id INTEGER,
-		    FOREIGN KEY(user_id) REFERENCES users(id)                             
-		)`
+	var err error
+	DB, err = Open(dsn)
 
-	_, err = DB.Exec(createEventsTable)
 	if err != nil {
-		panic("Could not create events table.")
+		panic("Could not connect to db.")
 	}
 
-	createRegistrationTable := `
-		CREATE TABLE IF NOT EXISTS registrations (
-		    id INTEGER PRIMARY KEY AUTOINCREMENT,
-		    event_id INTEGER,
-		    user_id INTEGER,
-		    FOREIGN KEY(event_id) REFERENCES events(id),
-		    FOREIGN KEY(user_id) REFERENCES users(id)                             
-		)`
+	DB.SetMaxOpenConns(config.MaxOpenConns)
+	DB.SetMaxIdleConns(config.MaxIdleConns)
 
-	_, err = DB.Exec(createRegistrationTable)
+	err = Migrate(DB)
 	if err != nil {
-		return
+		panic("Could not migrate db: " + err.Error())
+	}
+}
+
+// Close closes the connection pool. It is called once on shutdown after
+// the HTTP server has stopped handling requests.
+func Close() error {
+	if DB == nil {
+		return nil
 	}
+	return DB.Close()
 }
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order and tracked with PRAGMA user_version, so
+// entries must never be edited or reordered once released; append new ones.
+// The first three use IF NOT EXISTS because databases created before
+// versioning already have those tables at user_version 0.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+	    id INTEGER PRIMARY KEY AUTOINCREMENT,
+	    email TEXT NOT NULL UNIQUE,
+	    password TEXT NOT NULL
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS events (
+	    id INTEGER PRIMARY KEY AUTOINCREMENT,
+	    name TEXT NOT NULL,
+	    description TEXT NOT NULL,
+	    location TEXT NOT NULL,
+	    date_time DATETIME NOT NULL,
+	    user_id INTEGER,
+	    FOREIGN KEY(user_id) REFERENCES users(id)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS registrations (
+	    id INTEGER PRIMARY KEY AUTOINCREMENT,
+	    event_id INTEGER,
+	    user_id INTEGER,
+	    FOREIGN KEY(event_id) REFERENCES events(id),
+	    FOREIGN KEY(user_id) REFERENCES users(id)
+	)`,
+
+	// Kept so later versions keep their numbers; duplicate registrations
+	// are no longer deleted here but reported by the check of the index
+	// below.
+	`SELECT 1`,
+
+	`CREATE UNIQUE INDEX IF NOT EXISTS registrations_event_user
+	    ON registrations(event_id, user_id)`,
+
+	`ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT ''`,
+
+	`ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT ''`,
+
+	`ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT ''`,
+
+	`CREATE TABLE email_verifications (
+	    id INTEGER PRIMARY KEY AUTOINCREMENT,
+	    user_id INTEGER NOT NULL,
+	    email TEXT NOT NULL,
+	    token_hash TEXT NOT NULL UNIQUE,
+	    expires_at DATETIME NOT NULL,
+	    FOREIGN KEY(user_id) REFERENCES users(id)
+	)`,
+
+	`CREATE TABLE api_keys (
+	    id INTEGER PRIMARY KEY AUTOINCREMENT,
+	    user_id INTEGER NOT NULL,
+	    name TEXT NOT NULL,
+	    prefix TEXT NOT NULL,
+	    key_hash TEXT NOT NULL UNIQUE,
+	    scopes TEXT NOT NULL DEFAULT '',
+	    expires_at DATETIME,
+	    last_used_at DATETIME,
+	    created_at DATETIME NOT NULL,
+	    FOREIGN KEY(user_id) REFERENCES users(id)
+	)`,
+
+	`CREATE TABLE user_identities (
+	    id INTEGER PRIMARY KEY AUTOINCREMENT,
+	    user_id INTEGER NOT NULL,
+	    provider TEXT NOT NULL,
+	    subject TEXT NOT NULL,
+	    email TEXT NOT NULL,
+	    created_at DATETIME NOT NULL,
+	    UNIQUE(provider, subject),
+	    FOREIGN KEY(user_id) REFERENCES users(id)
+	)`,
+
+	`CREATE TABLE idempotency_keys (
+	    id INTEGER PRIMARY KEY AUTOINCREMENT,
+	    user_id INTEGER NOT NULL,
+	    key TEXT NOT NULL,
+	    fingerprint TEXT NOT NULL,
+	    status INTEGER NOT NULL DEFAULT 0,
+	    content_type TEXT NOT NULL DEFAULT '',
+	    body BLOB,
+	    expires_at DATETIME NOT NULL,
+	    UNIQUE(user_id, key),
+	    FOREIGN KEY(user_id) REFERENCES users(id)
+	)`,
+
+	`ALTER TABLE events ADD COLUMN created_at DATETIME`,
+
+	`ALTER TABLE events ADD COLUMN updated_at DATETIME`,
+
+	`UPDATE events SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP`,
+
+	`CREATE INDEX events_updated_at ON events(updated_at)`,
+
+	// A single row holding the time the last event was deleted, so the
+	// Last-Modified of the event list also changes when it shrinks.
+	`CREATE TABLE event_deletions (
+	    id INTEGER PRIMARY KEY CHECK (id = 1),
+	    deleted_at DATETIME NOT NULL
+	)`,
+
+	`ALTER TABLE events ADD COLUMN cancelled_at DATETIME`,
+
+	// Set once the user proves they own the email, through an email
+	// change or a provider that verified it. Signup does not verify.
+	`ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0`,
+}
+
+// checks run before the migration at the same index. They refuse to
+// migrate data that the migration would fail on or lose, with an error
+// that tells the operator what to resolve.
+var checks = map[int]func(*sql.Tx) error{
+	4: checkDuplicateRegistrations,
+}
+
+// checkDuplicateRegistrations guards the unique registrations index.
+// Which of the duplicates to keep is left to the operator.
+func checkDuplicateRegistrations(tx *sql.Tx) error {
+	var duplicates int
+	err := tx.QueryRow(`
+	SELECT COUNT(*) FROM (
+	    SELECT 1 FROM registrations GROUP BY event_id, user_id HAVING COUNT(*) > 1
+	)`).Scan(&duplicates)
+	if err != nil {
+		return err
+	}
+	if duplicates > 0 {
+		return fmt.Errorf("%d users are registered more than once for the same event; "+
+			"remove the extra registrations rows, for example all but the lowest id of each (event_id, user_id), and restart", duplicates)
+	}
+	return nil
+}
+
+// Migrate brings conn up to the latest schema version.
+func Migrate(conn *sql.DB) error {
+	current, err := SchemaVersion(conn)
+	if err != nil {
+		return err
+	}
+
+	for version := current; version < len(migrations); version++ {
+		err = applyMigration(conn, version)
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the number of migrations applied to conn.
+func SchemaVersion(conn *sql.DB) (int, error) {
+	var version int
+	err := conn.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// LatestSchemaVersion returns the version Migrate brings a database up to.
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+func applyMigration(conn *sql.DB, version int) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	check, ok := checks[version]
+	if ok {
+		err = check(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(migrations[version])
+	if err != nil {
+		return err
+	}
+
+	// PRAGMA does not accept bound parameters.
+	_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
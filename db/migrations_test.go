@@ -0,0 +1,39 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate_DuplicateRegistrations(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	conn.SetMaxOpenConns(1)
+
+	// A database from before the unique index
+	for version := range 4 {
+		assert.NoError(t, applyMigration(conn, version))
+	}
+	_, err = conn.Exec(`INSERT INTO registrations (event_id, user_id) VALUES (1, 1), (1, 1), (1, 2)`)
+	assert.NoError(t, err)
+
+	err = Migrate(conn)
+	assert.ErrorContains(t, err, "migration 5: 1 users are registered more than once")
+	version, _ := SchemaVersion(conn)
+	assert.Equal(t, 4, version, "stops before the index")
+
+	var count int
+	assert.NoError(t, conn.QueryRow(`SELECT COUNT(*) FROM registrations`).Scan(&count))
+	assert.Equal(t, 3, count, "nothing is deleted")
+
+	_, err = conn.Exec(`DELETE FROM registrations WHERE id = 2`)
+	assert.NoError(t, err)
+	assert.NoError(t, Migrate(conn), "migrates once resolved")
+	version, _ = SchemaVersion(conn)
+	assert.Equal(t, LatestSchemaVersion(), version)
+}
@@ -0,0 +1,123 @@
+package graphql
+
+import (
+	"REST_API/logging"
+	"REST_API/models"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// Error codes reported in the extensions of an error, following the codes
+// common GraphQL clients already know.
+const (
+	codeUnauthenticated = "UNAUTHENTICATED"
+	codeForbidden       = "FORBIDDEN"
+	codeNotFound        = "NOT_FOUND"
+	codeConflict        = "CONFLICT"
+	codeBadUserInput    = "BAD_USER_INPUT"
+	codeQueryTooComplex = "QUERY_TOO_COMPLEX"
+	codeInternal        = "INTERNAL_SERVER_ERROR"
+)
+
+// clientError is a failure caused by the request that is not a domain
+// error, such as missing credentials or a malformed ID.
+type clientError struct {
+	code    string
+	message string
+}
+
+func (e *clientError) Error() string {
+	return e.message
+}
+
+var (
+	errUnauthenticated = &clientError{codeUnauthenticated, "Authentication required"}
+	errUserSession     = &clientError{codeForbidden, "Only signed-in users can access this resource"}
+	errInvalidEventID  = &clientError{codeBadUserInput, "Invalid event ID"}
+	errInvalidCursor   = &clientError{codeBadUserInput, "Invalid cursor"}
+)
+
+// clientErrors gives every resolver error its code, the same way
+// routes.handleErrors maps errors to problem responses: domain and client
+// errors keep their message, anything else is logged and replaced by a
+// generic message carrying the request ID. Syntax, validation and limit
+// errors are passed through.
+func clientErrors(ctx context.Context, errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	for i, err := range errs {
+		// Only resolver errors have a path.
+		if len(err.Path) == 0 {
+			continue
+		}
+
+		cause := originalError(err)
+		var domainErr *models.Error
+		var clientErr *clientError
+		switch {
+		case errors.As(cause, &domainErr):
+			err.Message = domainErr.Message
+			err.Extensions = map[string]any{"code": domainCode(domainErr)}
+			if len(domainErr.Fields) > 0 {
+				fields := make([]map[string]string, 0, len(domainErr.Fields))
+				for _, field := range domainErr.Fields {
+					fields = append(fields, map[string]string{"field": inputField(field.Field), "message": field.Message})
+				}
+				err.Extensions["fields"] = fields
+			}
+		case errors.As(cause, &clientErr):
+			err.Message = clientErr.message
+			err.Extensions = map[string]any{"code": clientErr.code}
+		default:
+			slog.ErrorContext(ctx, "GraphQL resolver failed", slog.Any("path", err.Path), slog.Any("error", cause))
+			err.Message = "Internal server error"
+			err.Extensions = map[string]any{"code": codeInternal, "request_id": logging.RequestID(ctx)}
+		}
+		errs[i] = err
+	}
+	return errs
+}
+
+// originalError unwraps the located errors graphql-go wraps resolver
+// errors in.
+func originalError(err error) error {
+	for {
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			err = e.OriginalError
+		default:
+			return err
+		}
+	}
+}
+
+func domainCode(err *models.Error) string {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return codeNotFound
+	case errors.Is(err, models.ErrForbidden):
+		return codeForbidden
+	case errors.Is(err, models.ErrConflict):
+		return codeConflict
+	case errors.Is(err, models.ErrValidation):
+		return codeBadUserInput
+	default:
+		return codeInternal
+	}
+}
+
+// inputField turns the JSON name of a model field, such as date_time,
+// into its name in EventInput.
+func inputField(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,121 @@
+// Package graphql serves the events API as a GraphQL schema at /graphql.
+// Resolvers go through the same models and authorization checks as the
+// REST handlers, batch their lookups per request and reject queries that
+// nest too deeply or select too much.
+package graphql
+
+import (
+	"REST_API/auth"
+	"REST_API/problem"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer wraps each request in a span; the model operations its
+// resolvers run are traced as child spans.
+var tracer = otel.Tracer("REST_API/graphql")
+
+// Config limits what a single request may ask for. A zero limit is not
+// enforced.
+type Config struct {
+	// MaxDepth is how deeply selections may nest; the fields of the
+	// operation itself are at depth 1.
+	MaxDepth int
+	// MaxComplexity is how many fields a query may resolve. Fields below a
+	// paginated list count once per requested item.
+	MaxComplexity int
+}
+
+func DefaultConfig() Config {
+	return Config{MaxDepth: 10, MaxComplexity: 1000}
+}
+
+// request is a GraphQL-over-HTTP POST body.
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Handler executes GraphQL requests. It has to run after
+// auth.AuthenticateOptional: anonymous callers may read events, and the
+// principal decides which mutations are allowed.
+//
+// Every well-formed request is answered with 200, with failures listed in
+// the errors member of the response. Only bodies that are not a GraphQL
+// request at all get a 400 problem.
+func Handler(config Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req request
+		err := c.ShouldBindJSON(&req)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			problem.Abort(c, http.StatusRequestEntityTooLarge, "Request body must be at most "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+			return
+		}
+		if err != nil || req.Query == "" {
+			problem.Abort(c, http.StatusBadRequest, "Invalid GraphQL request")
+			return
+		}
+
+		ctx := c.Request.Context()
+		if principal, ok := auth.CurrentPrincipal(c); ok {
+			ctx = context.WithValue(ctx, principalKey{}, principal)
+		}
+		ctx = withLoaders(ctx)
+
+		c.JSON(http.StatusOK, execute(ctx, config, req))
+	}
+}
+
+func execute(ctx context.Context, config Config, req request) *gql.Result {
+	ctx, span := tracer.Start(ctx, "GraphQL", trace.WithAttributes(
+		attribute.String("graphql.operation.name", req.OperationName)))
+	defer span.End()
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &gql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := gql.ValidateDocument(&schema, doc, nil)
+	if !validation.IsValid {
+		return &gql.Result{Errors: validation.Errors}
+	}
+
+	// Measured after validation, which rejects fragment cycles.
+	err = checkLimits(doc, req.OperationName, req.Variables, config)
+	if err != nil {
+		return &gql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	result := gql.Execute(gql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	result.Errors = clientErrors(ctx, result.Errors)
+	return result
+}
+
+type principalKey struct{}
+
+func currentPrincipal(ctx context.Context) (*auth.Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*auth.Principal)
+	return principal, ok
+}
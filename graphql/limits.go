@@ -0,0 +1,145 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+)
+
+// Pagination of list fields that take first and after.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// paginated lists the fields whose selections repeat once per item, with
+// the page size used when first is not given.
+var paginated = map[string]int{
+	"events": defaultPageSize,
+}
+
+// checkLimits measures the operation that will be executed and rejects it
+// if it nests deeper than config.MaxDepth or selects more fields than
+// config.MaxComplexity. Introspection fields are not counted, so tools can
+// always load the schema.
+func checkLimits(doc *ast.Document, operationName string, variables map[string]any, config Config) error {
+	m := measurer{fragments: map[string]*ast.FragmentDefinition{}}
+	var operation *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			m.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	// An unknown operation is reported by the executor.
+	if operation == nil {
+		return nil
+	}
+	m.variables = withDefaults(operation, variables)
+
+	depth, complexity := m.measure(operation.SelectionSet, 1)
+	if config.MaxDepth > 0 && depth > config.MaxDepth {
+		return limitError(fmt.Sprintf("Query depth %d exceeds the limit of %d", depth, config.MaxDepth))
+	}
+	if config.MaxComplexity > 0 && complexity > config.MaxComplexity {
+		return limitError(fmt.Sprintf("Query complexity %d exceeds the limit of %d", complexity, config.MaxComplexity))
+	}
+	return nil
+}
+
+// withDefaults adds the default values of the operation's Int variables
+// that were not given.
+func withDefaults(operation *ast.OperationDefinition, variables map[string]any) map[string]any {
+	merged := map[string]any{}
+	for _, definition := range operation.VariableDefinitions {
+		if value, ok := definition.DefaultValue.(*ast.IntValue); ok {
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				merged[definition.Variable.Name.Value] = float64(n)
+			}
+		}
+	}
+	for name, value := range variables {
+		merged[name] = value
+	}
+	return merged
+}
+
+func limitError(message string) error {
+	return gqlerrors.FormattedError{
+		Message:    message,
+		Locations:  []location.SourceLocation{},
+		Extensions: map[string]any{"code": codeQueryTooComplex},
+	}
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+// measure returns the deepest level reached below set, which is at depth,
+// and the number of fields it selects.
+func (m measurer) measure(set *ast.SelectionSet, depth int) (int, int) {
+	if set == nil {
+		return depth - 1, 0
+	}
+
+	maxDepth, complexity := depth, 0
+	add := func(d, c int) {
+		maxDepth = max(maxDepth, d)
+		complexity += c
+	}
+
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			d, c := m.measure(selection.SelectionSet, depth+1)
+			add(max(d, depth), 1+c*m.pageSize(selection))
+		case *ast.InlineFragment:
+			add(m.measure(selection.SelectionSet, depth))
+		case *ast.FragmentSpread:
+			if fragment, ok := m.fragments[selection.Name.Value]; ok {
+				add(m.measure(fragment.SelectionSet, depth))
+			}
+		}
+	}
+	return maxDepth, complexity
+}
+
+// pageSize is how many items a paginated field may return, and 1 for any
+// other field.
+func (m measurer) pageSize(field *ast.Field) int {
+	size, ok := paginated[field.Name.Value]
+	if !ok {
+		return 1
+	}
+
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				size = n
+			}
+		case *ast.Variable:
+			// Variables decoded from JSON are float64.
+			if n, ok := m.variables[value.Name.Value].(float64); ok {
+				size = int(n)
+			}
+		}
+	}
+	return min(max(size, 1), maxPageSize)
+}
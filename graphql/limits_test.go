@@ -0,0 +1,73 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// measureQuery parses query and checks it against config
+func measureQuery(t *testing.T, query string, variables map[string]any, config Config) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return checkLimits(doc, "", variables, config)
+}
+
+func TestCheckLimits_Depth(t *testing.T) {
+	config := Config{MaxDepth: 3}
+
+	assert.NoError(t, measureQuery(t, `{ events { nodes { id } } }`, nil, config))
+
+	err := measureQuery(t, `{ events { nodes { organizer { id } } } }`, nil, config)
+	assert.EqualError(t, err, "Query depth 4 exceeds the limit of 3")
+	assert.Equal(t, codeQueryTooComplex, err.(gqlerrors.FormattedError).Extensions["code"])
+
+	t.Run("Fragments count where they are spread", func(t *testing.T) {
+		query := `
+		query { events { nodes { ...organizer } } }
+		fragment organizer on Event { organizer { id } }`
+		assert.Error(t, measureQuery(t, query, nil, config))
+	})
+
+	t.Run("Introspection is not counted", func(t *testing.T) {
+		query := `{ __schema { types { fields { type { ofType { ofType { name } } } } } } }`
+		assert.NoError(t, measureQuery(t, query, nil, config))
+	})
+}
+
+func TestCheckLimits_Complexity(t *testing.T) {
+	config := Config{MaxComplexity: 100}
+
+	// Everything below events counts once per requested event
+	assert.NoError(t, measureQuery(t, `{ events(first: 10) { nodes { id name } pageInfo { hasNextPage } } }`, nil, config))
+
+	t.Run("Fields repeat per requested item", func(t *testing.T) {
+		err := measureQuery(t, `{ events(first: 50) { nodes { id name } } }`, nil, config)
+		assert.EqualError(t, err, "Query complexity 151 exceeds the limit of 100")
+	})
+
+	t.Run("Page size from a variable", func(t *testing.T) {
+		query := `query($first: Int) { events(first: $first) { nodes { id name } } }`
+		assert.NoError(t, measureQuery(t, query, map[string]any{"first": float64(10)}, config))
+		assert.Error(t, measureQuery(t, query, map[string]any{"first": float64(50)}, config))
+	})
+
+	t.Run("Page size from a variable default", func(t *testing.T) {
+		query := `query($first: Int = 50) { events(first: $first) { nodes { id name } } }`
+		assert.Error(t, measureQuery(t, query, nil, config))
+	})
+
+	t.Run("Default page size", func(t *testing.T) {
+		err := measureQuery(t, `{ events { nodes { id name location description dateTime } } }`, nil, config)
+		assert.EqualError(t, err, "Query complexity 121 exceeds the limit of 100")
+	})
+}
+
+func TestCheckLimits_Disabled(t *testing.T) {
+	query := `{ events(first: 100) { nodes { organizer { id displayName avatarUrl } } } }`
+	assert.NoError(t, measureQuery(t, query, nil, Config{}))
+}
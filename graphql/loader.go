@@ -0,0 +1,108 @@
+package graphql
+
+import (
+	"REST_API/models"
+	"context"
+	"sync"
+)
+
+// loader batches lookups by key, like the DataLoader of graphql-js. load
+// only queues its key and returns a thunk. graphql-go runs thunks after
+// every field at the current depth has been resolved, so the first thunk
+// to run fetches all keys queued until then in one call, and listing N
+// events costs one query per field instead of N.
+//
+// Results are kept for the rest of the request, which is why a loader
+// must never outlive one.
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	queued  []K
+	pending map[K]bool
+	values  map[K]V
+	errs    map[K]error
+}
+
+func newLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		fetch:   fetch,
+		pending: map[K]bool{},
+		values:  map[K]V{},
+		errs:    map[K]error{},
+	}
+}
+
+// load returns a graphql-go thunk resolving to the value of key, or the
+// zero V if fetch did not return one.
+func (l *loader[K, V]) load(ctx context.Context, key K) func() (any, error) {
+	l.mu.Lock()
+	if !l.loaded(key) && !l.pending[key] {
+		l.pending[key] = true
+		l.queued = append(l.queued, key)
+	}
+	l.mu.Unlock()
+
+	return func() (any, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if !l.loaded(key) {
+			l.dispatch(ctx)
+		}
+		return l.values[key], l.errs[key]
+	}
+}
+
+func (l *loader[K, V]) loaded(key K) bool {
+	_, hasValue := l.values[key]
+	_, hasErr := l.errs[key]
+	return hasValue || hasErr
+}
+
+// dispatch fetches every queued key. l.mu must be held.
+func (l *loader[K, V]) dispatch(ctx context.Context) {
+	keys := l.queued
+	l.queued = nil
+	clear(l.pending)
+
+	values, err := l.fetch(ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+			continue
+		}
+		l.values[key] = values[key]
+	}
+}
+
+// loaders are the batched lookups of one request.
+type loaders struct {
+	users     *loader[int64, *models.User]
+	attendees *loader[int64, int]
+	// registered is keyed by event for the current principal.
+	registered *loader[int64, bool]
+}
+
+type loadersKey struct{}
+
+// withLoaders gives the request its own loaders, so nothing is cached
+// across requests or callers.
+func withLoaders(ctx context.Context) context.Context {
+	l := &loaders{
+		users:     newLoader(models.GetUsersByIDs),
+		attendees: newLoader(models.CountRegistrations),
+		registered: newLoader(func(ctx context.Context, eventIDs []int64) (map[int64]bool, error) {
+			principal, ok := currentPrincipal(ctx)
+			if !ok {
+				return map[int64]bool{}, nil
+			}
+			return models.RegisteredFor(ctx, principal.UserID, eventIDs)
+		}),
+	}
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,69 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingLoader doubles its keys and records every batch it fetches
+func countingLoader(batches *[][]int) *loader[int, int] {
+	return newLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		*batches = append(*batches, keys)
+		values := map[int]int{}
+		for _, key := range keys {
+			if key > 0 {
+				values[key] = key * 2
+			}
+		}
+		return values, nil
+	})
+}
+
+func TestLoader_Batches(t *testing.T) {
+	var batches [][]int
+	l := countingLoader(&batches)
+
+	thunks := []func() (any, error){
+		l.load(t.Context(), 1),
+		l.load(t.Context(), 2),
+		l.load(t.Context(), 1),
+		l.load(t.Context(), -1),
+	}
+	assert.Empty(t, batches, "nothing is fetched before a thunk runs")
+
+	var values []any
+	for _, thunk := range thunks {
+		value, err := thunk()
+		assert.NoError(t, err)
+		values = append(values, value)
+	}
+
+	assert.Equal(t, []any{2, 4, 2, 0}, values, "missing keys resolve to the zero value")
+	assert.Equal(t, [][]int{{1, 2, -1}}, batches, "every key is fetched once, in one batch")
+
+	t.Run("Loaded keys are cached", func(t *testing.T) {
+		value, err := l.load(t.Context(), 2)()
+		assert.NoError(t, err)
+		assert.Equal(t, 4, value)
+
+		_, err = l.load(t.Context(), 3)()
+		assert.NoError(t, err)
+		assert.Equal(t, [][]int{{1, 2, -1}, {3}}, batches)
+	})
+}
+
+func TestLoader_Error(t *testing.T) {
+	failure := errors.New("database is down")
+	l := newLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		return nil, failure
+	})
+
+	first, second := l.load(t.Context(), 1), l.load(t.Context(), 2)
+	_, err := first()
+	assert.ErrorIs(t, err, failure)
+	_, err = second()
+	assert.ErrorIs(t, err, failure, "every key of the batch fails")
+}
@@ -0,0 +1,397 @@
+package graphql
+
+import (
+	"REST_API/auth"
+	"REST_API/metrics"
+	"REST_API/models"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	gql "github.com/graphql-go/graphql"
+)
+
+var userType = gql.NewObject(gql.ObjectConfig{
+	Name: "User",
+	Fields: gql.Fields{
+		"id":          {Type: gql.NewNonNull(gql.ID), Resolve: userField(func(u *models.User) any { return u.ID })},
+		"displayName": {Type: gql.NewNonNull(gql.String), Resolve: userField(func(u *models.User) any { return u.DisplayName })},
+		"avatarUrl":   {Type: gql.NewNonNull(gql.String), Resolve: userField(func(u *models.User) any { return u.AvatarURL })},
+		"email": {
+			Type:        gql.String,
+			Description: "Only visible to the user themselves.",
+			Resolve: func(p gql.ResolveParams) (any, error) {
+				user := p.Source.(*models.User)
+				principal, ok := currentPrincipal(p.Context)
+				if !ok || principal.UserID != user.ID {
+					return nil, nil
+				}
+				return user.Email, nil
+			},
+		},
+	},
+})
+
+var eventType = gql.NewObject(gql.ObjectConfig{
+	Name: "Event",
+	Fields: gql.Fields{
+		"id":          {Type: gql.NewNonNull(gql.ID), Resolve: eventField(func(e *models.Event) any { return e.ID })},
+		"name":        {Type: gql.NewNonNull(gql.String), Resolve: eventField(func(e *models.Event) any { return e.Name })},
+		"description": {Type: gql.NewNonNull(gql.String), Resolve: eventField(func(e *models.Event) any { return e.Description })},
+		"location":    {Type: gql.NewNonNull(gql.String), Resolve: eventField(func(e *models.Event) any { return e.Location })},
+		"dateTime":    {Type: gql.NewNonNull(gql.DateTime), Resolve: eventField(func(e *models.Event) any { return e.DateTime })},
+		"createdAt":   {Type: gql.NewNonNull(gql.DateTime), Resolve: eventField(func(e *models.Event) any { return e.CreatedAt })},
+		"updatedAt":   {Type: gql.NewNonNull(gql.DateTime), Resolve: eventField(func(e *models.Event) any { return e.UpdatedAt })},
+		"cancelledAt": {
+			Type:        gql.DateTime,
+			Description: "Set once the event is cancelled because its organizer deleted their account.",
+			Resolve: eventField(func(e *models.Event) any {
+				if e.CancelledAt == nil {
+					return nil
+				}
+				return *e.CancelledAt
+			}),
+		},
+		"organizer": {
+			Type:        userType,
+			Description: "Null if the organizer's account no longer exists.",
+			Resolve: func(p gql.ResolveParams) (any, error) {
+				return loadersFrom(p.Context).users.load(p.Context, p.Source.(*models.Event).UserID), nil
+			},
+		},
+		"attendeeCount": {
+			Type: gql.NewNonNull(gql.Int),
+			Resolve: func(p gql.ResolveParams) (any, error) {
+				return loadersFrom(p.Context).attendees.load(p.Context, p.Source.(*models.Event).ID), nil
+			},
+		},
+		"registered": {
+			Type:        gql.NewNonNull(gql.Boolean),
+			Description: "Whether the caller is registered for the event; always false for anonymous callers.",
+			Resolve: func(p gql.ResolveParams) (any, error) {
+				return loadersFrom(p.Context).registered.load(p.Context, p.Source.(*models.Event).ID), nil
+			},
+		},
+	},
+})
+
+var pageInfoType = gql.NewObject(gql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: gql.Fields{
+		"endCursor":   {Type: gql.String},
+		"hasNextPage": {Type: gql.NewNonNull(gql.Boolean)},
+	},
+})
+
+var eventConnectionType = gql.NewObject(gql.ObjectConfig{
+	Name: "EventConnection",
+	Fields: gql.Fields{
+		"nodes":    {Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(eventType)))},
+		"pageInfo": {Type: gql.NewNonNull(pageInfoType)},
+	},
+})
+
+var eventInputType = gql.NewInputObject(gql.InputObjectConfig{
+	Name: "EventInput",
+	Fields: gql.InputObjectConfigFieldMap{
+		"name":        {Type: gql.NewNonNull(gql.String)},
+		"description": {Type: gql.NewNonNull(gql.String)},
+		"location":    {Type: gql.NewNonNull(gql.String)},
+		"dateTime":    {Type: gql.NewNonNull(gql.DateTime)},
+	},
+})
+
+var queryType = gql.NewObject(gql.ObjectConfig{
+	Name: "Query",
+	Fields: gql.Fields{
+		"events": {
+			Type:        gql.NewNonNull(eventConnectionType),
+			Description: fmt.Sprintf("Events in creation order, at most %d per page.", maxPageSize),
+			Args: gql.FieldConfigArgument{
+				"first": {Type: gql.Int, DefaultValue: defaultPageSize},
+				"after": {Type: gql.String, Description: "The endCursor of the previous page."},
+			},
+			Resolve: resolveEvents,
+		},
+		"event": {
+			Type:    eventType,
+			Args:    gql.FieldConfigArgument{"id": {Type: gql.NewNonNull(gql.ID)}},
+			Resolve: resolveEvent,
+		},
+		"me": {
+			Type:    userType,
+			Resolve: resolveMe,
+		},
+	},
+})
+
+var mutationType = gql.NewObject(gql.ObjectConfig{
+	Name: "Mutation",
+	Fields: gql.Fields{
+		"createEvent": {
+			Type:    eventType,
+			Args:    gql.FieldConfigArgument{"input": {Type: gql.NewNonNull(eventInputType)}},
+			Resolve: resolveCreateEvent,
+		},
+		"updateEvent": {
+			Type: eventType,
+			Args: gql.FieldConfigArgument{
+				"id":    {Type: gql.NewNonNull(gql.ID)},
+				"input": {Type: gql.NewNonNull(eventInputType)},
+			},
+			Resolve: resolveUpdateEvent,
+		},
+		"registerForEvent": {
+			Type:    eventType,
+			Args:    gql.FieldConfigArgument{"id": {Type: gql.NewNonNull(gql.ID)}},
+			Resolve: resolveRegisterForEvent,
+		},
+		"unregisterFromEvent": {
+			Type:    eventType,
+			Args:    gql.FieldConfigArgument{"id": {Type: gql.NewNonNull(gql.ID)}},
+			Resolve: resolveUnregisterFromEvent,
+		},
+	},
+})
+
+var schema = mustSchema(gql.SchemaConfig{Query: queryType, Mutation: mutationType})
+
+func mustSchema(config gql.SchemaConfig) gql.Schema {
+	s, err := gql.NewSchema(config)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func userField(get func(*models.User) any) gql.FieldResolveFn {
+	return func(p gql.ResolveParams) (any, error) {
+		return get(p.Source.(*models.User)), nil
+	}
+}
+
+func eventField(get func(*models.Event) any) gql.FieldResolveFn {
+	return func(p gql.ResolveParams) (any, error) {
+		return get(p.Source.(*models.Event)), nil
+	}
+}
+
+// connection and pageInfo are resolved by graphql-go, which matches
+// their field names to the schema.
+type connection struct {
+	Nodes    []*models.Event
+	PageInfo pageInfo
+}
+
+type pageInfo struct {
+	EndCursor   *string
+	HasNextPage bool
+}
+
+func resolveEvents(p gql.ResolveParams) (any, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return nil, &clientError{codeBadUserInput, fmt.Sprintf("first must be between 1 and %d", maxPageSize)}
+	}
+
+	var afterID int64
+	if after, ok := p.Args["after"].(string); ok {
+		var err error
+		afterID, err = decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// One extra event tells whether there is another page.
+	events, err := models.ListEvents(p.Context, afterID, first+1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := connection{Nodes: []*models.Event{}}
+	for i := range events[:min(len(events), first)] {
+		result.Nodes = append(result.Nodes, &events[i])
+	}
+	result.PageInfo.HasNextPage = len(events) > first
+	if len(result.Nodes) > 0 {
+		cursor := encodeCursor(result.Nodes[len(result.Nodes)-1].ID)
+		result.PageInfo.EndCursor = &cursor
+	}
+	return result, nil
+}
+
+func resolveEvent(p gql.ResolveParams) (any, error) {
+	id, err := parseEventID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	return models.GetEventByID(p.Context, id)
+}
+
+func resolveMe(p gql.ResolveParams) (any, error) {
+	principal, err := requireUserSession(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	return models.GetUserByID(p.Context, principal.UserID)
+}
+
+func resolveCreateEvent(p gql.ResolveParams) (any, error) {
+	principal, err := requireScope(p.Context, auth.ScopeEventsWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	event := eventFromInput(p.Args["input"])
+	event.UserID = principal.UserID
+	err = event.Save(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func resolveUpdateEvent(p gql.ResolveParams) (any, error) {
+	principal, err := requireScope(p.Context, auth.ScopeEventsWrite)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseEventID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := models.GetEventByID(p.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	err = event.CheckOwner(principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedEvent := eventFromInput(p.Args["input"])
+	updatedEvent.ID = id
+	updatedEvent.UserID = event.UserID
+	updatedEvent.CreatedAt = event.CreatedAt
+	err = updatedEvent.Update(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	return updatedEvent, nil
+}
+
+func resolveRegisterForEvent(p gql.ResolveParams) (any, error) {
+	principal, err := requireScope(p.Context, auth.ScopeRegistrationsWrite)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseEventID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := models.GetEventByID(p.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	err = event.Register(p.Context, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordRegistration(event.ID, true)
+	return event, nil
+}
+
+func resolveUnregisterFromEvent(p gql.ResolveParams) (any, error) {
+	principal, err := requireScope(p.Context, auth.ScopeRegistrationsWrite)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseEventID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := models.GetEventByID(p.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	err = event.Unregister(p.Context, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordRegistration(event.ID, false)
+	return event, nil
+}
+
+// requireScope is auth.RequireScope for resolvers.
+func requireScope(ctx context.Context, scope string) (*auth.Principal, error) {
+	principal, ok := currentPrincipal(ctx)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+	if !principal.HasScope(scope) {
+		return nil, &clientError{codeForbidden, "API key lacks scope " + scope}
+	}
+	return principal, nil
+}
+
+// requireUserSession is auth.RequireUserSession for resolvers.
+func requireUserSession(ctx context.Context) (*auth.Principal, error) {
+	principal, ok := currentPrincipal(ctx)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+	if principal.Method != auth.MethodToken {
+		return nil, errUserSession
+	}
+	return principal, nil
+}
+
+func eventFromInput(value any) *models.Event {
+	input := value.(map[string]any)
+	event := &models.Event{}
+	event.Name, _ = input["name"].(string)
+	event.Description, _ = input["description"].(string)
+	event.Location, _ = input["location"].(string)
+	event.DateTime, _ = input["dateTime"].(time.Time)
+	return event
+}
+
+func parseEventID(value any) (int64, error) {
+	s, _ := value.(string)
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errInvalidEventID
+	}
+	return id, nil
+}
+
+// Cursors are opaque to clients so pagination can change without
+// breaking them.
+const cursorPrefix = "event:"
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	idText, found := strings.CutPrefix(string(decoded), cursorPrefix)
+	if !found {
+		return 0, errInvalidCursor
+	}
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	return id, nil
+}
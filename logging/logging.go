@@ -0,0 +1,167 @@
+// Package logging configures structured logging with log/slog and provides
+// the request ID and access log middleware.
+package logging
+
+import (
+	"REST_API/problem"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// Setup installs the default slog logger. format is "json" or "text" and
+// level one of debug, info, warn or error.
+func Setup(w io.Writer, format, level string) error {
+	var logLevel slog.Level
+	err := logLevel.UnmarshalText([]byte(level))
+	if err != nil {
+		return fmt.Errorf("logging: %w", err)
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return fmt.Errorf("logging: unknown format %q", format)
+	}
+
+	slog.SetDefault(slog.New(NewContextHandler(handler)))
+	return nil
+}
+
+// ContextHandler adds the request ID and trace ID stored in the context to
+// every record logged with one of the slog ...Context functions.
+type ContextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(handler slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: handler}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID returns ctx carrying id as its request ID, or a new ID if
+// id is not well-formed, together with the ID used.
+func WithRequestID(ctx context.Context, id string) (context.Context, string) {
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	return context.WithValue(ctx, requestIDKey{}, id), id
+}
+
+// RequestIDMiddleware reuses a well-formed X-Request-ID from the caller,
+// or generates one, and echoes it in the response.
+func RequestIDMiddleware(c *gin.Context) {
+	ctx, id := WithRequestID(c.Request.Context(), c.GetHeader(RequestIDHeader))
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Header(RequestIDHeader, id)
+	c.Next()
+}
+
+// validRequestID keeps caller-supplied IDs short and printable so they
+// cannot be used to forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	return !strings.ContainsFunc(id, func(r rune) bool {
+		return r <= ' ' || r > '~'
+	})
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// AccessLog logs one record per request with its route, status, latency
+// and, once authenticated, the user ID.
+func AccessLog(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", c.Request.Method),
+		slog.String("route", route),
+		slog.String("path", c.Request.URL.Path),
+		slog.Int("status", c.Writer.Status()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("client_ip", c.ClientIP()),
+	}
+	if userId := c.GetInt64("userId"); userId != 0 {
+		attrs = append(attrs, slog.Int64("user_id", userId))
+	}
+
+	level := slog.LevelInfo
+	if c.Writer.Status() >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+}
+
+// Recovery turns a panic into a 500 response and logs it with its stack.
+func Recovery(c *gin.Context) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+
+		slog.ErrorContext(c.Request.Context(), "panic",
+			slog.Any("error", recovered),
+			slog.String("stack", string(debug.Stack())))
+		problem.Abort(c, http.StatusInternalServerError, "Internal server error")
+	}()
+	c.Next()
+}
@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// Sender delivers a plain-text message to a single recipient.
+type Sender interface {
+	Send(to, subject, body string) error
+}
+
+// LogSender writes messages to the default logger instead of delivering
+// them. It is the default until a real transport is configured.
+type LogSender struct{}
+
+func (LogSender) Send(to, subject, body string) error {
+	slog.Info("mail", "to", to, "subject", subject, "body", body)
+	return nil
+}
+
+var (
+	senderMu      sync.RWMutex
+	defaultSender Sender = LogSender{}
+)
+
+func SetSender(s Sender) {
+	senderMu.Lock()
+	defer senderMu.Unlock()
+	defaultSender = s
+}
+
+func Send(to, subject, body string) error {
+	senderMu.RLock()
+	s := defaultSender
+	senderMu.RUnlock()
+	return s.Send(to, subject, body)
+}
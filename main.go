@@ -1,20 +1,383 @@
 package main
 
 import (
+	"REST_API/auth"
+	"REST_API/compress"
+	"REST_API/config"
 	"REST_API/db"
+	"REST_API/graphql"
+	"REST_API/logging"
+	"REST_API/metrics"
+	"REST_API/models"
+	"REST_API/oidc"
+	"REST_API/ratelimit"
 	"REST_API/routes"
+	"REST_API/rpc"
+	"REST_API/security"
+	"REST_API/tlsconfig"
+	"REST_API/tracing"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	db.InitDB()
-	server := gin.Default()
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	err = logging.Setup(os.Stdout, cfg.Logging.Format, cfg.Logging.Level)
+	if err != nil {
+		fatal("Could not set up logging", err)
+	}
+
+	slog.Info("Effective configuration", "config", cfg.Redacted())
+	if cfg.Auth.JWTSecret == config.DefaultJWTSecret {
+		slog.Warn("Using the default JWT secret; set JWT_SECRET in production")
+	}
+
+	db.InitDB(db.Config{
+		Path:         cfg.Database.Path,
+		MaxOpenConns: cfg.Database.MaxOpenConns,
+		MaxIdleConns: cfg.Database.MaxIdleConns,
+	})
+	auth.Configure(cfg.Auth.JWTSecret, time.Duration(cfg.Auth.TokenTTL))
+	auth.SetPasswordHasher(passwordHasher(cfg.Auth))
+	models.ConfigureEventCache(cfg.Database.EventCacheSize, time.Duration(cfg.Database.EventCacheTTL))
+	metrics.ConfigureEventSeries(cfg.Metrics.MaxEventSeries)
+	registerOIDCProviders(cfg.OIDC.Providers)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Could not set up tracing", err)
+	}
+
+	// Logging and panic recovery are installed by RegisterRoutes.
+	server := gin.New()
+	// gin trusts every proxy by default, which lets any client pick its own
+	// ClientIP with X-Forwarded-For. Only the configured proxies are
+	// trusted; with none the peer address is used.
+	err = server.SetTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		fatal("Invalid trusted proxies", err)
+	}
+
+	routeConfig := routes.Config{
+		OIDCStateTTL:   time.Duration(cfg.OIDC.StateTTL),
+		IdempotencyTTL: time.Duration(cfg.Server.IdempotencyTTL),
+		MaxBodyBytes:   int64(cfg.Server.MaxBodyBytes),
+		Compression: compress.Config{
+			Enabled: cfg.HTTP.Compression,
+			MinSize: cfg.HTTP.CompressionMinSize,
+		},
+		CacheMaxAge:       time.Duration(cfg.HTTP.CacheMaxAge),
+		UnversionedRoutes: cfg.HTTP.UnversionedRoutes,
+		UnversionedSunset: time.Time(cfg.HTTP.UnversionedSunset),
+		CORS: security.CORSConfig{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           time.Duration(cfg.CORS.MaxAge),
+		},
+		SecurityHeaders: security.HeadersConfig{
+			HSTSMaxAge: time.Duration(cfg.Security.HSTSMaxAge),
+		},
+		GraphQL: graphql.Config{
+			MaxDepth:      cfg.GraphQL.MaxDepth,
+			MaxComplexity: cfg.GraphQL.MaxComplexity,
+		},
+		MetricsToken: cfg.Metrics.Token,
+	}
+	for _, a := range cfg.TLS.ServiceAccounts {
+		routeConfig.ServiceAccounts = append(routeConfig.ServiceAccounts, models.ServiceAccount{
+			Subject: a.Subject,
+			Email:   a.Email,
+			Scopes:  a.Scopes,
+		})
+	}
+	// gRPC shares the buckets, so each caller has one budget per group.
+	routeConfig.RateLimitStore = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Enabled {
+		routeConfig.RateLimits = routes.RateLimits{
+			Public:  rateLimitPolicy(cfg.RateLimit.Public),
+			Auth:    rateLimitPolicy(cfg.RateLimit.Auth),
+			Events:  rateLimitPolicy(cfg.RateLimit.Events),
+			Account: rateLimitPolicy(cfg.RateLimit.Account),
+			GraphQL: rateLimitPolicy(cfg.RateLimit.GraphQL),
+		}
+	}
+	routes.RegisterRoutes(server, routeConfig)
+
+	httpServer := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           server.Handler(),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	servers := []listener{httpListener(httpServer)}
+	if cfg.TLS.Enabled() {
+		httpServer.TLSConfig, err = newTLSConfig(cfg.TLS)
+		if err != nil {
+			fatal("Invalid TLS configuration", err)
+		}
+
+		if cfg.TLS.RedirectAddr != "" {
+			servers = append(servers, httpListener(&http.Server{
+				Addr:              cfg.TLS.RedirectAddr,
+				Handler:           tlsconfig.RedirectHandler(cfg.Server.Addr),
+				ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+				IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+				MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+				ErrorLog:          httpServer.ErrorLog,
+			}))
+		}
+	}
+	if cfg.GRPC.Addr != "" {
+		// The gRPC server shares the HTTPS certificate, but client
+		// certificates are not accepted there.
+		var tlsConfig *tls.Config
+		if httpServer.TLSConfig != nil {
+			tlsConfig = httpServer.TLSConfig.Clone()
+			tlsConfig.ClientAuth = tls.NoClientCert
+			tlsConfig.ClientCAs = nil
+		} else {
+			slog.Warn("gRPC is served without TLS; set tls.cert_file or keep grpc.addr on a trusted network", "addr", cfg.GRPC.Addr)
+		}
+		rpcServer := rpc.New(rpc.Config{
+			TLS: tlsConfig,
+			RateLimits: rpc.RateLimits{
+				Public: routeConfig.RateLimits.Public,
+				Auth:   routeConfig.RateLimits.Auth,
+				Events: routeConfig.RateLimits.Events,
+			},
+			RateLimitStore: routeConfig.RateLimitStore,
+		})
+		servers = append(servers, grpcListener(rpcServer, cfg.GRPC.Addr))
+	}
+
+	err = run(context.Background(), servers, time.Duration(cfg.Server.DrainDelay), time.Duration(cfg.Server.ShutdownTimeout))
+
+	// Flush spans of the last requests; the collector may be gone, which
+	// must not hide the server's own error.
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	traceErr := shutdownTracing(flushCtx)
+	cancel()
+	if traceErr != nil {
+		slog.Error("Could not flush traces", "error", traceErr)
+	}
+
+	if err != nil {
+		fatal("Server stopped", err)
+	}
+}
+
+// listener is a server that run starts and stops: the HTTP servers and
+// the gRPC server.
+type listener struct {
+	// serve blocks until the server fails, or returns
+	// http.ErrServerClosed once shutdown has started.
+	serve func() error
+	// drain, if set, reports the server as not ready before shutdown.
+	drain func()
+	// shutdown waits for in-flight requests until ctx is done and then
+	// closes the remaining connections.
+	shutdown func(ctx context.Context) error
+}
+
+func httpListener(server *http.Server) listener {
+	return listener{
+		serve: func() error { return serve(server) },
+		shutdown: func(ctx context.Context) error {
+			err := server.Shutdown(ctx)
+			if err != nil {
+				_ = server.Close()
+			}
+			return err
+		},
+	}
+}
 
-	routes.RegisterRoutes(server)
+func grpcListener(server *rpc.Server, addr string) listener {
+	return listener{
+		serve: func() error {
+			lis, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fmt.Errorf("could not start gRPC server on %s: %w", addr, err)
+			}
+			slog.Info("Listening", "addr", addr, "protocol", "grpc")
+			err = server.Serve(lis)
+			if err == nil {
+				// Serve returns nil once the server is stopped.
+				return http.ErrServerClosed
+			}
+			return err
+		},
+		drain:    server.Drain,
+		shutdown: server.Shutdown,
+	}
+}
+
+// run serves until a listener fails, ctx is done or SIGINT/SIGTERM
+// arrives. On shutdown /readyz reports 503 for drainDelay while the
+// listeners stay open, so load balancers stop sending requests before
+// connections are refused. In-flight requests then get shutdownTimeout to
+// finish before the database is closed.
+func run(ctx context.Context, servers []listener, drainDelay, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, len(servers))
+	for _, server := range servers {
+		go func() {
+			serveErr <- server.serve()
+		}()
+	}
+
+	// A listener that fails stops the others too.
+	running := len(servers)
+	var err error
+	select {
+	case err = <-serveErr:
+		running--
+		slog.Error("Server failed, shutting down", "error", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	routes.SetDraining(true)
+	for _, server := range servers {
+		if server.drain != nil {
+			server.drain()
+		}
+	}
+	if drainDelay > 0 {
+		slog.Info("Shutting down, reporting not ready", "delay", drainDelay)
+		time.Sleep(drainDelay)
+	}
+
+	slog.Info("Shutting down, draining requests", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		shutdownErr := server.shutdown(shutdownCtx)
+		if shutdownErr != nil {
+			err = errors.Join(err, fmt.Errorf("requests did not finish in time: %w", shutdownErr))
+		}
+	}
+
+	// Servers return ErrServerClosed as soon as Shutdown starts.
+	for range running {
+		if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) {
+			err = errors.Join(err, serveErr)
+		}
+	}
+
+	closeErr := db.Close()
+	if closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("could not close database: %w", closeErr))
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Server stopped")
+	return nil
+}
+
+// serve listens on server.Addr, with TLS when the server has a TLS config.
+func serve(server *http.Server) error {
+	var err error
+	if server.TLSConfig != nil {
+		slog.Info("Listening", "addr", server.Addr, "tls", true)
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		slog.Info("Listening", "addr", server.Addr)
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return fmt.Errorf("could not start server on %s: %w", server.Addr, err)
+}
+
+// fatal logs err and exits. Deferred functions do not run.
+func fatal(message string, err error) {
+	slog.Error(message, "error", err)
+	os.Exit(1)
+}
 
-	err := server.Run(":8080")
+// registerOIDCProviders discovers the configured providers. A provider
+// that cannot be reached is skipped so password login keeps working.
+func registerOIDCProviders(providers []config.OIDCProvider) {
+	for _, p := range providers {
+		provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		}, nil)
+		if err != nil {
+			slog.Warn("Skipping login provider", "provider", p.Name, "error", err)
+			continue
+		}
+		oidc.Register(provider)
+	}
+}
+
+// passwordHasher builds the hasher for new password hashes. The values
+// were checked by config.Validate.
+func passwordHasher(cfg config.AuthConfig) auth.Hasher {
+	if cfg.Hasher == "bcrypt" {
+		return auth.BcryptHasher{Cost: cfg.BcryptCost}
+	}
+	hasher := auth.DefaultArgon2idHasher()
+	hasher.Time = uint32(cfg.Argon2id.Time)
+	hasher.Memory = uint32(cfg.Argon2id.Memory)
+	hasher.Threads = uint8(cfg.Argon2id.Threads)
+	return hasher
+}
+
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	minVersion, err := tlsconfig.ParseVersion(cfg.MinVersion)
 	if err != nil {
-		return
+		return nil, err
+	}
+	return tlsconfig.New(tlsconfig.Config{
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		MinVersion:   minVersion,
+		ClientCAFile: cfg.ClientCAFile,
+	})
+}
+
+func rateLimitPolicy(policy config.RateLimitPolicy) ratelimit.Policy {
+	return ratelimit.Policy{
+		Anonymous: ratelimit.Rate(policy.Anonymous),
+		User:      ratelimit.Rate(policy.User),
+		APIKey:    ratelimit.Rate(policy.APIKey),
 	}
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"REST_API/auth"
+	"REST_API/config"
+	"REST_API/routes"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_DrainDelay(t *testing.T) {
+	testDB := routes.SetupTestDB(t)
+	defer testDB.Cleanup()
+	defer routes.SetDraining(false)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	httpServer := &http.Server{Handler: routes.SetupTestRouter()}
+	server := httpListener(httpServer)
+	server.serve = func() error { return httpServer.Serve(lis) }
+	readyz := "http://" + lis.Addr().String() + "/readyz"
+
+	ctx, cancel := context.WithCancel(t.Context())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- run(ctx, []listener{server}, 500*time.Millisecond, 5*time.Second)
+	}()
+
+	client := &http.Client{Timeout: time.Second}
+	assert.Eventually(t, func() bool {
+		resp, err := client.Get(readyz)
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 10*time.Millisecond, "ready while serving")
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		resp, err := client.Get(readyz)
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusServiceUnavailable
+	}, 400*time.Millisecond, 10*time.Millisecond, "not ready while the listener is still open")
+
+	assert.NoError(t, <-stopped)
+	_, err = client.Get(readyz)
+	assert.Error(t, err, "the listener is closed after the delay")
+}
+
+func TestPasswordHasher(t *testing.T) {
+	cfg := config.Default().Auth
+	cfg.Argon2id.Memory = 64 * 1024
+	assert.Equal(t, auth.Argon2idHasher{Time: 2, Memory: 64 * 1024, Threads: 1, SaltLen: 16, KeyLen: 32}, passwordHasher(cfg))
+
+	cfg.Hasher = "bcrypt"
+	cfg.BcryptCost = 12
+	assert.Equal(t, auth.BcryptHasher{Cost: 12}, passwordHasher(cfg))
+}
@@ -0,0 +1,247 @@
+// Package metrics exposes Prometheus metrics for HTTP traffic, logins,
+// event registrations, the event cache and the database connection pool.
+package metrics
+
+import (
+	"REST_API/db"
+	"REST_API/models"
+	"container/list"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "rest_api"
+
+// Registry holds every metric of the service. A dedicated registry keeps
+// tests and repeated route registration from clashing with the global one.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by method and result.",
+	}, []string{"method", "result"})
+
+	registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_registrations_total",
+		Help:      "Event registrations and unregistrations.",
+	}, []string{"action"})
+
+	eventRegistrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_registrations_by_event_total",
+		Help:      "Event registrations and unregistrations of the most recently active events.",
+	}, []string{"event_id", "action"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		logins,
+		registrations,
+		eventRegistrations,
+		dbStatsCollector{},
+		eventCacheCollector{},
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records the count and latency of every request. Requests
+// are labelled with the route template, such as /events/:id, so the
+// number of series does not grow with IDs in the path.
+func Middleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+}
+
+// Login methods
+const (
+	LoginPassword = "password"
+	LoginOIDC     = "oidc"
+)
+
+// RecordLogin counts a login attempt.
+func RecordLogin(method string, success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	logins.WithLabelValues(method, result).Inc()
+}
+
+// RecordRegistration counts a registration for an event, or an
+// unregistration when registered is false.
+func RecordRegistration(eventId int64, registered bool) {
+	action := "unregister"
+	if registered {
+		action = "register"
+	}
+	registrations.WithLabelValues(action).Inc()
+	eventSeries.record(eventId, action)
+}
+
+// ConfigureEventSeries sets how many events keep their own registration
+// series; 0 turns the per-event series off. The default is 1000.
+func ConfigureEventSeries(max int) {
+	eventSeries.configure(max)
+}
+
+// eventSeries bounds the series of eventRegistrations. Every new event
+// would add series, so only the events with the most recent registrations
+// keep theirs. An evicted event that becomes active again starts from
+// zero, which Prometheus treats as a counter reset.
+var eventSeries = &boundedEventSeries{
+	max:      1000,
+	order:    list.New(),
+	elements: map[int64]*list.Element{},
+}
+
+type boundedEventSeries struct {
+	mu  sync.Mutex
+	max int
+	// order holds event IDs, the most recently active first.
+	order    *list.List
+	elements map[int64]*list.Element
+}
+
+func (s *boundedEventSeries) record(eventId int64, action string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.max <= 0 {
+		return
+	}
+
+	if element, ok := s.elements[eventId]; ok {
+		s.order.MoveToFront(element)
+	} else {
+		s.elements[eventId] = s.order.PushFront(eventId)
+		s.evict()
+	}
+	// Counted with mu held, so an eviction cannot remove the series
+	// between the lookup above and the increment.
+	eventRegistrations.WithLabelValues(strconv.FormatInt(eventId, 10), action).Inc()
+}
+
+func (s *boundedEventSeries) configure(max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.max = max
+	s.evict()
+}
+
+// evict must be called with mu held.
+func (s *boundedEventSeries) evict() {
+	for s.order.Len() > max(s.max, 0) {
+		eventId := s.order.Remove(s.order.Back()).(int64)
+		delete(s.elements, eventId)
+		eventRegistrations.DeletePartialMatch(prometheus.Labels{"event_id": strconv.FormatInt(eventId, 10)})
+	}
+}
+
+// dbStatsCollector reads sql.DBStats from db.DB on every scrape, so it
+// follows the connection pool even when db.DB is replaced.
+type dbStatsCollector struct{}
+
+var (
+	dbOpenDesc = prometheus.NewDesc(namespace+"_db_open_connections",
+		"Established connections, both in use and idle.", nil, nil)
+	dbInUseDesc = prometheus.NewDesc(namespace+"_db_in_use_connections",
+		"Connections currently in use.", nil, nil)
+	dbIdleDesc = prometheus.NewDesc(namespace+"_db_idle_connections",
+		"Idle connections.", nil, nil)
+	dbWaitCountDesc = prometheus.NewDesc(namespace+"_db_wait_count_total",
+		"Total number of times a connection had to be waited for.", nil, nil)
+	dbWaitDurationDesc = prometheus.NewDesc(namespace+"_db_wait_duration_seconds_total",
+		"Total time spent waiting for a connection.", nil, nil)
+)
+
+func (dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbOpenDesc
+	ch <- dbInUseDesc
+	ch <- dbIdleDesc
+	ch <- dbWaitCountDesc
+	ch <- dbWaitDurationDesc
+}
+
+func (dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	if db.DB == nil {
+		return
+	}
+
+	stats := db.DB.Stats()
+	ch <- prometheus.MustNewConstMetric(dbOpenDesc, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUseDesc, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdleDesc, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
+
+// eventCacheCollector reads models.EventCacheStats on every scrape.
+type eventCacheCollector struct{}
+
+var (
+	cacheHitsDesc = prometheus.NewDesc(namespace+"_event_cache_hits_total",
+		"Event lookups served from the cache.", nil, nil)
+	cacheMissesDesc = prometheus.NewDesc(namespace+"_event_cache_misses_total",
+		"Event lookups not found in the cache.", nil, nil)
+	cacheLoadsDesc = prometheus.NewDesc(namespace+"_event_cache_loads_total",
+		"Event lookups that went to the database; concurrent misses share one load.", nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc(namespace+"_event_cache_evictions_total",
+		"Events evicted to stay within the cache size.", nil, nil)
+	cacheEntriesDesc = prometheus.NewDesc(namespace+"_event_cache_entries",
+		"Events currently cached.", nil, nil)
+)
+
+func (eventCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheLoadsDesc
+	ch <- cacheEvictionsDesc
+	ch <- cacheEntriesDesc
+}
+
+func (eventCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := models.EventCacheStats()
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheLoadsDesc, prometheus.CounterValue, float64(stats.Loads))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+}
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"REST_API/db"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// EventPolicy decides what happens to the events a user owns when the
+// account is deleted.
+type EventPolicy string
+
+const (
+	// DeleteEvents removes the events together with every registration for
+	// them, including other users' registrations.
+	DeleteEvents EventPolicy = "delete"
+	// TransferEvents hands the events over to another existing user;
+	// attendees stay registered.
+	TransferEvents EventPolicy = "transfer"
+	// CancelEvents keeps the events, marked cancelled and without an owner,
+	// so attendees can still see them; attendees stay registered.
+	CancelEvents EventPolicy = "cancel"
+)
+
+var ErrTransferTarget = invalid("Invalid transfer target")
+
+type Registration struct {
+	ID            int64     `json:"id"`
+	EventID       int64     `json:"event_id"`
+	EventName     string    `json:"event_name"`
+	EventDateTime time.Time `json:"event_date_time"`
+}
+
+// UserExport is everything we store about a user, as returned by a data
+// subject access request.
+type UserExport struct {
+	ExportedAt    time.Time      `json:"exported_at"`
+	User          User           `json:"user"`
+	Events        []Event        `json:"events"`
+	Registrations []Registration `json:"registrations"`
+	APIKeys       []APIKey       `json:"api_keys"`
+	Identities    []Identity     `json:"identities"`
+}
+
+func (u *User) Export(ctx context.Context) (*UserExport, error) {
+	ctx, span := tracer.Start(ctx, "User.Export")
+	defer span.End()
+
+	user, err := GetUserByID(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &UserExport{
+		ExportedAt:    time.Now().UTC(),
+		User:          *user,
+		Events:        []Event{},
+		Registrations: []Registration{},
+	}
+
+	rows, err := db.DB.QueryContext(ctx, `SELECT `+eventColumns+` FROM events WHERE user_id = ? ORDER BY id`, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		export.Events = append(export.Events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	registrationRows, err := db.DB.QueryContext(ctx, `
+	SELECT r.id, r.event_id, e.name, e.date_time
+	FROM registrations r JOIN events e ON e.id = r.event_id
+	WHERE r.user_id = ? ORDER BY r.id`, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = registrationRows.Close() }()
+
+	for registrationRows.Next() {
+		var registration Registration
+		err := registrationRows.Scan(
+			&registration.ID,
+			&registration.EventID,
+			&registration.EventName,
+			&registration.EventDateTime)
+		if err != nil {
+			return nil, err
+		}
+		export.Registrations = append(export.Registrations, registration)
+	}
+
+	if err = registrationRows.Err(); err != nil {
+		return nil, err
+	}
+
+	export.APIKeys, err = GetAPIKeysByUser(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	export.Identities, err = GetIdentitiesByUser(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// DeleteAccount removes the user and everything that references them in a
+// single transaction. Owned events are handled according to policy;
+// transferTo is the email of the receiving user for TransferEvents.
+func (u *User) DeleteAccount(ctx context.Context, currentPassword string, policy EventPolicy, transferTo string) error {
+	ctx, span := tracer.Start(ctx, "User.DeleteAccount")
+	defer span.End()
+
+	err := u.checkPassword(ctx, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Read before the changes, to announce them once committed.
+	owned, err := queryIDs(ctx, tx, "SELECT id FROM events WHERE user_id = ?", u.ID)
+	if err != nil {
+		return err
+	}
+	attended, err := queryIDs(ctx, tx, "SELECT event_id FROM registrations WHERE user_id = ?", u.ID)
+	if err != nil {
+		return err
+	}
+
+	switch policy {
+	case TransferEvents:
+		var targetId int64
+		err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE email = ?", transferTo).Scan(&targetId)
+		if errors.Is(err, sql.ErrNoRows) || targetId == u.ID {
+			return ErrTransferTarget
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE events SET user_id = ?, updated_at = ? WHERE user_id = ?", targetId, time.Now().UTC(), u.ID)
+		if err != nil {
+			return err
+		}
+	case CancelEvents:
+		now := time.Now().UTC()
+		_, err = tx.ExecContext(ctx, "UPDATE events SET user_id = NULL, cancelled_at = ?, updated_at = ? WHERE user_id = ?", now, now, u.ID)
+		if err != nil {
+			return err
+		}
+	case DeleteEvents, "":
+		_, err = tx.ExecContext(ctx, `
+		DELETE FROM registrations
+		WHERE event_id IN (SELECT id FROM events WHERE user_id = ?)`, u.ID)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, "DELETE FROM events WHERE user_id = ?", u.ID)
+		if err != nil {
+			return err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			err = recordEventDeletion(ctx, tx)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return invalid("Unknown event policy")
+	}
+
+	statements := []string{
+		"DELETE FROM registrations WHERE user_id = ?",
+		"DELETE FROM email_verifications WHERE user_id = ?",
+		"DELETE FROM api_keys WHERE user_id = ?",
+		"DELETE FROM user_identities WHERE user_id = ?",
+		"DELETE FROM idempotency_keys WHERE user_id = ?",
+		"DELETE FROM users WHERE id = ?",
+	}
+	for _, statement := range statements {
+		_, err = tx.ExecContext(ctx, statement, u.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// Events were transferred, cancelled or deleted.
+	eventCache.Clear()
+	publishAccountDeletion(ctx, policy, owned, attended)
+	return nil
+}
+
+// publishAccountDeletion announces what happened to the events the user
+// owned, and the attendee counts of the events they were registered for.
+// The deletion is already committed, so an event that cannot be read only
+// skips its announcement.
+func publishAccountDeletion(ctx context.Context, policy EventPolicy, owned, attended []int64) {
+	deleted := make(map[int64]bool)
+	for _, id := range owned {
+		if policy == DeleteEvents || policy == "" {
+			deleted[id] = true
+			publishEventChange(EventDeleted, Event{ID: id})
+			continue
+		}
+		event, err := GetEventByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		publishEventChange(EventUpdated, *event)
+	}
+
+	for _, id := range attended {
+		if deleted[id] {
+			continue
+		}
+		event, err := GetEventByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		event.publishRegistrations(ctx)
+	}
+}
+
+func queryIDs(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"REST_API/db"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+const apiKeyPrefix = "rak_"
+
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKey is the stored metadata of a personal API key. The key itself is
+// only returned once by Save; afterwards just its SHA-256 hash is kept.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Save generates a new key for k.UserID and returns it in plain text.
+func (k *APIKey) Save(ctx context.Context) (string, error) {
+	ctx, span := tracer.Start(ctx, "APIKey.Save")
+	defer span.End()
+
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	k.Prefix = key[:len(apiKeyPrefix)+6]
+	k.CreatedAt = time.Now().UTC()
+
+	query := `
+	INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := db.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	result, err := stmt.ExecContext(ctx, k.UserID, k.Name, k.Prefix, hashToken(key),
+		strings.Join(k.Scopes, " "), k.ExpiresAt, k.CreatedAt)
+	if err != nil {
+		return "", err
+	}
+
+	k.ID, err = result.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+func GetAPIKeysByUser(ctx context.Context, userId int64) ([]APIKey, error) {
+	ctx, span := tracer.Start(ctx, "GetAPIKeysByUser")
+	defer span.End()
+
+	query := `
+	SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+	FROM api_keys WHERE user_id = ? ORDER BY id`
+	rows, err := db.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := []APIKey{}
+
+	for rows.Next() {
+		var key APIKey
+		var scopes string
+		var expiresAt, lastUsedAt sql.NullTime
+		err := rows.Scan(
+			&key.ID,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			&scopes,
+			&expiresAt,
+			&lastUsedAt,
+			&key.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		key.Scopes = strings.Fields(scopes)
+		if expiresAt.Valid {
+			key.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			key.LastUsedAt = &lastUsedAt.Time
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey revokes a key owned by userId. It returns ErrAPIKeyNotFound
+// if the user has no key with that ID.
+func DeleteAPIKey(ctx context.Context, userId, id int64) error {
+	ctx, span := tracer.Start(ctx, "DeleteAPIKey")
+	defer span.End()
+
+	result, err := db.DB.ExecContext(ctx, "DELETE FROM api_keys WHERE id = ? AND user_id = ?", id, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey implements auth.APIKeyValidator. It also records when
+// the key was last used.
+func AuthenticateAPIKey(ctx context.Context, key string) (int64, int64, []string, error) {
+	ctx, span := tracer.Start(ctx, "AuthenticateAPIKey")
+	defer span.End()
+
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return 0, 0, nil, ErrInvalidAPIKey
+	}
+
+	query := `SELECT id, user_id, scopes, expires_at FROM api_keys WHERE key_hash = ?`
+	var id, userId int64
+	var scopes string
+	var expiresAt sql.NullTime
+	err := db.DB.QueryRowContext(ctx, query, hashToken(key)).Scan(&id, &userId, &scopes, &expiresAt)
+	if err != nil {
+		return 0, 0, nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	if expiresAt.Valid && now.After(expiresAt.Time) {
+		return 0, 0, nil, ErrInvalidAPIKey
+	}
+
+	_, err = db.DB.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE id = ?", now, id)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	return id, userId, strings.Fields(scopes), nil
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Kinds of domain errors. Every *Error wraps exactly one of them, so
+// callers can test with errors.Is(err, ErrNotFound) without knowing the
+// specific error.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrForbidden  = errors.New("forbidden")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+)
+
+// Error is a domain error whose message is safe to show to clients.
+type Error struct {
+	Kind    error
+	Message string
+	// Fields lists the offending fields of an ErrValidation error.
+	Fields []FieldError
+}
+
+// FieldError describes why a single field was rejected.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func notFound(message string) *Error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+func forbidden(message string) *Error {
+	return &Error{Kind: ErrForbidden, Message: message}
+}
+
+func conflict(message string) *Error {
+	return &Error{Kind: ErrConflict, Message: message}
+}
+
+func invalid(message string, fields ...FieldError) *Error {
+	return &Error{Kind: ErrValidation, Message: message, Fields: fields}
+}
+
+var (
+	ErrEventNotFound     = notFound("Event not found")
+	ErrUserNotFound      = notFound("User not found")
+	ErrAPIKeyNotFound    = notFound("API key not found")
+	ErrNotEventOwner     = forbidden("Only the owner can change this event")
+	ErrAlreadyRegistered = conflict("Already registered for this event")
+	ErrEventCancelled    = conflict("The event is cancelled")
+)
+
+// isUniqueViolation reports whether err comes from a UNIQUE constraint.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
@@ -2,29 +2,95 @@ package models
 
 import (
 	"REST_API/db"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
 	"time"
 )
 
 type Event struct {
 	ID          int64     `json:"id"`
-	Name        string    `json:"name" binding:"required"`
-	Description string    `json:"description" binding:"required"`
-	Location    string    `json:"location" binding:"required"`
-	DateTime    time.Time `json:"date_time" binding:"required"`
+	Name        string    `json:"name" binding:"required,max=100" mod:"trim"`
+	Description string    `json:"description" binding:"required,max=5000" mod:"trim"`
+	Location    string    `json:"location" binding:"required,max=200" mod:"trim"`
+	DateTime    time.Time `json:"date_time" binding:"required,future_on_create"`
 	UserID      int64     `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// CancelledAt is set when the organizer's account was deleted with
+	// CancelEvents. A cancelled event has no owner, so it can no longer be
+	// changed, and takes no new registrations.
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
 }
 
-func (e *Event) Save() error {
+// eventColumns are the columns scanEvent reads, in order. Cancelled events
+// have no owner; their UserID is 0.
+const eventColumns = "id, name, description, location, date_time, COALESCE(user_id, 0), created_at, updated_at, cancelled_at"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// placeholders returns one ? per ID for an IN clause, and the IDs as
+// query arguments.
+func placeholders(ids []int64) (string, []any) {
+	marks := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		marks[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(marks, ", "), args
+}
+
+func scanEvent(row scanner) (Event, error) {
+	var event Event
+	err := row.Scan(
+		&event.ID,
+		&event.Name,
+		&event.Description,
+		&event.Location,
+		&event.DateTime,
+		&event.UserID,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+		&event.CancelledAt)
+	return event, err
+}
+
+// CheckOwner returns ErrNotEventOwner unless userId created the event.
+// Only the owner may change or delete an event.
+func (e *Event) CheckOwner(userId int64) error {
+	if e.UserID != userId {
+		return ErrNotEventOwner
+	}
+	return nil
+}
+
+func (e *Event) Save(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Event.Save")
+	defer span.End()
+
+	err := Validate(Creating(ctx), e)
+	if err != nil {
+		return err
+	}
+
+	e.CreatedAt = time.Now().UTC()
+	e.UpdatedAt = e.CreatedAt
+	e.CancelledAt = nil
+
 	query := `
-	INSERT INTO events (name, description, location, date_time, user_id) 
-	VALUES (?, ?, ?, ?, ?)`
-	stmt, err := db.DB.Prepare(query)
+	INSERT INTO events (name, description, location, date_time, user_id, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := db.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = stmt.Close() }()
 
-	result, err := stmt.Exec(e.Name, e.Description, e.Location, e.DateTime, e.UserID)
+	result, err := stmt.ExecContext(ctx, e.Name, e.Description, e.Location, e.DateTime, e.UserID, e.CreatedAt, e.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -35,47 +101,93 @@ func (e *Event) Save() error {
 	}
 
 	e.ID = resultID
+	publishEventChange(EventCreated, *e)
 	return nil
 }
 
-func (e *Event) Update() error {
+func (e *Event) Update(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Event.Update")
+	defer span.End()
+
+	err := Validate(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	e.UpdatedAt = time.Now().UTC()
+	// Only owned events are updated, and those are never cancelled.
+	e.CancelledAt = nil
+
 	query := `
-	UPDATE events 
-	SET name = ?, description = ?, location = ?, date_time = ?, user_id = ? 
+	UPDATE events
+	SET name = ?, description = ?, location = ?, date_time = ?, user_id = ?, updated_at = ?
 	WHERE id = ?`
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := db.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = stmt.Close() }()
 
-	_, err = stmt.Exec(e.Name, e.Description, e.Location, e.DateTime, e.UserID, e.ID)
+	_, err = stmt.ExecContext(ctx, e.Name, e.Description, e.Location, e.DateTime, e.UserID, e.UpdatedAt, e.ID)
 	if err != nil {
 		return err
 	}
 
+	eventCache.Invalidate(e.ID)
+	publishEventChange(EventUpdated, *e)
 	return nil
 }
 
-func (e *Event) Delete() error {
-	query := `DELETE FROM events WHERE id = ?`
-	stmt, err := db.DB.Prepare(query)
+// Delete removes the event together with its registrations, which would
+// otherwise violate the registrations foreign key.
+func (e *Event) Delete(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Event.Delete")
+	defer span.End()
+
+	tx, err := db.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = stmt.Close() }()
+	defer func() { _ = tx.Rollback() }()
 
-	_, err = stmt.Exec(e.ID)
+	_, err = tx.ExecContext(ctx, `DELETE FROM registrations WHERE event_id = ?`, e.ID)
 	if err != nil {
 		return err
 	}
 
+	_, err = tx.ExecContext(ctx, `DELETE FROM events WHERE id = ?`, e.ID)
+	if err != nil {
+		return err
+	}
+
+	err = recordEventDeletion(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	eventCache.Invalidate(e.ID)
+	publishEventChange(EventDeleted, *e)
 	return nil
 }
 
-func GetAllEvents() ([]Event, error) {
-	query := `SELECT * FROM events`
-	rows, err := db.DB.Query(query)
+func recordEventDeletion(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO event_deletions (id, deleted_at) VALUES (1, ?)
+	ON CONFLICT(id) DO UPDATE SET deleted_at = excluded.deleted_at`, time.Now().UTC())
+	return err
+}
+
+func GetAllEvents(ctx context.Context) ([]Event, error) {
+	ctx, span := tracer.Start(ctx, "GetAllEvents")
+	defer span.End()
+
+	query := `SELECT ` + eventColumns + ` FROM events`
+	rows, err := db.DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -84,35 +196,73 @@ func GetAllEvents() ([]Event, error) {
 	var events []Event
 
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(
-			&event.ID,
-			&event.Name,
-			&event.Description,
-			&event.Location,
-			&event.DateTime,
-			&event.UserID)
+		event, err := scanEvent(rows)
 		if err != nil {
 			return nil, err
 		}
 		events = append(events, event)
 	}
 
-	return events, nil
+	return events, rows.Err()
 }
 
-func GetEventByID(id int64) (*Event, error) {
-	query := `SELECT * FROM events WHERE id = ?`
-	row := db.DB.QueryRow(query, id)
+// ListEvents returns up to limit events with an ID above afterID, in ID
+// order, so callers can page through all events with the last ID seen.
+func ListEvents(ctx context.Context, afterID int64, limit int) ([]Event, error) {
+	ctx, span := tracer.Start(ctx, "ListEvents")
+	defer span.End()
 
-	var event Event
-	err := row.Scan(
-		&event.ID,
-		&event.Name,
-		&event.Description,
-		&event.Location,
-		&event.DateTime,
-		&event.UserID)
+	query := `SELECT ` + eventColumns + ` FROM events WHERE id > ? ORDER BY id LIMIT ?`
+	rows, err := db.DB.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	events := []Event{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// EventsLastModified returns when any event was last created, changed or
+// deleted, or the zero time if that never happened.
+func EventsLastModified(ctx context.Context) (time.Time, error) {
+	ctx, span := tracer.Start(ctx, "EventsLastModified")
+	defer span.End()
+
+	var updatedAt, deletedAt time.Time
+	err := db.DB.QueryRowContext(ctx, "SELECT updated_at FROM events ORDER BY updated_at DESC LIMIT 1").Scan(&updatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, err
+	}
+	err = db.DB.QueryRowContext(ctx, "SELECT deleted_at FROM event_deletions WHERE id = 1").Scan(&deletedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, err
+	}
+
+	if deletedAt.After(updatedAt) {
+		return deletedAt, nil
+	}
+	return updatedAt, nil
+}
+
+// GetEventByID is served from eventCache. Every caller gets its own copy.
+func GetEventByID(ctx context.Context, id int64) (*Event, error) {
+	ctx, span := tracer.Start(ctx, "GetEventByID")
+	defer span.End()
+
+	event, err := eventCache.GetOrLoad(id, func() (Event, error) {
+		// Callers waiting for the same load must not fail because the
+		// request that started it was cancelled.
+		return loadEvent(context.WithoutCancel(ctx), id)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -120,28 +270,136 @@ func GetEventByID(id int64) (*Event, error) {
 	return &event, nil
 }
 
-func (e *Event) Register(userId int64) error {
+func loadEvent(ctx context.Context, id int64) (Event, error) {
+	query := `SELECT ` + eventColumns + ` FROM events WHERE id = ?`
+	event, err := scanEvent(db.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Event{}, ErrEventNotFound
+	}
+	return event, err
+}
+
+func (e *Event) Register(ctx context.Context, userId int64) error {
+	ctx, span := tracer.Start(ctx, "Event.Register")
+	defer span.End()
+
+	if e.CancelledAt != nil {
+		return ErrEventCancelled
+	}
+
 	query := `INSERT INTO registrations (event_id, user_id) VALUES (?, ?)`
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := db.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = stmt.Close() }()
 
-	_, err = stmt.Exec(e.ID, userId)
+	_, err = stmt.ExecContext(ctx, e.ID, userId)
+	if isUniqueViolation(err) {
+		return ErrAlreadyRegistered
+	}
+	if err != nil {
+		return err
+	}
 
-	return err
+	e.publishRegistrations(ctx)
+	return nil
 }
 
-func (e *Event) Unregister(userId int64) error {
+func (e *Event) Unregister(ctx context.Context, userId int64) error {
+	ctx, span := tracer.Start(ctx, "Event.Unregister")
+	defer span.End()
+
 	query := `DELETE FROM registrations WHERE event_id = ? AND user_id = ?`
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := db.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = stmt.Close() }()
 
-	_, err = stmt.Exec(e.ID, userId)
+	result, err := stmt.ExecContext(ctx, e.ID, userId)
+	if err != nil {
+		return err
+	}
+
+	// Unregistering twice is not an error, but nothing changed.
+	removed, err := result.RowsAffected()
+	if err == nil && removed > 0 {
+		e.publishRegistrations(ctx)
+	}
+	return nil
+}
 
-	return err
+// publishRegistrations announces the attendee count after a registration
+// change. The change is already committed, so a count that cannot be read
+// only skips the announcement.
+func (e *Event) publishRegistrations(ctx context.Context) {
+	counts, err := CountRegistrations(ctx, []int64{e.ID})
+	if err != nil {
+		return
+	}
+	publishRegistrationChange(*e, counts[e.ID])
+}
+
+// CountRegistrations returns how many users registered for each of
+// eventIDs in a single query. Events without registrations are left out.
+func CountRegistrations(ctx context.Context, eventIDs []int64) (map[int64]int, error) {
+	ctx, span := tracer.Start(ctx, "CountRegistrations")
+	defer span.End()
+
+	counts := make(map[int64]int, len(eventIDs))
+	if len(eventIDs) == 0 {
+		return counts, nil
+	}
+
+	marks, args := placeholders(eventIDs)
+	query := `SELECT event_id, COUNT(*) FROM registrations WHERE event_id IN (` + marks + `) GROUP BY event_id`
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var eventId int64
+		var count int
+		err := rows.Scan(&eventId, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[eventId] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// RegisteredFor reports which of eventIDs userId is registered for, in a
+// single query. Events the user is not registered for are left out.
+func RegisteredFor(ctx context.Context, userId int64, eventIDs []int64) (map[int64]bool, error) {
+	ctx, span := tracer.Start(ctx, "RegisteredFor")
+	defer span.End()
+
+	registered := make(map[int64]bool, len(eventIDs))
+	if len(eventIDs) == 0 {
+		return registered, nil
+	}
+
+	marks, args := placeholders(eventIDs)
+	query := `SELECT event_id FROM registrations WHERE user_id = ? AND event_id IN (` + marks + `)`
+	rows, err := db.DB.QueryContext(ctx, query, append([]any{userId}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var eventId int64
+		err := rows.Scan(&eventId)
+		if err != nil {
+			return nil, err
+		}
+		registered[eventId] = true
+	}
+
+	return registered, rows.Err()
 }
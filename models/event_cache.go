@@ -0,0 +1,22 @@
+package models
+
+import (
+	"REST_API/cache"
+	"time"
+)
+
+// eventCache serves GetEventByID. Writers invalidate it after committing;
+// cache.LRU makes sure a read racing the write cannot cache the old row.
+var eventCache = cache.New[int64, Event](1000, time.Minute)
+
+// ConfigureEventCache replaces the event cache with an empty one holding
+// up to size events for ttl. A size of zero disables caching. It is meant
+// to be called at startup, before requests are served.
+func ConfigureEventCache(size int, ttl time.Duration) {
+	eventCache = cache.New[int64, Event](size, ttl)
+}
+
+// EventCacheStats reports the event cache's hits, misses and size.
+func EventCacheStats() cache.Stats {
+	return eventCache.Stats()
+}
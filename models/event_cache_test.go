@@ -0,0 +1,219 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func saveCacheTestEvent(t *testing.T) *Event {
+	t.Helper()
+
+	event := &Event{
+		Name:        "v0",
+		Description: "Event for testing the cache",
+		Location:    "Test location",
+		DateTime:    time.Now().Add(24 * time.Hour),
+		UserID:      1,
+	}
+	err := event.Save(t.Context())
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+	return event
+}
+
+func TestGetEventByID_Cache(t *testing.T) {
+	cleanup := setupEventTestDB(t)
+	defer cleanup()
+
+	t.Run("Second lookup is a hit", func(t *testing.T) {
+		event := saveCacheTestEvent(t)
+		before := EventCacheStats()
+
+		for range 2 {
+			_, err := GetEventByID(t.Context(), event.ID)
+			if err != nil {
+				t.Fatalf("GetEventByID() error = %v", err)
+			}
+		}
+
+		after := EventCacheStats()
+		if after.Misses != before.Misses+1 || after.Hits != before.Hits+1 {
+			t.Errorf("GetEventByID() misses +%d hits +%d, want +1 each", after.Misses-before.Misses, after.Hits-before.Hits)
+		}
+	})
+
+	t.Run("Callers get their own copy", func(t *testing.T) {
+		event := saveCacheTestEvent(t)
+
+		first, err := GetEventByID(t.Context(), event.ID)
+		if err != nil {
+			t.Fatalf("GetEventByID() error = %v", err)
+		}
+		first.Name = "changed by the caller"
+
+		second, err := GetEventByID(t.Context(), event.ID)
+		if err != nil {
+			t.Fatalf("GetEventByID() error = %v", err)
+		}
+		if second.Name != "v0" {
+			t.Errorf("GetEventByID() name = %q, want %q", second.Name, "v0")
+		}
+	})
+
+	t.Run("Update invalidates", func(t *testing.T) {
+		event := saveCacheTestEvent(t)
+		_, _ = GetEventByID(t.Context(), event.ID)
+
+		event.Name = "v1"
+		err := event.Update(t.Context())
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		retrieved, err := GetEventByID(t.Context(), event.ID)
+		if err != nil {
+			t.Fatalf("GetEventByID() error = %v", err)
+		}
+		if retrieved.Name != "v1" {
+			t.Errorf("GetEventByID() name = %q, want %q", retrieved.Name, "v1")
+		}
+	})
+
+	t.Run("Delete invalidates", func(t *testing.T) {
+		event := saveCacheTestEvent(t)
+		_, _ = GetEventByID(t.Context(), event.ID)
+
+		err := event.Delete(t.Context())
+		if err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+
+		_, err = GetEventByID(t.Context(), event.ID)
+		if !errors.Is(err, ErrEventNotFound) {
+			t.Errorf("GetEventByID() error = %v, want %v", err, ErrEventNotFound)
+		}
+	})
+
+	t.Run("Missing events are not cached", func(t *testing.T) {
+		before := EventCacheStats()
+
+		for range 2 {
+			_, err := GetEventByID(t.Context(), 999)
+			if !errors.Is(err, ErrEventNotFound) {
+				t.Fatalf("GetEventByID() error = %v, want %v", err, ErrEventNotFound)
+			}
+		}
+
+		if loads := EventCacheStats().Loads - before.Loads; loads != 2 {
+			t.Errorf("GetEventByID() loads = %d, want 2", loads)
+		}
+	})
+
+	t.Run("Account deletion invalidates transferred events", func(t *testing.T) {
+		owner := &User{Email: "owner@example.com", Password: "password123"}
+		err := owner.Save(t.Context())
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		event := saveCacheTestEvent(t)
+		event.UserID = owner.ID
+		err = event.Update(t.Context())
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		_, _ = GetEventByID(t.Context(), event.ID)
+
+		err = owner.DeleteAccount(t.Context(), "password123", TransferEvents, "testuser@example.com")
+		if err != nil {
+			t.Fatalf("DeleteAccount() error = %v", err)
+		}
+
+		retrieved, err := GetEventByID(t.Context(), event.ID)
+		if err != nil {
+			t.Fatalf("GetEventByID() error = %v", err)
+		}
+		if retrieved.UserID != 1 {
+			t.Errorf("GetEventByID() user_id = %d, want 1", retrieved.UserID)
+		}
+	})
+}
+
+func TestGetEventByID_Concurrency(t *testing.T) {
+	cleanup := setupEventTestDB(t)
+	defer cleanup()
+
+	t.Run("Concurrent misses share one query", func(t *testing.T) {
+		event := saveCacheTestEvent(t)
+		before := EventCacheStats()
+
+		var wg sync.WaitGroup
+		for range 50 {
+			wg.Go(func() {
+				retrieved, err := GetEventByID(t.Context(), event.ID)
+				if err != nil || retrieved.Name != "v0" {
+					t.Errorf("GetEventByID() = %v, %v", retrieved, err)
+				}
+			})
+		}
+		wg.Wait()
+
+		if loads := EventCacheStats().Loads - before.Loads; loads != 1 {
+			t.Errorf("GetEventByID() loads = %d, want 1", loads)
+		}
+	})
+
+	t.Run("Readers never go back to an older version", func(t *testing.T) {
+		event := saveCacheTestEvent(t)
+		const versions = 100
+
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			for version := 1; version <= versions; version++ {
+				update := *event
+				update.Name = fmt.Sprint("v", version)
+				err := update.Update(t.Context())
+				if err != nil {
+					t.Errorf("Update() error = %v", err)
+					return
+				}
+			}
+		})
+		for range 8 {
+			wg.Go(func() {
+				seen := 0
+				for seen < versions {
+					retrieved, err := GetEventByID(t.Context(), event.ID)
+					if err != nil {
+						t.Errorf("GetEventByID() error = %v", err)
+						return
+					}
+					version, _ := strconv.Atoi(strings.TrimPrefix(retrieved.Name, "v"))
+					if version < seen {
+						t.Errorf("GetEventByID() returned v%d after v%d", version, seen)
+						return
+					}
+					seen = version
+				}
+			})
+		}
+		wg.Wait()
+
+		cached, err := GetEventByID(t.Context(), event.ID)
+		if err != nil {
+			t.Fatalf("GetEventByID() error = %v", err)
+		}
+		stored, err := loadEvent(t.Context(), event.ID)
+		if err != nil {
+			t.Fatalf("loadEvent() error = %v", err)
+		}
+		if cached.Name != stored.Name || !cached.UpdatedAt.Equal(stored.UpdatedAt) {
+			t.Errorf("cached event %q at %v, database has %q at %v", cached.Name, cached.UpdatedAt, stored.Name, stored.UpdatedAt)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"REST_API/pubsub"
+	"time"
+)
+
+type ChangeType string
+
+const (
+	EventCreated ChangeType = "created"
+	EventUpdated ChangeType = "updated"
+	EventDeleted ChangeType = "deleted"
+	// EventRegistrations is a user registering for or unregistering
+	// from the event.
+	EventRegistrations ChangeType = "registrations"
+)
+
+// EventChange is published after a change to an event is committed.
+type EventChange struct {
+	Type    ChangeType
+	EventID int64
+	// Event is the event after the change, zero for EventDeleted.
+	Event Event
+	// AttendeeCount is the number of registrations after an
+	// EventRegistrations change.
+	AttendeeCount int
+	At            time.Time
+}
+
+// eventChanges buffers enough changes for a subscriber that is busy
+// writing to a slow client; one that falls further behind is dropped. The
+// history lets clients that reconnect catch up on what they missed.
+var eventChanges = pubsub.New[EventChange](256, 1000)
+
+// SubscribeEventChanges returns a subscription to the changes of all
+// events made through Event.Save, Update, Delete, Register, Unregister
+// and User.DeleteAccount from now on.
+func SubscribeEventChanges() *pubsub.Subscription[EventChange] {
+	return eventChanges.Subscribe()
+}
+
+// SubscribeEventChangesAfter resumes after the change with ID lastID.
+// When the changes since then are no longer known it returns false and a
+// subscription to new changes only; the subscriber has missed changes and
+// must reload the events it needs.
+func SubscribeEventChangesAfter(lastID uint64) (*pubsub.Subscription[EventChange], bool) {
+	return eventChanges.SubscribeAfter(lastID)
+}
+
+func publishEventChange(changeType ChangeType, event Event) {
+	change := EventChange{Type: changeType, EventID: event.ID, At: time.Now().UTC()}
+	if changeType != EventDeleted {
+		change.Event = event
+	}
+	eventChanges.Publish(change)
+}
+
+func publishRegistrationChange(event Event, attendeeCount int) {
+	eventChanges.Publish(EventChange{
+		Type:          EventRegistrations,
+		EventID:       event.ID,
+		Event:         event,
+		AttendeeCount: attendeeCount,
+		At:            time.Now().UTC(),
+	})
+}
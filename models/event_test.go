@@ -3,6 +3,9 @@ package models
 import (
 	"REST_API/db"
 	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,53 +19,17 @@ func setupEventTestDB(t *testing.T) func() {
 	if err != nil {
 		t.Fatalf("Failed to create test database: %v", err)
 	}
+	// Every connection to :memory: opens a separate database.
+	testDB.SetMaxOpenConns(1)
 
 	_, err = testDB.Exec("PRAGMA foreign_keys = ON")
 	if err != nil {
 		t.Fatalf("Failed to enable foreign keys: %v", err)
 	}
 
-	createUsersTable := `
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			email TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL
-		)`
-
-	_, err = testDB.Exec(createUsersTable)
-	if err != nil {
-		t.Fatalf("Failed to create users table: %v", err)
-	}
-
-	createEventsTable := `
-		CREATE TABLE events (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			description TEXT NOT NULL,
-			location TEXT NOT NULL,
-			date_time DATETIME NOT NULL,
-			user_id INTEGER,
-			FOREIGN KEY(user_id) REFERENCES users(id)
-		)`
-
-	_, err = testDB.Exec(createEventsTable)
-	if err != nil {
-		t.Fatalf("Failed to create events table: %v", err)
-	}
-
-	createRegistrationTable := `
-		CREATE TABLE registrations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			event_id INTEGER,
-			user_id INTEGER,
-			FOREIGN KEY(event_id) REFERENCES events(id),
-			FOREIGN KEY(user_id) REFERENCES users(id),
-			UNIQUE(event_id, user_id)
-		)`
-
-	_, err = testDB.Exec(createRegistrationTable)
+	err = db.Migrate(testDB)
 	if err != nil {
-		t.Fatalf("Failed to create registrations table: %v", err)
+		t.Fatalf("Failed to migrate test database: %v", err)
 	}
 
 	_, err = testDB.Exec("INSERT INTO users (email, password) VALUES (?, ?)", "testuser@example.com", "hashedpassword")
@@ -71,6 +38,7 @@ func setupEventTestDB(t *testing.T) func() {
 	}
 
 	db.DB = testDB
+	eventCache.Clear()
 
 	return func() {
 		err := testDB.Close()
@@ -102,15 +70,26 @@ func TestEvent_Save(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "Valid event with empty fields (SQLite allows this)",
+			name: "Invalid event with empty fields",
 			event: Event{
 				Name:        "",
-				Description: "",
+				Description: "  ",
 				Location:    "",
-				DateTime:    time.Now(),
+				DateTime:    time.Now().Add(24 * time.Hour),
 				UserID:      1,
 			},
-			wantErr: false,
+			wantErr: true,
+		},
+		{
+			name: "Invalid event in the past",
+			event: Event{
+				Name:        "Test Conference",
+				Description: "Annual tech conference",
+				Location:    "Convention Center",
+				DateTime:    time.Now().Add(-time.Hour),
+				UserID:      1,
+			},
+			wantErr: true,
 		},
 		{
 			name: "Invalid event with non-existent user ID",
@@ -127,7 +106,7 @@ func TestEvent_Save(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.event.Save()
+			err := tt.event.Save(t.Context())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Save() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -150,7 +129,7 @@ func TestEvent_Update(t *testing.T) {
 		UserID:      1,
 	}
 
-	err := event.Save()
+	err := event.Save(t.Context())
 	if err != nil {
 		t.Fatalf("Failed to create test event: %v", err)
 	}
@@ -169,10 +148,17 @@ func TestEvent_Update(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "Update with empty name (allowed)",
+			name: "Update with empty name",
 			updateFn: func(e *Event) {
 				e.Name = ""
 			},
+			wantErr: true,
+		},
+		{
+			name: "Update to a past date (allowed)",
+			updateFn: func(e *Event) {
+				e.DateTime = time.Now().Add(-time.Hour)
+			},
 			wantErr: false,
 		},
 		{
@@ -189,7 +175,7 @@ func TestEvent_Update(t *testing.T) {
 			testEvent := *event
 			tt.updateFn(&testEvent)
 
-			err := testEvent.Update()
+			err := testEvent.Update(t.Context())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -209,26 +195,59 @@ func TestEvent_Delete(t *testing.T) {
 		UserID:      1,
 	}
 
-	err := event.Save()
+	err := event.Save(t.Context())
 	if err != nil {
 		t.Fatalf("Failed to create test event: %v", err)
 	}
 
 	t.Run("Successful delete", func(t *testing.T) {
-		err := event.Delete()
+		err := event.Delete(t.Context())
 		if err != nil {
 			t.Errorf("Delete() error = %v", err)
 		}
 
-		_, err = GetEventByID(event.ID)
+		_, err = GetEventByID(t.Context(), event.ID)
 		if err == nil {
 			t.Error("Event should not exist after deletion")
 		}
 	})
 
+	t.Run("Delete event with registrations", func(t *testing.T) {
+		registered := &Event{
+			Name:        "Registered Event",
+			Description: "This event has attendees",
+			Location:    "Test location",
+			DateTime:    time.Now().Add(24 * time.Hour),
+			UserID:      1,
+		}
+		err := registered.Save(t.Context())
+		if err != nil {
+			t.Fatalf("Failed to create test event: %v", err)
+		}
+
+		err = registered.Register(t.Context(), 1)
+		if err != nil {
+			t.Fatalf("Failed to register for test event: %v", err)
+		}
+
+		err = registered.Delete(t.Context())
+		if err != nil {
+			t.Errorf("Delete() error = %v", err)
+		}
+
+		var count int
+		err = db.DB.QueryRow("SELECT COUNT(*) FROM registrations WHERE event_id = ?", registered.ID).Scan(&count)
+		if err != nil {
+			t.Fatalf("Failed to count registrations: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("Expected registrations to be deleted, found %d", count)
+		}
+	})
+
 	t.Run("Delete non-existent event", func(t *testing.T) {
 		nonExistentEvent := &Event{ID: 999}
-		err := nonExistentEvent.Delete()
+		err := nonExistentEvent.Delete(t.Context())
 		if err != nil {
 			t.Errorf("Delete() error = %v", err)
 		}
@@ -247,7 +266,7 @@ func TestEvent_Register(t *testing.T) {
 		UserID:      1,
 	}
 
-	err := event.Save()
+	err := event.Save(t.Context())
 	if err != nil {
 		t.Fatalf("Failed to create test event: %v", err)
 	}
@@ -271,7 +290,7 @@ func TestEvent_Register(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := event.Register(tt.userID)
+			err := event.Register(t.Context(), tt.userID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Register() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -286,17 +305,17 @@ func TestEvent_Register(t *testing.T) {
 			DateTime:    time.Now().Add(48 * time.Hour),
 			UserID:      1,
 		}
-		err := testEvent.Save()
+		err := testEvent.Save(t.Context())
 		if err != nil {
 			t.Fatalf("Failed to create test event: %v", err)
 		}
 
-		err = testEvent.Register(1)
+		err = testEvent.Register(t.Context(), 1)
 		if err != nil {
 			t.Fatalf("First registration should succeed: %v", err)
 		}
 
-		err = testEvent.Register(1)
+		err = testEvent.Register(t.Context(), 1)
 		if err == nil {
 			t.Error("Duplicate registration should fail")
 		}
@@ -315,25 +334,25 @@ func TestEvent_Unregister(t *testing.T) {
 		UserID:      1,
 	}
 
-	err := event.Save()
+	err := event.Save(t.Context())
 	if err != nil {
 		t.Fatalf("Failed to create test event: %v", err)
 	}
 
-	err = event.Register(1)
+	err = event.Register(t.Context(), 1)
 	if err != nil {
 		t.Fatalf("Failed to register user: %v", err)
 	}
 
 	t.Run("Successful unregistration", func(t *testing.T) {
-		err := event.Unregister(1)
+		err := event.Unregister(t.Context(), 1)
 		if err != nil {
 			t.Errorf("Unregister() error = %v", err)
 		}
 	})
 
 	t.Run("Unregister non-registered user", func(t *testing.T) {
-		err := event.Unregister(999)
+		err := event.Unregister(t.Context(), 999)
 		if err != nil {
 			t.Errorf("Unregister() error = %v", err)
 		}
@@ -362,14 +381,14 @@ func TestGetAllEvents(t *testing.T) {
 	}
 
 	for _, event := range events {
-		err := event.Save()
+		err := event.Save(t.Context())
 		if err != nil {
 			t.Fatalf("Failed to create test event: %v", err)
 		}
 	}
 
 	t.Run("Get all events", func(t *testing.T) {
-		allEvents, err := GetAllEvents()
+		allEvents, err := GetAllEvents(t.Context())
 		if err != nil {
 			t.Errorf("GetAllEvents() error = %v", err)
 			return
@@ -393,7 +412,7 @@ func TestGetEventByID(t *testing.T) {
 		UserID:      1,
 	}
 
-	err := event.Save()
+	err := event.Save(t.Context())
 	if err != nil {
 		t.Fatalf("Failed to create test event: %v", err)
 	}
@@ -417,7 +436,7 @@ func TestGetEventByID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			retrievedEvent, err := GetEventByID(tt.id)
+			retrievedEvent, err := GetEventByID(t.Context(), tt.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetEventByID() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -434,3 +453,192 @@ func TestGetEventByID(t *testing.T) {
 		})
 	}
 }
+
+func TestEventsLastModified(t *testing.T) {
+	cleanup := setupEventTestDB(t)
+	defer cleanup()
+
+	lastModified, err := EventsLastModified(t.Context())
+	if err != nil || !lastModified.IsZero() {
+		t.Fatalf("EventsLastModified() without events = %v, %v, want zero time", lastModified, err)
+	}
+
+	event := &Event{
+		Name:        "Timestamped Event",
+		Description: "Event for testing timestamps",
+		Location:    "Test location",
+		DateTime:    time.Now().Add(24 * time.Hour),
+		UserID:      1,
+	}
+	err = event.Save(t.Context())
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+
+	stored, err := GetEventByID(t.Context(), event.ID)
+	if err != nil {
+		t.Fatalf("Failed to get test event: %v", err)
+	}
+	if stored.CreatedAt.IsZero() || !stored.UpdatedAt.Equal(stored.CreatedAt) {
+		t.Errorf("Saved event has created_at %v and updated_at %v", stored.CreatedAt, stored.UpdatedAt)
+	}
+
+	lastModified, err = EventsLastModified(t.Context())
+	if err != nil || !lastModified.Equal(stored.UpdatedAt) {
+		t.Errorf("EventsLastModified() after save = %v, %v, want %v", lastModified, err, stored.UpdatedAt)
+	}
+
+	stored.Name = "Renamed Event"
+	err = stored.Update(t.Context())
+	if err != nil {
+		t.Fatalf("Failed to update test event: %v", err)
+	}
+
+	updated, err := GetEventByID(t.Context(), event.ID)
+	if err != nil {
+		t.Fatalf("Failed to get test event: %v", err)
+	}
+	if !updated.UpdatedAt.After(updated.CreatedAt) {
+		t.Errorf("Update did not move updated_at past created_at %v", updated.CreatedAt)
+	}
+
+	err = updated.Delete(t.Context())
+	if err != nil {
+		t.Fatalf("Failed to delete test event: %v", err)
+	}
+
+	lastModified, err = EventsLastModified(t.Context())
+	if err != nil || !lastModified.After(updated.UpdatedAt) {
+		t.Errorf("EventsLastModified() after delete = %v, %v, want after %v", lastModified, err, updated.UpdatedAt)
+	}
+}
+
+func TestSubscribeEventChanges(t *testing.T) {
+	cleanup := setupEventTestDB(t)
+	defer cleanup()
+
+	changes := SubscribeEventChanges()
+	defer changes.Close()
+
+	event := &Event{
+		Name:        "Watched Event",
+		Description: "Event for testing changes",
+		Location:    "Test location",
+		DateTime:    time.Now().Add(24 * time.Hour),
+		UserID:      1,
+	}
+	err := event.Save(t.Context())
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+	event.Name = "Renamed Event"
+	err = event.Update(t.Context())
+	if err != nil {
+		t.Fatalf("Failed to update test event: %v", err)
+	}
+	err = event.Register(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	for range 2 {
+		err = event.Unregister(t.Context(), 1)
+		if err != nil {
+			t.Fatalf("Failed to unregister: %v", err)
+		}
+	}
+	err = event.Delete(t.Context())
+	if err != nil {
+		t.Fatalf("Failed to delete test event: %v", err)
+	}
+
+	want := []struct {
+		changeType ChangeType
+		name       string
+		attendees  int
+	}{
+		{EventCreated, "Watched Event", 0},
+		{EventUpdated, "Renamed Event", 0},
+		{EventRegistrations, "Renamed Event", 1},
+		// Only the first Unregister changed anything.
+		{EventRegistrations, "Renamed Event", 0},
+		{EventDeleted, "", 0},
+	}
+	var lastID uint64
+	for _, w := range want {
+		msg := <-changes.C()
+		change := msg.Value
+		if change.Type != w.changeType || change.EventID != event.ID || change.Event.Name != w.name || change.AttendeeCount != w.attendees {
+			t.Errorf("Got change %s of event %d named %q with %d attendees, want %s of event %d named %q with %d",
+				change.Type, change.EventID, change.Event.Name, change.AttendeeCount, w.changeType, event.ID, w.name, w.attendees)
+		}
+		if msg.ID <= lastID {
+			t.Errorf("Change ID %d does not follow %d", msg.ID, lastID)
+		}
+		lastID = msg.ID
+	}
+
+	resumed, ok := SubscribeEventChangesAfter(lastID - 1)
+	if !ok {
+		t.Fatal("SubscribeEventChangesAfter() could not resume from a recent change")
+	}
+	defer resumed.Close()
+	if msg := <-resumed.C(); msg.ID != lastID || msg.Value.Type != EventDeleted {
+		t.Errorf("Resumed with change %d (%s), want %d (%s)", msg.ID, msg.Value.Type, lastID, EventDeleted)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("Reports every violation", func(t *testing.T) {
+		event := &Event{
+			Name:     strings.Repeat("x", 101),
+			Location: "Convention Center",
+			DateTime: time.Now().Add(-time.Hour),
+		}
+
+		err := Validate(Creating(t.Context()), event)
+		var validationErr *Error
+		if !errors.As(err, &validationErr) || !errors.Is(err, ErrValidation) {
+			t.Fatalf("Validate() error = %v, want a validation error", err)
+		}
+
+		want := []FieldError{
+			{Field: "name", Message: "must be at most 100 characters"},
+			{Field: "description", Message: "is required"},
+			{Field: "date_time", Message: "must be in the future"},
+		}
+		if !reflect.DeepEqual(validationErr.Fields, want) {
+			t.Errorf("Validate() fields = %v, want %v", validationErr.Fields, want)
+		}
+	})
+
+	t.Run("Trims whitespace", func(t *testing.T) {
+		event := &Event{
+			Name:        "  Test Conference \n",
+			Description: "\tAnnual tech conference",
+			Location:    "Convention Center ",
+			DateTime:    time.Now().Add(time.Hour),
+		}
+
+		err := Validate(t.Context(), event)
+		if err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if event.Name != "Test Conference" || event.Description != "Annual tech conference" || event.Location != "Convention Center" {
+			t.Errorf("Validate() did not trim fields: %+v", event)
+		}
+	})
+
+	t.Run("Past dates are only rejected on create", func(t *testing.T) {
+		event := &Event{
+			Name:        "Test Conference",
+			Description: "Annual tech conference",
+			Location:    "Convention Center",
+			DateTime:    time.Now().Add(-time.Hour),
+		}
+
+		err := Validate(t.Context(), event)
+		if err != nil {
+			t.Errorf("Validate() error = %v, want nil", err)
+		}
+	})
+}
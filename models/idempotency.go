@@ -0,0 +1,128 @@
+package models
+
+import (
+	"REST_API/db"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrIdempotencyKeyReused means the key was first used for a different
+	// request.
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+	// ErrIdempotencyKeyInFlight means the first request with the key has
+	// not finished yet.
+	ErrIdempotencyKeyInFlight = errors.New("idempotency key in use by a running request")
+	// ErrIdempotencyClaimLost means the lease of a claim ran out and the
+	// key was released or claimed again by a retry before the request
+	// finished.
+	ErrIdempotencyClaimLost = errors.New("idempotency key claim expired")
+)
+
+// IdempotentResponse is the first response to a request with an
+// Idempotency-Key, replayed when the request is retried.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// BeginIdempotentRequest claims key for userId. It returns the ID of the
+// claim when the request should run, the stored response when it already
+// ran, or an error if the key belongs to another request or is still in
+// use. The claim expires after lease, so a key whose request never
+// finished, for example because the server stopped, can be claimed again.
+// Expired keys of the user are removed on the way.
+func BeginIdempotentRequest(ctx context.Context, userId int64, key, fingerprint string, lease time.Duration) (int64, *IdempotentResponse, error) {
+	ctx, span := tracer.Start(ctx, "BeginIdempotentRequest")
+	defer span.End()
+
+	now := time.Now().UTC()
+	_, err := db.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE user_id = ? AND expires_at <= ?", userId, now)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	query := `
+	INSERT INTO idempotency_keys (user_id, key, fingerprint, expires_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT(user_id, key) DO NOTHING`
+	result, err := db.DB.ExecContext(ctx, query, userId, key, fingerprint, now.Add(lease))
+	if err != nil {
+		return 0, nil, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+	if claimed == 1 {
+		// IDs are never reused, so a claim taken over after its lease ran
+		// out gets a new one.
+		claimId, err := result.LastInsertId()
+		return claimId, nil, err
+	}
+
+	var stored string
+	var response IdempotentResponse
+	query = "SELECT fingerprint, status, content_type, body FROM idempotency_keys WHERE user_id = ? AND key = ?"
+	err = db.DB.QueryRowContext(ctx, query, userId, key).Scan(&stored, &response.Status, &response.ContentType, &response.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released by a failed first request in the meantime.
+		return 0, nil, ErrIdempotencyKeyInFlight
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if stored != fingerprint {
+		return 0, nil, ErrIdempotencyKeyReused
+	}
+	if response.Status == 0 {
+		return 0, nil, ErrIdempotencyKeyInFlight
+	}
+	return 0, &response, nil
+}
+
+// CompleteIdempotentRequest stores the response to replay for the key of
+// claimId until ttl has passed. It returns ErrIdempotencyClaimLost and
+// leaves the key alone if the claim is no longer held.
+func CompleteIdempotentRequest(ctx context.Context, claimId int64, response IdempotentResponse, ttl time.Duration) error {
+	ctx, span := tracer.Start(ctx, "CompleteIdempotentRequest")
+	defer span.End()
+
+	expiresAt := time.Now().UTC().Add(ttl)
+	query := "UPDATE idempotency_keys SET status = ?, content_type = ?, body = ?, expires_at = ? WHERE id = ? AND status = 0"
+	result, err := db.DB.ExecContext(ctx, query, response.Status, response.ContentType, response.Body, expiresAt, claimId)
+	if err != nil {
+		return err
+	}
+	return claimHeld(result)
+}
+
+// ReleaseIdempotencyKey forgets the key of claimId so that a retry runs
+// the request again, for requests that failed without a response worth
+// replaying. Like CompleteIdempotentRequest it only touches a claim that
+// is still held.
+func ReleaseIdempotencyKey(ctx context.Context, claimId int64) error {
+	ctx, span := tracer.Start(ctx, "ReleaseIdempotencyKey")
+	defer span.End()
+
+	result, err := db.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE id = ? AND status = 0", claimId)
+	if err != nil {
+		return err
+	}
+	return claimHeld(result)
+}
+
+func claimHeld(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrIdempotencyClaimLost
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIdempotentRequest_ExpiredLease(t *testing.T) {
+	cleanup := setupEventTestDB(t)
+	defer cleanup()
+
+	// beginExpired claims key with a lease that has already run out, as if
+	// its request was still running after the lease.
+	beginExpired := func(t *testing.T, key string) int64 {
+		t.Helper()
+		claimId, stored, err := BeginIdempotentRequest(t.Context(), 1, key, "fingerprint", -time.Second)
+		if err != nil || stored != nil {
+			t.Fatalf("BeginIdempotentRequest() = %v, %v", stored, err)
+		}
+		return claimId
+	}
+	// retry claims key again with a normal lease.
+	retry := func(t *testing.T, key string) int64 {
+		t.Helper()
+		claimId, stored, err := BeginIdempotentRequest(t.Context(), 1, key, "fingerprint", time.Minute)
+		if err != nil || stored != nil {
+			t.Fatalf("BeginIdempotentRequest() = %v, %v", stored, err)
+		}
+		return claimId
+	}
+	response := IdempotentResponse{Status: http.StatusCreated, ContentType: "application/json", Body: []byte(`{"id":1}`)}
+
+	t.Run("Late completion keeps the retry's claim", func(t *testing.T) {
+		stale := beginExpired(t, "complete-1")
+		current := retry(t, "complete-1")
+
+		err := CompleteIdempotentRequest(t.Context(), stale, IdempotentResponse{Status: http.StatusOK}, time.Hour)
+		if !errors.Is(err, ErrIdempotencyClaimLost) {
+			t.Fatalf("CompleteIdempotentRequest() error = %v, want ErrIdempotencyClaimLost", err)
+		}
+		_, _, err = BeginIdempotentRequest(t.Context(), 1, "complete-1", "fingerprint", time.Minute)
+		if !errors.Is(err, ErrIdempotencyKeyInFlight) {
+			t.Fatalf("BeginIdempotentRequest() error = %v, want ErrIdempotencyKeyInFlight", err)
+		}
+
+		err = CompleteIdempotentRequest(t.Context(), current, response, time.Hour)
+		if err != nil {
+			t.Fatalf("CompleteIdempotentRequest() error = %v", err)
+		}
+		_, stored, err := BeginIdempotentRequest(t.Context(), 1, "complete-1", "fingerprint", time.Minute)
+		if err != nil || stored == nil || stored.Status != http.StatusCreated {
+			t.Fatalf("BeginIdempotentRequest() = %v, %v, want the retry's response", stored, err)
+		}
+	})
+
+	t.Run("Late release keeps the retry's claim", func(t *testing.T) {
+		stale := beginExpired(t, "release-1")
+		current := retry(t, "release-1")
+
+		err := ReleaseIdempotencyKey(t.Context(), stale)
+		if !errors.Is(err, ErrIdempotencyClaimLost) {
+			t.Fatalf("ReleaseIdempotencyKey() error = %v, want ErrIdempotencyClaimLost", err)
+		}
+		err = CompleteIdempotentRequest(t.Context(), current, response, time.Hour)
+		if err != nil {
+			t.Fatalf("CompleteIdempotentRequest() error = %v", err)
+		}
+	})
+
+	t.Run("Completed keys are not released", func(t *testing.T) {
+		claimId := retry(t, "done-1")
+		err := CompleteIdempotentRequest(t.Context(), claimId, response, time.Hour)
+		if err != nil {
+			t.Fatalf("CompleteIdempotentRequest() error = %v", err)
+		}
+
+		err = ReleaseIdempotencyKey(t.Context(), claimId)
+		if !errors.Is(err, ErrIdempotencyClaimLost) {
+			t.Fatalf("ReleaseIdempotencyKey() error = %v, want ErrIdempotencyClaimLost", err)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"REST_API/db"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdentityEmail  = invalid("Provider did not share an email address")
+	ErrIdentityLinked = conflict("This login is already linked to another account")
+	// ErrLinkRequired is returned for a login whose email belongs to an
+	// account that has not proven it owns that email. Linking it would let
+	// whoever signed up with the email keep access to the account.
+	ErrLinkRequired = conflict("An account with this email already exists; log in to it and link this login from your profile")
+)
+
+// Identity links a user to an account at an external OpenID Connect
+// provider, identified by the provider's stable subject.
+type Identity struct {
+	ID        int64     `json:"id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginWithIdentity returns the user linked to (provider, subject). On the
+// first login a new user without a password is created, or, if both the
+// provider and our account verified the email, the identity is linked to
+// the existing user with that email. Any other login with the email of an
+// existing user is rejected with ErrLinkRequired; the user links it with
+// LinkIdentity after logging in.
+func LoginWithIdentity(ctx context.Context, provider, subject, email string, emailVerified bool) (*User, error) {
+	ctx, span := tracer.Start(ctx, "LoginWithIdentity")
+	defer span.End()
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var userId int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?",
+		provider, subject).Scan(&userId)
+	if err == nil {
+		_ = tx.Rollback()
+		return GetUserByID(ctx, userId)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if email == "" {
+		return nil, ErrIdentityEmail
+	}
+
+	var userVerified bool
+	err = tx.QueryRowContext(ctx, "SELECT id, email_verified FROM users WHERE email = ?", email).Scan(&userId, &userVerified)
+	switch {
+	case err == nil && !(emailVerified && userVerified):
+		return nil, ErrLinkRequired
+	case errors.Is(err, sql.ErrNoRows):
+		// An empty password never matches any hash, so the account can
+		// only be reached through its linked identities.
+		result, err := tx.ExecContext(ctx, "INSERT INTO users (email, password, email_verified) VALUES (?, '', ?)", email, emailVerified)
+		if err != nil {
+			return nil, err
+		}
+		userId, err = result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+	VALUES (?, ?, ?, ?, ?)`, userId, provider, subject, email, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetUserByID(ctx, userId)
+}
+
+// LinkIdentity links (provider, subject) to the user, who logged in to
+// their account first. Linking an identity that is already linked to the
+// user is not an error.
+func LinkIdentity(ctx context.Context, userId int64, provider, subject, email string) error {
+	ctx, span := tracer.Start(ctx, "LinkIdentity")
+	defer span.End()
+
+	_, err := db.DB.ExecContext(ctx, `
+	INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+	VALUES (?, ?, ?, ?, ?)`, userId, provider, subject, email, time.Now().UTC())
+	if !isUniqueViolation(err) {
+		return err
+	}
+
+	var linkedTo int64
+	err = db.DB.QueryRowContext(ctx,
+		"SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?",
+		provider, subject).Scan(&linkedTo)
+	if err != nil {
+		return err
+	}
+	if linkedTo != userId {
+		return ErrIdentityLinked
+	}
+	return nil
+}
+
+func GetIdentitiesByUser(ctx context.Context, userId int64) ([]Identity, error) {
+	ctx, span := tracer.Start(ctx, "GetIdentitiesByUser")
+	defer span.End()
+
+	query := `
+	SELECT id, provider, subject, email, created_at
+	FROM user_identities WHERE user_id = ? ORDER BY id`
+	rows, err := db.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	identities := []Identity{}
+
+	for rows.Next() {
+		var identity Identity
+		err := rows.Scan(
+			&identity.ID,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"REST_API/auth"
+	"REST_API/db"
+	"context"
+	"crypto/x509"
+	"errors"
+)
+
+var ErrUnknownServiceAccount = errors.New("unknown service account")
+
+// ServiceAccount lets a client certificate act as an existing user. The
+// certificate is matched by its subject common name.
+type ServiceAccount struct {
+	Subject string
+	// Email identifies the user the account acts as.
+	Email string
+	// Scopes restrict the account like an API key.
+	Scopes []string
+}
+
+// ClientCertAuthenticator returns an auth.ClientCertValidator for
+// accounts. The user is looked up on every request, so an account stops
+// working as soon as its user is deleted.
+func ClientCertAuthenticator(accounts []ServiceAccount) auth.ClientCertValidator {
+	bySubject := make(map[string]ServiceAccount, len(accounts))
+	for _, account := range accounts {
+		bySubject[account.Subject] = account
+	}
+
+	return func(ctx context.Context, cert *x509.Certificate) (int64, []string, error) {
+		ctx, span := tracer.Start(ctx, "AuthenticateClientCert")
+		defer span.End()
+
+		account, ok := bySubject[cert.Subject.CommonName]
+		if !ok {
+			return 0, nil, ErrUnknownServiceAccount
+		}
+
+		var userId int64
+		err := db.DB.QueryRowContext(ctx, "SELECT id FROM users WHERE email = ?", account.Email).Scan(&userId)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		return userId, account.Scopes, nil
+	}
+}
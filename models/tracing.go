@@ -0,0 +1,7 @@
+package models
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts one span per model operation. The SQL statements it runs
+// are traced as child spans by the database driver.
+var tracer = otel.Tracer("REST_API/models")
@@ -3,18 +3,48 @@ package models
 import (
 	"REST_API/auth"
 	"REST_API/db"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"time"
 )
 
+var (
+	ErrInvalidCredentials = forbidden("Invalid credentials")
+	ErrIncorrectPassword  = forbidden("Current password is incorrect")
+	ErrEmailTaken         = conflict("Email already in use")
+	ErrInvalidToken       = invalid("Invalid or expired token")
+	ErrLoginRequired      = forbidden("Log in again to confirm this change")
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+// User is safe to serialize: the password (plain or hashed) never leaves
+// the process.
 type User struct {
-	ID       int64
-	Email    string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	ID          int64  `json:"id"`
+	Email       string `json:"email" binding:"required,email,max=254" mod:"trim"`
+	Password    string `json:"-"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Locale      string `json:"locale"`
 }
 
-func (u *User) Save() error {
+func (u *User) Save(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "User.Save")
+	defer span.End()
+
+	err := Validate(ctx, u)
+	if err != nil {
+		return err
+	}
+
 	query := "INSERT INTO users (email, password) VALUES (?, ?)"
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := db.DB.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -25,7 +55,10 @@ func (u *User) Save() error {
 		return err
 	}
 
-	result, err := stmt.Exec(u.Email, hashedPassword)
+	result, err := stmt.ExecContext(ctx, u.Email, hashedPassword)
+	if isUniqueViolation(err) {
+		return ErrEmailTaken
+	}
 	if err != nil {
 		return err
 	}
@@ -36,21 +69,306 @@ func (u *User) Save() error {
 	return err
 }
 
-func (u *User) ValidateCredentials() error {
+func (u *User) ValidateCredentials(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "User.ValidateCredentials")
+	defer span.End()
+
 	query := "SELECT id, password FROM users WHERE email = ?"
-	row := db.DB.QueryRow(query, u.Email)
+	row := db.DB.QueryRowContext(ctx, query, u.Email)
 
 	var retrievedPassword string
 	err := row.Scan(&u.ID, &retrievedPassword)
 	if err != nil {
-		return errors.New("invalid credentials")
+		return ErrInvalidCredentials
 	}
 
 	passwordIsWalid := auth.CheckPasswordHash(u.Password, retrievedPassword)
 
 	if !passwordIsWalid {
-		return errors.New("invalid credentials")
+		return ErrInvalidCredentials
+	}
+
+	if auth.NeedsRehash(retrievedPassword) {
+		// A failed upgrade must not fail the login; the old hash is still
+		// valid and we will try again next time.
+		_ = u.rehashPassword(ctx, retrievedPassword)
 	}
 
 	return nil
 }
+
+// rehashPassword replaces an outdated hash with one from the current hasher.
+// The old hash is part of the WHERE clause so a concurrent password change
+// is never overwritten.
+func (u *User) rehashPassword(ctx context.Context, oldHash string) error {
+	hashedPassword, err := auth.HashPassword(u.Password)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE users SET password = ? WHERE id = ? AND password = ?"
+	stmt, err := db.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	_, err = stmt.ExecContext(ctx, hashedPassword, u.ID, oldHash)
+	return err
+}
+
+func GetUserByID(ctx context.Context, id int64) (*User, error) {
+	ctx, span := tracer.Start(ctx, "GetUserByID")
+	defer span.End()
+
+	query := `
+	SELECT id, email, display_name, avatar_url, locale
+	FROM users WHERE id = ?`
+	row := db.DB.QueryRowContext(ctx, query, id)
+
+	var user User
+	err := row.Scan(&user.ID, &user.Email, &user.DisplayName, &user.AvatarURL, &user.Locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetUsersByIDs loads the users with the given IDs in a single query.
+// Unknown IDs are left out of the result.
+func GetUsersByIDs(ctx context.Context, ids []int64) (map[int64]*User, error) {
+	ctx, span := tracer.Start(ctx, "GetUsersByIDs")
+	defer span.End()
+
+	users := make(map[int64]*User, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	marks, args := placeholders(ids)
+	query := `
+	SELECT id, email, display_name, avatar_url, locale
+	FROM users WHERE id IN (` + marks + `)`
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.ID, &user.Email, &user.DisplayName, &user.AvatarURL, &user.Locale)
+		if err != nil {
+			return nil, err
+		}
+		users[user.ID] = &user
+	}
+
+	return users, rows.Err()
+}
+
+func (u *User) UpdateProfile(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "User.UpdateProfile")
+	defer span.End()
+
+	query := `
+	UPDATE users
+	SET display_name = ?, avatar_url = ?, locale = ?
+	WHERE id = ?`
+	stmt, err := db.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	_, err = stmt.ExecContext(ctx, u.DisplayName, u.AvatarURL, u.Locale, u.ID)
+	return err
+}
+
+type recentLoginKey struct{}
+
+// RecentLogin marks ctx as coming from a login moments ago. Accounts
+// created through single sign-on have no password, so a fresh login is
+// what confirms their sensitive changes instead.
+func RecentLogin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recentLoginKey{}, true)
+}
+
+func isRecentLogin(ctx context.Context) bool {
+	recent, _ := ctx.Value(recentLoginKey{}).(bool)
+	return recent
+}
+
+// Reauthenticate confirms that the caller is the user before a sensitive
+// change, with the current password or, for accounts without one, a
+// RecentLogin.
+func (u *User) Reauthenticate(ctx context.Context, currentPassword string) error {
+	return u.checkPassword(ctx, currentPassword)
+}
+
+// checkPassword verifies password against the stored hash for u.ID. It
+// guards sensitive changes, so a mismatch is ErrIncorrectPassword.
+// Accounts without a password need a RecentLogin instead.
+func (u *User) checkPassword(ctx context.Context, password string) error {
+	var hashedPassword string
+	err := db.DB.QueryRowContext(ctx, "SELECT password FROM users WHERE id = ?", u.ID).Scan(&hashedPassword)
+	if err != nil {
+		return ErrIncorrectPassword
+	}
+
+	if hashedPassword == "" {
+		if !isRecentLogin(ctx) {
+			return ErrLoginRequired
+		}
+		return nil
+	}
+
+	if !auth.CheckPasswordHash(password, hashedPassword) {
+		return ErrIncorrectPassword
+	}
+
+	return nil
+}
+
+func (u *User) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "User.ChangePassword")
+	defer span.End()
+
+	err := u.checkPassword(ctx, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE users SET password = ? WHERE id = ?"
+	stmt, err := db.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	_, err = stmt.ExecContext(ctx, hashedPassword, u.ID)
+	return err
+}
+
+// RequestEmailChange records newEmail as pending and returns the one-time
+// token that confirms it. Only a hash of the token is stored, and any
+// earlier pending change for the user is discarded.
+func (u *User) RequestEmailChange(ctx context.Context, currentPassword, newEmail string) (string, error) {
+	ctx, span := tracer.Start(ctx, "User.RequestEmailChange")
+	defer span.End()
+
+	err := u.checkPassword(ctx, currentPassword)
+	if err != nil {
+		return "", err
+	}
+
+	var taken int
+	err = db.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE email = ?", newEmail).Scan(&taken)
+	if err != nil {
+		return "", err
+	}
+	if taken > 0 {
+		return "", ErrEmailTaken
+	}
+
+	tokenBytes := make([]byte, 32)
+	_, err = rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM email_verifications WHERE user_id = ?", u.ID)
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+	INSERT INTO email_verifications (user_id, email, token_hash, expires_at)
+	VALUES (?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, u.ID, newEmail, hashToken(token), time.Now().Add(emailVerificationTTL))
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// ConfirmEmailChange applies the pending email change identified by token
+// and returns the updated user.
+func ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "ConfirmEmailChange")
+	defer span.End()
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+	SELECT user_id, email, expires_at
+	FROM email_verifications WHERE token_hash = ?`
+	var userId int64
+	var email string
+	var expiresAt time.Time
+	err = tx.QueryRowContext(ctx, query, hashToken(token)).Scan(&userId, &email, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM email_verifications WHERE user_id = ?", userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(expiresAt) {
+		// Commit so the expired token is cleaned up.
+		_ = tx.Commit()
+		return nil, ErrInvalidToken
+	}
+
+	var taken int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE email = ? AND id != ?", email, userId).Scan(&taken)
+	if err != nil {
+		return nil, err
+	}
+	if taken > 0 {
+		return nil, ErrEmailTaken
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET email = ?, email_verified = 1 WHERE id = ?", email, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetUserByID(ctx, userId)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,11 +1,14 @@
 package models
 
 import (
+	"REST_API/auth"
 	"REST_API/db"
 	"database/sql"
+	"strings"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func setupTestDB(t *testing.T) func() {
@@ -15,20 +18,16 @@ func setupTestDB(t *testing.T) func() {
 	if err != nil {
 		t.Fatalf("Failed to create test database: %v", err)
 	}
+	// Every connection to :memory: opens a separate database.
+	testDB.SetMaxOpenConns(1)
 
-	createUsersTable := `
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			email TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL
-		)`
-
-	_, err = testDB.Exec(createUsersTable)
+	err = db.Migrate(testDB)
 	if err != nil {
-		t.Fatalf("Failed to create users table: %v", err)
+		t.Fatalf("Failed to migrate test database: %v", err)
 	}
 
 	db.DB = testDB
+	eventCache.Clear()
 
 	return func() {
 		err := testDB.Close()
@@ -69,6 +68,14 @@ func TestUser_Save(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Invalid email",
+			fields: fields{
+				Email:    "not-an-email",
+				Password: "password123",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -77,7 +84,7 @@ func TestUser_Save(t *testing.T) {
 				Email:    tt.fields.Email,
 				Password: tt.fields.Password,
 			}
-			err := u.Save()
+			err := u.Save(t.Context())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Save() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -92,7 +99,7 @@ func TestUser_Save(t *testing.T) {
 			Email:    "unique@example.com",
 			Password: "password123",
 		}
-		err := u1.Save()
+		err := u1.Save(t.Context())
 		if err != nil {
 			t.Fatalf("First user save should succeed: %v", err)
 		}
@@ -101,7 +108,7 @@ func TestUser_Save(t *testing.T) {
 			Email:    "unique@example.com",
 			Password: "different123",
 		}
-		err = u2.Save()
+		err = u2.Save(t.Context())
 		if err == nil {
 			t.Error("Second user with duplicate email should fail")
 		}
@@ -116,7 +123,7 @@ func TestUser_ValidateCredentials(t *testing.T) {
 		Email:    "test@example.com",
 		Password: "correctpassword",
 	}
-	err := testUser.Save()
+	err := testUser.Save(t.Context())
 	if err != nil {
 		t.Fatalf("Failed to create test user: %v", err)
 	}
@@ -159,7 +166,7 @@ func TestUser_ValidateCredentials(t *testing.T) {
 				Email:    tt.email,
 				Password: tt.password,
 			}
-			err := u.ValidateCredentials()
+			err := u.ValidateCredentials(t.Context())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ValidateCredentials() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -169,3 +176,79 @@ func TestUser_ValidateCredentials(t *testing.T) {
 		})
 	}
 }
+
+func TestUser_ValidateCredentials_RehashesOutdatedHash(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	auth.SetPasswordHasher(auth.BcryptHasher{Cost: bcrypt.MinCost})
+	defer auth.SetPasswordHasher(auth.DefaultArgon2idHasher())
+
+	u := &User{
+		Email:    "legacy@example.com",
+		Password: "legacypassword",
+	}
+	err := u.Save(t.Context())
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	storedHash := func() string {
+		var hash string
+		err := db.DB.QueryRow("SELECT password FROM users WHERE id = ?", u.ID).Scan(&hash)
+		if err != nil {
+			t.Fatalf("Failed to read stored hash: %v", err)
+		}
+		return hash
+	}
+
+	if !strings.HasPrefix(storedHash(), "$2a$") {
+		t.Fatalf("Expected bcrypt hash, got %q", storedHash())
+	}
+
+	t.Run("Stronger bcrypt cost triggers rehash", func(t *testing.T) {
+		auth.SetPasswordHasher(auth.BcryptHasher{Cost: bcrypt.MinCost + 1})
+
+		err := (&User{Email: u.Email, Password: u.Password}).ValidateCredentials(t.Context())
+		if err != nil {
+			t.Fatalf("ValidateCredentials() error = %v", err)
+		}
+
+		cost, err := bcrypt.Cost([]byte(storedHash()))
+		if err != nil {
+			t.Fatalf("Stored hash is not bcrypt: %v", err)
+		}
+		if cost != bcrypt.MinCost+1 {
+			t.Errorf("Expected cost %d after rehash, got %d", bcrypt.MinCost+1, cost)
+		}
+	})
+
+	t.Run("Switching to argon2id migrates bcrypt hash", func(t *testing.T) {
+		auth.SetPasswordHasher(auth.DefaultArgon2idHasher())
+
+		err := (&User{Email: u.Email, Password: u.Password}).ValidateCredentials(t.Context())
+		if err != nil {
+			t.Fatalf("ValidateCredentials() error = %v", err)
+		}
+
+		hash := storedHash()
+		if !strings.HasPrefix(hash, "$argon2id$") {
+			t.Fatalf("Expected argon2id hash after login, got %q", hash)
+		}
+		if auth.NeedsRehash(hash) {
+			t.Error("Upgraded hash should not need another rehash")
+		}
+	})
+
+	t.Run("Upgraded hash still validates", func(t *testing.T) {
+		err := (&User{Email: u.Email, Password: u.Password}).ValidateCredentials(t.Context())
+		if err != nil {
+			t.Errorf("ValidateCredentials() error = %v", err)
+		}
+
+		err = (&User{Email: u.Email, Password: "wrongpassword"}).ValidateCredentials(t.Context())
+		if err == nil {
+			t.Error("Wrong password should fail after upgrade")
+		}
+	})
+}
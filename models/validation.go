@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation rules are declared on the structs themselves with the
+// `binding` tag, the same tag gin uses, and `mod:"trim"` marks string
+// fields whose surrounding whitespace is dropped before validation. The
+// HTTP handlers and the models both run Validate, so every way into the
+// database applies the same rules.
+//
+// Besides the built-in validators two rules are available:
+//
+//	future            the time must be after now
+//	future_on_create  like future, but only when ctx comes from Creating
+var validate = newValidator()
+
+type creatingKey struct{}
+
+// Creating marks ctx as creating a new record, which enables create-only
+// rules such as future_on_create.
+func Creating(ctx context.Context) context.Context {
+	return context.WithValue(ctx, creatingKey{}, true)
+}
+
+func isCreating(ctx context.Context) bool {
+	creating, _ := ctx.Value(creatingKey{}).(bool)
+	return creating
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	_ = v.RegisterValidation("future", func(fl validator.FieldLevel) bool {
+		return isFuture(fl.Field())
+	})
+	_ = v.RegisterValidationCtx("future_on_create", func(ctx context.Context, fl validator.FieldLevel) bool {
+		return !isCreating(ctx) || isFuture(fl.Field())
+	})
+	return v
+}
+
+func isFuture(field reflect.Value) bool {
+	t, ok := field.Interface().(time.Time)
+	return ok && t.After(time.Now())
+}
+
+// Validate trims the fields of obj marked `mod:"trim"` and checks obj
+// against its binding rules. obj must be a pointer to a struct. All
+// violations are reported at once in an ErrValidation error.
+func Validate(ctx context.Context, obj any) error {
+	trimStrings(reflect.ValueOf(obj))
+
+	err := validate.StructCtx(ctx, obj)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	fields := make([]FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		fields = append(fields, FieldError{
+			Field:   fieldPath(fieldErr),
+			Message: validationMessage(fieldErr),
+		})
+	}
+	return invalid("Invalid input", fields...)
+}
+
+func trimStrings(value reflect.Value) {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		structField := value.Type().Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		if structField.Tag.Get("mod") != "trim" {
+			trimStrings(field)
+			continue
+		}
+
+		if field.Kind() == reflect.Pointer && !field.IsNil() {
+			field = field.Elem()
+		}
+		if field.Kind() == reflect.String {
+			field.SetString(strings.TrimSpace(field.String()))
+		}
+	}
+}
+
+// fieldPath drops the struct name from the namespace, leaving the JSON path
+// such as "scopes[0]".
+func fieldPath(fieldErr validator.FieldError) string {
+	_, path, found := strings.Cut(fieldErr.Namespace(), ".")
+	if !found {
+		return fieldErr.Field()
+	}
+	return path
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "bcp47_language_tag":
+		return "must be a BCP 47 language tag"
+	case "oneof":
+		return "must be one of: " + fieldErr.Param()
+	case "unique":
+		return "must not contain duplicates"
+	case "future", "future_on_create":
+		return "must be in the future"
+	case "min":
+		return "must be at least " + fieldErr.Param() + lengthUnit(fieldErr)
+	case "max":
+		return "must be at most " + fieldErr.Param() + lengthUnit(fieldErr)
+	default:
+		return "is invalid"
+	}
+}
+
+func lengthUnit(fieldErr validator.FieldError) string {
+	switch fieldErr.Kind() {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Map:
+		return " items"
+	default:
+		return ""
+	}
+}
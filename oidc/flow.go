@@ -0,0 +1,146 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AuthRequest is what we remember between redirecting the browser to the
+// provider and receiving the callback.
+type AuthRequest struct {
+	Provider     string
+	State        string
+	Nonce        string
+	CodeVerifier string
+	// Binding is also kept in a cookie of the browser that started the
+	// login. The callback must come from that browser, so a callback URL
+	// of someone else's login cannot sign a victim in to their account.
+	Binding   string
+	ExpiresAt time.Time
+	// LinkUserID is the user who asked to link the identity to their
+	// account, zero for a login.
+	LinkUserID int64
+}
+
+// StateStore keeps pending AuthRequests keyed by state. Take must remove
+// the request so a state can only be redeemed once.
+type StateStore interface {
+	Put(req AuthRequest) error
+	Take(state string) (AuthRequest, bool)
+}
+
+// MemoryStateStore is a StateStore for a single process.
+type MemoryStateStore struct {
+	mu       sync.Mutex
+	requests map[string]AuthRequest
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{requests: map[string]AuthRequest{}}
+}
+
+func (s *MemoryStateStore) Put(req AuthRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for state, pending := range s.requests {
+		if now.After(pending.ExpiresAt) {
+			delete(s.requests, state)
+		}
+	}
+
+	s.requests[req.State] = req
+	return nil
+}
+
+func (s *MemoryStateStore) Take(state string) (AuthRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.requests[state]
+	delete(s.requests, state)
+	if !ok || time.Now().After(req.ExpiresAt) {
+		return AuthRequest{}, false
+	}
+	return req, true
+}
+
+// NewAuthRequest creates fresh state, nonce, PKCE verifier and browser
+// binding values.
+func NewAuthRequest(provider string, ttl time.Duration) (AuthRequest, error) {
+	values := make([]string, 4)
+	for i := range values {
+		value, err := randomString()
+		if err != nil {
+			return AuthRequest{}, err
+		}
+		values[i] = value
+	}
+
+	return AuthRequest{
+		Provider:     provider,
+		State:        values[0],
+		Nonce:        values[1],
+		CodeVerifier: values[2],
+		Binding:      values[3],
+		ExpiresAt:    time.Now().Add(ttl),
+	}, nil
+}
+
+// CodeChallenge is the S256 PKCE challenge for the request's verifier.
+func (r AuthRequest) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(r.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]*Provider{}
+)
+
+// Register makes p available for login under p.Name().
+func Register(p *Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name()] = p
+}
+
+func Unregister(name string) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	delete(providers, name)
+}
+
+func Lookup(name string) (*Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Names lists the registered providers in alphabetical order.
+func Names() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
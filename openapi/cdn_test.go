@@ -0,0 +1,37 @@
+//go:build cdn
+
+package openapi
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that the pinned hashes match the assets on the CDN. It needs
+// network access, so it only runs with go test -tags cdn ./openapi.
+func TestUIIntegrity(t *testing.T) {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	for _, file := range []string{"swagger-ui.css", "swagger-ui-bundle.js"} {
+		t.Run(file, func(t *testing.T) {
+			resp, err := client.Get(swaggerUI + "/" + file)
+			if err != nil {
+				t.Fatalf("Failed to fetch %s: %v", file, err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			hash := sha512.New384()
+			_, err = io.Copy(hash, resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, "sha384-"+base64.StdEncoding.EncodeToString(hash.Sum(nil)), uiIntegrity[file],
+				"run go generate ./openapi")
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Package openapi serves the OpenAPI 3.1 description of the API and a
+// Swagger UI page for browsing it.
+package openapi
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spec is the OpenAPI document. It is maintained by hand next to the
+// routes; a test in the routes package fails when the two disagree.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// swaggerUI is the swagger-ui-dist release the page loads from the CDN.
+// After changing it, run go generate ./openapi to pin the new assets.
+const swaggerUI = "https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14"
+
+//go:generate go run ./internal/gensri -out integrity.go https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14 swagger-ui.css swagger-ui-bundle.js
+
+const uiScript = `SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui", validatorUrl: null});`
+
+var uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>API documentation</title>
+<link rel="stylesheet" href="` + swaggerUI + `/swagger-ui.css"` + pinned("swagger-ui.css") + `>
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="` + swaggerUI + `/swagger-ui-bundle.js"` + pinned("swagger-ui-bundle.js") + `></script>
+<script>` + uiScript + `</script>
+</body>
+</html>
+`
+
+// UIContentSecurityPolicy replaces the JSON-only policy on the Swagger UI
+// page. It allows the CDN assets, the inline bootstrap script by its hash
+// and the inline styles Swagger UI sets; requests only go to this origin.
+var UIContentSecurityPolicy = "default-src 'none'; " +
+	"script-src " + swaggerUI + "/ 'sha256-" + scriptHash(uiScript) + "'; " +
+	"style-src " + swaggerUI + "/ 'unsafe-inline'; " +
+	"img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'"
+
+// pinned returns the attributes that make the browser check a CDN asset
+// against its hash in uiIntegrity. Without a hash only crossorigin is set.
+func pinned(file string) string {
+	attributes := ` crossorigin="anonymous"`
+	if hash := uiIntegrity[file]; hash != "" {
+		attributes += ` integrity="` + hash + `"`
+	}
+	return attributes
+}
+
+func scriptHash(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Handler serves Spec.
+func Handler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", Spec)
+}
+
+// UI serves a Swagger UI page for Spec. It needs UIContentSecurityPolicy.
+func UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(uiPage))
+}
@@ -0,0 +1,56 @@
+// Package problem writes RFC 7807 "problem details" error responses.
+package problem
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentType = "application/problem+json"
+
+// requestIDHeader is set on the response by the request ID middleware.
+const requestIDHeader = "X-Request-ID"
+
+// Details is an RFC 7807 problem. RequestID and Errors are extension
+// members.
+type Details struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// New builds a problem for the current request.
+func New(c *gin.Context, status int, detail string) *Details {
+	return &Details{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		RequestID: c.Writer.Header().Get(requestIDHeader),
+	}
+}
+
+// Write aborts the request with the problem as its response. gin keeps a
+// Content-Type that is already set, so the body is rendered as JSON under
+// the problem media type.
+func Write(c *gin.Context, details *Details) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(details.Status, details)
+}
+
+// Abort responds with a problem built from status and detail.
+func Abort(c *gin.Context, status int, detail string) {
+	Write(c, New(c, status, detail))
+}
@@ -0,0 +1,7 @@
+// Package proto holds the protobuf definitions of the gRPC API. The Go
+// code next to them is generated; after changing a .proto file run
+// go generate ./proto with protoc, protoc-gen-go and protoc-gen-go-grpc
+// on PATH.
+package proto
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative events/v1/events.proto events/v1/auth.proto
@@ -0,0 +1,165 @@
+// Package pubsub fans messages out to in-process subscribers.
+package pubsub
+
+import (
+	"sync"
+	"time"
+)
+
+// Bus delivers every published message to every current subscriber.
+// Publish never blocks: a subscriber that falls more than its buffer
+// behind is dropped, its channel closed and Lagged set, so it can
+// resubscribe and catch up from the history or the source of truth.
+//
+// Messages get consecutive IDs. The IDs of a bus start at its creation
+// time in microseconds, so IDs from an earlier bus, for example before a
+// restart, are lower than any of its own and are reported as too old to
+// replay rather than mistaken for recent ones.
+type Bus[T any] struct {
+	buffer int
+
+	mu          sync.Mutex
+	subscribers map[*Subscription[T]]struct{}
+	nextID      uint64
+	// history is a ring of the last published messages; start is the
+	// index of the oldest.
+	history []Message[T]
+	start   int
+	limit   int
+}
+
+// Message is a published value and its ID.
+type Message[T any] struct {
+	ID    uint64
+	Value T
+}
+
+// Subscription receives the messages published after it was created.
+type Subscription[T any] struct {
+	bus *Bus[T]
+	c   chan Message[T]
+	// lagged is written under bus.mu before c is closed.
+	lagged bool
+}
+
+// New returns a bus whose subscribers buffer up to buffer messages each,
+// and which keeps the last history messages for SubscribeAfter.
+func New[T any](buffer, history int) *Bus[T] {
+	return &Bus[T]{
+		buffer:      buffer,
+		subscribers: make(map[*Subscription[T]]struct{}),
+		nextID:      uint64(time.Now().UnixMicro()),
+		limit:       history,
+	}
+}
+
+// Subscribe starts receiving messages. Callers must Close the
+// subscription when they are done with it.
+func (b *Bus[T]) Subscribe() *Subscription[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribe(nil)
+}
+
+// SubscribeAfter is Subscribe for a subscriber that already received the
+// messages up to lastID: the messages after it are delivered first. It
+// returns false if some of them are no longer in the history, or lastID
+// did not come from this bus; the subscription then starts with the next
+// message like one from Subscribe.
+func (b *Bus[T]) SubscribeAfter(lastID uint64) (*Subscription[T], bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	oldest := b.nextID - uint64(len(b.history))
+	if lastID+1 < oldest || lastID >= b.nextID {
+		return b.subscribe(nil), false
+	}
+
+	var missed []Message[T]
+	for i := range b.history {
+		msg := b.history[(b.start+i)%len(b.history)]
+		if msg.ID > lastID {
+			missed = append(missed, msg)
+		}
+	}
+	return b.subscribe(missed), true
+}
+
+// subscribe must be called with b.mu held.
+func (b *Bus[T]) subscribe(missed []Message[T]) *Subscription[T] {
+	s := &Subscription[T]{bus: b, c: make(chan Message[T], b.buffer+len(missed))}
+	for _, msg := range missed {
+		s.c <- msg
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// Publish hands value to every subscriber and returns its ID.
+func (b *Bus[T]) Publish(value T) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msg := Message[T]{ID: b.nextID, Value: value}
+	b.nextID++
+	b.remember(msg)
+
+	for s := range b.subscribers {
+		select {
+		case s.c <- msg:
+		default:
+			s.lagged = true
+			b.remove(s)
+		}
+	}
+	return msg.ID
+}
+
+// remember adds msg to the history, replacing the oldest message once it
+// is full. It must be called with b.mu held.
+func (b *Bus[T]) remember(msg Message[T]) {
+	switch {
+	case b.limit <= 0:
+	case len(b.history) < b.limit:
+		b.history = append(b.history, msg)
+	default:
+		b.history[b.start] = msg
+		b.start = (b.start + 1) % len(b.history)
+	}
+}
+
+// Subscribers returns the number of current subscribers.
+func (b *Bus[T]) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// remove must be called with b.mu held.
+func (b *Bus[T]) remove(s *Subscription[T]) {
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.c)
+	}
+}
+
+// C delivers the messages. It is closed when the subscription is closed
+// or dropped for lagging.
+func (s *Subscription[T]) C() <-chan Message[T] {
+	return s.c
+}
+
+// Lagged reports whether the subscription was dropped because its buffer
+// was full. Messages were lost; it is only meaningful once C is closed.
+func (s *Subscription[T]) Lagged() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.lagged
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription[T]) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// values drains a closed subscription
+func values(s *Subscription[int]) []int {
+	var received []int
+	for msg := range s.C() {
+		received = append(received, msg.Value)
+	}
+	return received
+}
+
+func TestBus_Publish(t *testing.T) {
+	bus := New[int](4, 0)
+	first, second := bus.Subscribe(), bus.Subscribe()
+	defer first.Close()
+
+	id := bus.Publish(1)
+	second.Close()
+	bus.Publish(2)
+
+	assert.Equal(t, Message[int]{ID: id, Value: 1}, <-first.C())
+	assert.Equal(t, Message[int]{ID: id + 1, Value: 2}, <-first.C(), "IDs are consecutive")
+	assert.Equal(t, []int{1}, values(second), "messages published before Close are kept")
+	assert.False(t, second.Lagged())
+	assert.Equal(t, 1, bus.Subscribers())
+
+	second.Close()
+}
+
+func TestBus_DropsLaggingSubscribers(t *testing.T) {
+	bus := New[int](2, 0)
+	slow := bus.Subscribe()
+	defer slow.Close()
+
+	for i := range 3 {
+		bus.Publish(i)
+	}
+
+	assert.Equal(t, []int{0, 1}, values(slow))
+	assert.True(t, slow.Lagged())
+	assert.Zero(t, bus.Subscribers())
+}
+
+func TestBus_SubscribeAfter(t *testing.T) {
+	bus := New[int](4, 3)
+	var ids []uint64
+	for i := range 5 {
+		ids = append(ids, bus.Publish(i))
+	}
+
+	t.Run("Replays the missed messages", func(t *testing.T) {
+		s, ok := bus.SubscribeAfter(ids[2])
+		assert.True(t, ok)
+		bus.Publish(5)
+		s.Close()
+		assert.Equal(t, []int{3, 4, 5}, values(s))
+	})
+
+	t.Run("Nothing missed", func(t *testing.T) {
+		last := bus.Publish(6)
+		s, ok := bus.SubscribeAfter(last)
+		assert.True(t, ok)
+		s.Close()
+		assert.Empty(t, values(s))
+	})
+
+	t.Run("Older than the history", func(t *testing.T) {
+		s, ok := bus.SubscribeAfter(ids[1])
+		assert.False(t, ok)
+		s.Close()
+		assert.Empty(t, values(s))
+	})
+
+	t.Run("Not from this bus", func(t *testing.T) {
+		_, ok := bus.SubscribeAfter(0)
+		assert.False(t, ok, "from an earlier bus")
+		_, ok = bus.SubscribeAfter(ids[4] + 100)
+		assert.False(t, ok, "never issued")
+	})
+}
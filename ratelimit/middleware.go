@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"REST_API/auth"
+	"REST_API/problem"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware limits the route group named group with policy. API keys are
+// counted per key, service accounts per account at the API key rate,
+// tokens per user and everything else per client IP, so it has to run
+// after auth.Authenticate on authenticated groups. Every limited response
+// carries the RateLimit-* headers from the IETF RateLimit header fields
+// draft; rejected requests get a 429 problem with Retry-After.
+//
+// If the store fails the request is let through: an unavailable limiter
+// should not take the API down with it.
+func Middleware(store Store, group string, policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, rate := identify(c, policy)
+		if rate.Unlimited() {
+			c.Next()
+			return
+		}
+
+		result, err := store.Take(c.Request.Context(), group+":"+key, rate)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "rate limit store failed", slog.String("group", group), slog.Any("error", err))
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("RateLimit-Policy", strconv.Itoa(rate.Requests)+";w="+seconds(rate.Period))
+		header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", seconds(result.Reset))
+
+		if !result.Allowed {
+			retryAfter := seconds(result.RetryAfter)
+			header.Set("Retry-After", retryAfter)
+			problem.Abort(c, http.StatusTooManyRequests, "Rate limit exceeded, retry in "+retryAfter+" seconds")
+			return
+		}
+		c.Next()
+	}
+}
+
+func identify(c *gin.Context, policy Policy) (string, Rate) {
+	principal, ok := auth.CurrentPrincipal(c)
+	switch {
+	case !ok:
+		return "ip:" + c.ClientIP(), policy.Anonymous
+	case principal.Method == auth.MethodAPIKey:
+		return "key:" + strconv.FormatInt(principal.KeyID, 10), policy.APIKey
+	case principal.Method == auth.MethodClientCert:
+		return "cert:" + principal.ServiceAccount, policy.APIKey
+	default:
+		return "user:" + strconv.FormatInt(principal.UserID, 10), policy.User
+	}
+}
+
+// seconds rounds d up to whole seconds, the unit of the RateLimit headers.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,125 @@
+// Package ratelimit implements token bucket rate limiting for gin routes.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rate allows Requests per Period. A bucket holds up to Requests tokens
+// and refills evenly over Period, so short bursts are allowed as long as
+// the average stays within the rate. The zero Rate is unlimited.
+type Rate struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (r Rate) Unlimited() bool {
+	return r.Requests <= 0 || r.Period <= 0
+}
+
+// Policy picks a rate by who is calling.
+type Policy struct {
+	Anonymous Rate
+	User      Rate
+	APIKey    Rate
+}
+
+// Result describes the bucket after a request has been counted.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a request would be allowed. It is zero
+	// when Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps one token bucket per key. The in-process MemoryStore limits
+// each instance on its own; a shared backend such as Redis can implement
+// Store to enforce one limit across instances.
+type Store interface {
+	Take(ctx context.Context, key string, rate Rate) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rate    Rate
+}
+
+// full reports whether the bucket has refilled completely by now, at which
+// point it carries no state and can be dropped.
+func (b *bucket) full(now time.Time) bool {
+	return now.Sub(b.updated) >= b.rate.Period
+}
+
+// MemoryStore is a Store for a single process. Idle buckets are dropped
+// lazily, so it needs no background goroutine.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, rate Rate) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	capacity := float64(rate.Requests)
+	// Nanoseconds per token, as a float: with more requests than
+	// nanoseconds in the period a token refills in less than one.
+	perToken := float64(rate.Period) / capacity
+
+	b, ok := s.buckets[key]
+	if !ok || b.rate != rate {
+		b = &bucket{tokens: capacity, updated: now, rate: rate}
+		s.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated)
+	b.tokens = math.Min(capacity, b.tokens+float64(elapsed)/perToken)
+	b.updated = now
+
+	result := Result{Limit: rate.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * perToken)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration((capacity - b.tokens) * perToken)
+
+	return result, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if b.full(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestStore returns a store whose clock only moves when advance is called
+func newTestStore() (*MemoryStore, func(time.Duration)) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	return store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	rate := Rate{Requests: 3, Period: 3 * time.Second}
+
+	t.Run("Allows a burst up to the limit", func(t *testing.T) {
+		store, _ := newTestStore()
+
+		for i := 2; i >= 0; i-- {
+			result, err := store.Take(t.Context(), "a", rate)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 3, result.Limit)
+			assert.Equal(t, i, result.Remaining)
+		}
+
+		result, err := store.Take(t.Context(), "a", rate)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Equal(t, time.Second, result.RetryAfter)
+		assert.Equal(t, 3*time.Second, result.Reset)
+	})
+
+	t.Run("Refills over time", func(t *testing.T) {
+		store, advance := newTestStore()
+		for range 3 {
+			_, _ = store.Take(t.Context(), "a", rate)
+		}
+
+		advance(time.Second)
+		result, _ := store.Take(t.Context(), "a", rate)
+		assert.True(t, result.Allowed)
+
+		result, _ = store.Take(t.Context(), "a", rate)
+		assert.False(t, result.Allowed)
+
+		advance(time.Hour)
+		result, _ = store.Take(t.Context(), "a", rate)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2, result.Remaining, "the bucket never holds more than the limit")
+	})
+
+	t.Run("More requests than nanoseconds in the period", func(t *testing.T) {
+		store, advance := newTestStore()
+		fast := Rate{Requests: 10, Period: 5 * time.Nanosecond}
+		for range 10 {
+			_, _ = store.Take(t.Context(), "a", fast)
+		}
+
+		result, _ := store.Take(t.Context(), "a", fast)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 5*time.Nanosecond, result.Reset)
+
+		advance(time.Nanosecond)
+		result, _ = store.Take(t.Context(), "a", fast)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 1, result.Remaining)
+	})
+
+	t.Run("Keys are independent", func(t *testing.T) {
+		store, _ := newTestStore()
+		for range 3 {
+			_, _ = store.Take(t.Context(), "a", rate)
+		}
+
+		result, _ := store.Take(t.Context(), "b", rate)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("Idle buckets are dropped", func(t *testing.T) {
+		store, advance := newTestStore()
+		_, _ = store.Take(t.Context(), "a", rate)
+		_, _ = store.Take(t.Context(), "b", Rate{Requests: 1, Period: time.Hour})
+
+		advance(2 * sweepInterval)
+		_, _ = store.Take(t.Context(), "c", rate)
+
+		assert.NotContains(t, store.buckets, "a")
+		assert.Contains(t, store.buckets, "b")
+		assert.Contains(t, store.buckets, "c")
+	})
+}
@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"REST_API/models"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type accountDeletion struct {
+	CurrentPassword string `json:"current_password"`
+	Events          string `json:"events" binding:"omitempty,oneof=delete transfer cancel"`
+	TransferTo      string `json:"transfer_to" binding:"required_if=Events transfer,omitempty,email" mod:"trim"`
+}
+
+func exportAccount(c *gin.Context) {
+	user := models.User{ID: c.GetInt64("userId")}
+	export, err := user.Export(c.Request.Context())
+	if err != nil {
+		fail(c, err, "Data could not be exported")
+		return
+	}
+
+	filename := fmt.Sprintf("user-%d-export", user.ID)
+
+	if c.Query("format") != "zip" {
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	archive, err := zipExport(export)
+	if err != nil {
+		fail(c, err, "Data could not be exported")
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// zipExport writes each part of the export as its own JSON file.
+func zipExport(export *models.UserExport) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"user.json", export.User},
+		{"events.json", export.Events},
+		{"registrations.json", export.Registrations},
+		{"api_keys.json", export.APIKeys},
+		{"identities.json", export.Identities},
+	}
+
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(file.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := archive.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func deleteAccount(c *gin.Context) {
+	var input accountDeletion
+	if !bindJSON(c, &input, "Invalid account deletion data") {
+		return
+	}
+
+	user := models.User{ID: c.GetInt64("userId")}
+	err := user.DeleteAccount(reauthContext(c), input.CurrentPassword, models.EventPolicy(input.Events), input.TransferTo)
+	if err != nil {
+		fail(c, err, "Account could not be deleted")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
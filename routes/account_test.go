@@ -0,0 +1,206 @@
+package routes
+
+import (
+	"REST_API/db"
+	"REST_API/models"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// countRows returns the result of a COUNT(*) query
+func countRows(t *testing.T, query string, args ...any) int {
+	var count int
+	err := db.DB.QueryRow(query, args...).Scan(&count)
+	assert.NoError(t, err)
+	return count
+}
+
+// Test GET /me/export
+func TestExportAccount(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	other := GetTestUsers()["user1"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+
+	ownEvent := createTestEvent(t, user.ID)
+	otherEvent := createTestEvent(t, other.ID)
+	assert.NoError(t, otherEvent.Register(t.Context(), user.ID))
+
+	t.Run("Export as JSON", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/me/export", token, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+		assert.NotContains(t, w.Body.String(), "password")
+
+		var export models.UserExport
+		err := json.Unmarshal(w.Body.Bytes(), &export)
+		assert.NoError(t, err)
+		assert.Equal(t, user.Email, export.User.Email)
+		assert.Len(t, export.Events, 1)
+		assert.Equal(t, ownEvent.ID, export.Events[0].ID)
+		assert.Len(t, export.Registrations, 1)
+		assert.Equal(t, otherEvent.ID, export.Registrations[0].EventID)
+	})
+
+	t.Run("Export as ZIP", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/me/export?format=zip", token, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		assert.NoError(t, err)
+
+		files := map[string][]byte{}
+		for _, f := range archive.File {
+			r, err := f.Open()
+			assert.NoError(t, err)
+			content, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			files[f.Name] = content
+		}
+
+		assert.Contains(t, files, "user.json")
+		assert.Contains(t, files, "events.json")
+		assert.Contains(t, files, "registrations.json")
+		assert.Contains(t, string(files["user.json"]), user.Email)
+	})
+}
+
+// Test DELETE /me
+func TestDeleteAccount(t *testing.T) {
+	t.Run("Wrong password keeps the account", func(t *testing.T) {
+		testDB := SetupTestDB(t)
+		defer testDB.Cleanup()
+
+		router := SetupTestRouter()
+		user := GetTestUsers()["testuser"]
+		token := GenerateTestJWT(t, user.ID, user.Email)
+
+		w := makeJSONRequest(t, router, http.MethodDelete, "/me", token, gin.H{"current_password": "wrongpassword"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, 1, countRows(t, "SELECT COUNT(*) FROM users WHERE id = ?", user.ID))
+	})
+
+	t.Run("Delete removes owned events and all their registrations", func(t *testing.T) {
+		testDB := SetupTestDB(t)
+		defer testDB.Cleanup()
+
+		router := SetupTestRouter()
+		user := GetTestUsers()["testuser"]
+		other := GetTestUsers()["user1"]
+		token := GenerateTestJWT(t, user.ID, user.Email)
+
+		ownEvent := createTestEvent(t, user.ID)
+		otherEvent := createTestEvent(t, other.ID)
+		assert.NoError(t, ownEvent.Register(t.Context(), other.ID))
+		assert.NoError(t, otherEvent.Register(t.Context(), user.ID))
+
+		w := makeJSONRequest(t, router, http.MethodDelete, "/me", token, gin.H{
+			"current_password": user.Password,
+			"events":           "delete",
+		})
+		assertResponseAndMessage(t, w, http.StatusOK, "Account deleted successfully", "message")
+
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM users WHERE id = ?", user.ID))
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM events WHERE id = ?", ownEvent.ID))
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM registrations WHERE event_id = ?", ownEvent.ID))
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM registrations WHERE user_id = ?", user.ID))
+		assert.Equal(t, 1, countRows(t, "SELECT COUNT(*) FROM events WHERE id = ?", otherEvent.ID))
+	})
+
+	t.Run("Transfer hands events to another user", func(t *testing.T) {
+		testDB := SetupTestDB(t)
+		defer testDB.Cleanup()
+
+		router := SetupTestRouter()
+		user := GetTestUsers()["testuser"]
+		other := GetTestUsers()["user1"]
+		attendee := GetTestUsers()["user2"]
+		token := GenerateTestJWT(t, user.ID, user.Email)
+
+		ownEvent := createTestEvent(t, user.ID)
+		assert.NoError(t, ownEvent.Register(t.Context(), attendee.ID))
+
+		w := makeJSONRequest(t, router, http.MethodDelete, "/me", token, gin.H{
+			"current_password": user.Password,
+			"events":           "transfer",
+			"transfer_to":      other.Email,
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		event, err := models.GetEventByID(t.Context(), ownEvent.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, other.ID, event.UserID)
+		verifyRegistrationCount(t, ownEvent.ID, attendee.ID, 1)
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM users WHERE id = ?", user.ID))
+	})
+
+	t.Run("Cancel keeps events without an owner", func(t *testing.T) {
+		testDB := SetupTestDB(t)
+		defer testDB.Cleanup()
+
+		router := SetupTestRouter()
+		user := GetTestUsers()["testuser"]
+		attendee := GetTestUsers()["user2"]
+		token := GenerateTestJWT(t, user.ID, user.Email)
+
+		ownEvent := createTestEvent(t, user.ID)
+		assert.NoError(t, ownEvent.Register(t.Context(), attendee.ID))
+
+		w := makeJSONRequest(t, router, http.MethodDelete, "/me", token, gin.H{
+			"current_password": user.Password,
+			"events":           "cancel",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM users WHERE id = ?", user.ID))
+
+		event, err := models.GetEventByID(t.Context(), ownEvent.ID)
+		assert.NoError(t, err)
+		assert.Zero(t, event.UserID)
+		assert.NotNil(t, event.CancelledAt)
+		verifyRegistrationCount(t, ownEvent.ID, attendee.ID, 1)
+
+		w = makeJSONRequest(t, router, http.MethodGet, "/v1/events/"+strconv.FormatInt(ownEvent.ID, 10), "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"cancelled_at":`)
+
+		other := GetTestUsers()["user1"]
+		w = makeRegistrationRequest(router, http.MethodPost, strconv.FormatInt(ownEvent.ID, 10), "Bearer "+GenerateTestJWT(t, other.ID, other.Email))
+		assert.Equal(t, http.StatusConflict, w.Code, "cancelled events take no registrations")
+	})
+
+	t.Run("Transfer requires an existing other user", func(t *testing.T) {
+		testDB := SetupTestDB(t)
+		defer testDB.Cleanup()
+
+		router := SetupTestRouter()
+		user := GetTestUsers()["testuser"]
+		token := GenerateTestJWT(t, user.ID, user.Email)
+
+		invalid := []gin.H{
+			{"current_password": user.Password, "events": "transfer"},
+			{"current_password": user.Password, "events": "transfer", "transfer_to": "nobody@example.com"},
+			{"current_password": user.Password, "events": "transfer", "transfer_to": user.Email},
+			{"current_password": user.Password, "events": "archive"},
+		}
+
+		for _, body := range invalid {
+			w := makeJSONRequest(t, router, http.MethodDelete, "/me", token, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, "body %v", body)
+		}
+		assert.Equal(t, 1, countRows(t, "SELECT COUNT(*) FROM users WHERE id = ?", user.ID))
+	})
+}
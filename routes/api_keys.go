@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"REST_API/models"
+	"REST_API/problem"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type apiKeyInput struct {
+	Name      string     `json:"name" binding:"required,max=100" mod:"trim"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,unique,dive,oneof=events:write registrations:write"`
+	ExpiresAt *time.Time `json:"expires_at" binding:"omitempty,future"`
+}
+
+func createAPIKey(c *gin.Context) {
+	var input apiKeyInput
+	if !bindJSON(c, &input, "Invalid API key data") {
+		return
+	}
+
+	apiKey := models.APIKey{
+		UserID:    c.GetInt64("userId"),
+		Name:      input.Name,
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+	}
+	key, err := apiKey.Save(c.Request.Context())
+	if err != nil {
+		fail(c, err, "API key could not be created")
+		return
+	}
+
+	// The key is only ever shown in this response.
+	c.JSON(http.StatusCreated, gin.H{"key": key, "api_key": apiKey})
+}
+
+func getAPIKeys(c *gin.Context) {
+	keys, err := models.GetAPIKeysByUser(c.Request.Context(), c.GetInt64("userId"))
+	if err != nil {
+		fail(c, err, "API keys could not be retrieved")
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func deleteAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		problem.Abort(c, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	err = models.DeleteAPIKey(c.Request.Context(), c.GetInt64("userId"), id)
+	if err != nil {
+		fail(c, err, "API key could not be deleted")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
@@ -0,0 +1,180 @@
+package routes
+
+import (
+	"REST_API/db"
+	"REST_API/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// allScopes grants a test key everything an API key can do
+var allScopes = []string{"events:write", "registrations:write"}
+
+// createTestAPIKey creates a key through the API and returns it with its ID
+func createTestAPIKey(t *testing.T, router *gin.Engine, token string, body gin.H) (string, int64) {
+	w := makeJSONRequest(t, router, http.MethodPost, "/me/api-keys", token, body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response struct {
+		Key    string        `json:"key"`
+		APIKey models.APIKey `json:"api_key"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Key)
+
+	return response.Key, response.APIKey.ID
+}
+
+// makeAPIKeyRequest sends a request authenticated with an API key header
+func makeAPIKeyRequest(router *gin.Engine, method, url, header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, nil)
+	req.Header.Set(header, value)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test POST/GET/DELETE /me/api-keys
+func TestAPIKeyManagement(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+
+	t.Run("Key is shown once and stored hashed", func(t *testing.T) {
+		key, id := createTestAPIKey(t, router, token, gin.H{"name": "ci", "scopes": allScopes})
+
+		var storedHash string
+		err := db.DB.QueryRow("SELECT key_hash FROM api_keys WHERE id = ?", id).Scan(&storedHash)
+		assert.NoError(t, err)
+		assert.NotEqual(t, key, storedHash)
+
+		w := makeJSONRequest(t, router, http.MethodGet, "/me/api-keys", token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), key)
+
+		var keys []models.APIKey
+		err = json.Unmarshal(w.Body.Bytes(), &keys)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+		assert.Equal(t, "ci", keys[0].Name)
+		assert.ElementsMatch(t, allScopes, keys[0].Scopes)
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		invalid := []gin.H{
+			{},
+			{"name": "no scopes"},
+			{"name": "empty scopes", "scopes": []string{}},
+			{"name": "bad scope", "scopes": []string{"admin"}},
+			{"name": "read scope", "scopes": []string{"events:read"}},
+			{"name": "expired", "scopes": allScopes, "expires_at": time.Now().Add(-time.Hour)},
+		}
+
+		for _, body := range invalid {
+			w := makeJSONRequest(t, router, http.MethodPost, "/me/api-keys", token, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, "body %v", body)
+		}
+	})
+
+	t.Run("Cannot delete another user's key", func(t *testing.T) {
+		_, id := createTestAPIKey(t, router, token, gin.H{"name": "mine", "scopes": allScopes})
+
+		other := GetTestUsers()["user1"]
+		otherToken := GenerateTestJWT(t, other.ID, other.Email)
+
+		w := makeJSONRequest(t, router, http.MethodDelete, "/me/api-keys/"+strconv.FormatInt(id, 10), otherToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// Test authenticating with API keys
+func TestAPIKeyAuthentication(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+	event := createTestEvent(t, user.ID)
+	registerURL := "/events/" + strconv.FormatInt(event.ID, 10) + "/register"
+
+	t.Run("X-API-Key header", func(t *testing.T) {
+		key, id := createTestAPIKey(t, router, token, gin.H{"name": "full", "scopes": allScopes})
+
+		w := makeAPIKeyRequest(router, http.MethodPost, registerURL, "X-API-Key", key)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		verifyRegistrationCount(t, event.ID, user.ID, 1)
+
+		var lastUsed *time.Time
+		err := db.DB.QueryRow("SELECT last_used_at FROM api_keys WHERE id = ?", id).Scan(&lastUsed)
+		assert.NoError(t, err)
+		assert.NotNil(t, lastUsed)
+	})
+
+	t.Run("Authorization ApiKey header", func(t *testing.T) {
+		key, _ := createTestAPIKey(t, router, token, gin.H{"name": "full", "scopes": allScopes})
+
+		w := makeAPIKeyRequest(router, http.MethodDelete, registerURL, "Authorization", "ApiKey "+key)
+		assert.Equal(t, http.StatusOK, w.Code)
+		verifyRegistrationCount(t, event.ID, user.ID, 0)
+	})
+
+	t.Run("Missing scope is forbidden", func(t *testing.T) {
+		key, _ := createTestAPIKey(t, router, token, gin.H{
+			"name":   "events only",
+			"scopes": []string{"events:write"},
+		})
+
+		w := makeAPIKeyRequest(router, http.MethodPost, registerURL, "X-API-Key", key)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		verifyRegistrationCount(t, event.ID, user.ID, 0)
+	})
+
+	t.Run("API keys cannot manage the account", func(t *testing.T) {
+		key, _ := createTestAPIKey(t, router, token, gin.H{"name": "full", "scopes": allScopes})
+
+		w := makeAPIKeyRequest(router, http.MethodGet, "/me/api-keys", "X-API-Key", key)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Expired key is rejected", func(t *testing.T) {
+		key, id := createTestAPIKey(t, router, token, gin.H{
+			"name":       "short lived",
+			"scopes":     allScopes,
+			"expires_at": time.Now().Add(time.Hour),
+		})
+
+		_, err := db.DB.Exec("UPDATE api_keys SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute).UTC(), id)
+		assert.NoError(t, err)
+
+		w := makeAPIKeyRequest(router, http.MethodPost, registerURL, "X-API-Key", key)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Revoked key is rejected", func(t *testing.T) {
+		key, id := createTestAPIKey(t, router, token, gin.H{"name": "revoked", "scopes": allScopes})
+
+		w := makeJSONRequest(t, router, http.MethodDelete, "/me/api-keys/"+strconv.FormatInt(id, 10), token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = makeAPIKeyRequest(router, http.MethodPost, registerURL, "X-API-Key", key)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Unknown key is rejected", func(t *testing.T) {
+		w := makeAPIKeyRequest(router, http.MethodPost, registerURL, "X-API-Key", "rak_doesnotexist")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
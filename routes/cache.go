@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notModified marks a public response as cacheable until lastModified
+// changes. It answers 304 and returns true when the client's copy from
+// If-Modified-Since is still current.
+func notModified(c *gin.Context, lastModified time.Time) bool {
+	if routeConfig.CacheMaxAge > 0 {
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(routeConfig.CacheMaxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "public, no-cache")
+	}
+	// HTTP dates have whole seconds, so a change later in the same second
+	// would not be noticed. Responses get no validator until their last
+	// change is in a past second.
+	if lastModified.IsZero() || !lastModified.Before(time.Now().Truncate(time.Second)) {
+		return false
+	}
+
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	if err != nil || lastModified.After(since) {
+		return false
+	}
+	c.AbortWithStatus(http.StatusNotModified)
+	return true
+}
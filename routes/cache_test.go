@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"REST_API/db"
+	"REST_API/models"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// backdateEvents moves every event's last change to the given time
+func backdateEvents(t *testing.T, updatedAt time.Time) {
+	_, err := db.DB.Exec("UPDATE events SET updated_at = ?", updatedAt.UTC())
+	assert.NoError(t, err)
+	// The cache does not see writes that bypass the models
+	models.ConfigureEventCache(1000, time.Minute)
+}
+
+// Test Cache-Control, Last-Modified and If-Modified-Since on event reads
+func TestConditionalRequests(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+	event := createTestEvent(t, user.ID)
+	other := createTestEvent(t, user.ID)
+	eventURL := "/events/" + strconv.FormatInt(event.ID, 10)
+
+	lastWeek := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	backdateEvents(t, lastWeek)
+	ifModifiedSince := func(since time.Time) http.Header {
+		return http.Header{"If-Modified-Since": {since.Format(http.TimeFormat)}}
+	}
+
+	t.Run("Responses carry validators", func(t *testing.T) {
+		for _, url := range []string{"/events", eventURL} {
+			w := makeRequestFrom(router, http.MethodGet, url, "192.0.2.1:1234", nil)
+
+			assert.Equal(t, http.StatusOK, w.Code, url)
+			assert.Equal(t, "public, no-cache", w.Header().Get("Cache-Control"), url)
+			assert.Equal(t, "Thu, 01 Jan 2026 12:00:00 GMT", w.Header().Get("Last-Modified"), url)
+		}
+	})
+
+	t.Run("Unchanged resources are not sent again", func(t *testing.T) {
+		for _, url := range []string{"/events", eventURL} {
+			w := makeRequestFrom(router, http.MethodGet, url, "192.0.2.1:1234", ifModifiedSince(lastWeek))
+
+			assert.Equal(t, http.StatusNotModified, w.Code, url)
+			assert.Empty(t, w.Body.String(), url)
+			assert.Equal(t, "Thu, 01 Jan 2026 12:00:00 GMT", w.Header().Get("Last-Modified"), url)
+		}
+	})
+
+	t.Run("Older copies are replaced", func(t *testing.T) {
+		w := makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.1:1234", ifModifiedSince(lastWeek.Add(-time.Second)))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Updates change Last-Modified", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPut, eventURL, token, gin.H{
+			"name":        "Updated",
+			"description": "Updated",
+			"location":    "Updated",
+			"date_time":   time.Now().Add(time.Hour),
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		for _, url := range []string{"/events", eventURL} {
+			w := makeRequestFrom(router, http.MethodGet, url, "192.0.2.1:1234", ifModifiedSince(lastWeek))
+			assert.Equal(t, http.StatusOK, w.Code, url)
+			assert.Empty(t, w.Header().Get("Last-Modified"), "changes in the current second are not validated")
+		}
+
+		backdateEvents(t, lastWeek)
+	})
+
+	t.Run("Deleting an event changes the list", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodDelete, "/events/"+strconv.FormatInt(other.ID, 10), token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.1:1234", ifModifiedSince(lastWeek))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), `"id":`+strconv.FormatInt(other.ID, 10)+`,`)
+	})
+
+	t.Run("Configured max age", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		config := DefaultConfig()
+		config.CacheMaxAge = 30 * time.Second
+		RegisterRoutes(router, config)
+
+		w := makeRequestFrom(router, http.MethodGet, eventURL, "192.0.2.1:1234", nil)
+		assert.Equal(t, "public, max-age=30", w.Header().Get("Cache-Control"))
+	})
+}
+
+// Test that large event lists are compressed
+func TestCompression(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	for range 20 {
+		createTestEvent(t, user.ID)
+	}
+
+	w := makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.1:1234", http.Header{"Accept-Encoding": {"gzip, br"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+
+	w = makeRequestFrom(router, http.MethodGet, "/events/1", "192.0.2.1:1234", http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "small responses are not compressed")
+}
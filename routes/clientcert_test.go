@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"REST_API/models"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeClientCertRequest sends a request as if the TLS handshake had
+// verified a client certificate for commonName
+func makeClientCertRequest(router *gin.Engine, method, url, commonName string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, nil)
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test authenticating service accounts with TLS client certificates
+func TestClientCertAuthentication(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	user := GetTestUsers()["testuser"]
+	event := createTestEvent(t, user.ID)
+	registerURL := "/events/" + strconv.FormatInt(event.ID, 10) + "/register"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	config := DefaultConfig()
+	config.ServiceAccounts = []models.ServiceAccount{
+		{Subject: "billing-service", Email: user.Email, Scopes: []string{"events:write", "registrations:write"}},
+		{Subject: "reporting", Email: user.Email, Scopes: []string{"events:write"}},
+		{Subject: "orphan", Email: "nobody@example.com", Scopes: []string{"registrations:write"}},
+	}
+	RegisterRoutes(router, config)
+
+	t.Run("Service account acts as its user", func(t *testing.T) {
+		w := makeClientCertRequest(router, http.MethodPost, registerURL, "billing-service")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		verifyRegistrationCount(t, event.ID, user.ID, 1)
+	})
+
+	t.Run("Scopes are enforced", func(t *testing.T) {
+		w := makeClientCertRequest(router, http.MethodDelete, registerURL, "reporting")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		verifyRegistrationCount(t, event.ID, user.ID, 1)
+	})
+
+	t.Run("Service accounts cannot manage the account", func(t *testing.T) {
+		w := makeClientCertRequest(router, http.MethodGet, "/me", "billing-service")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Unknown certificates are not authenticated", func(t *testing.T) {
+		for _, commonName := range []string{"intruder", "orphan"} {
+			w := makeClientCertRequest(router, http.MethodPost, registerURL, commonName)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, commonName)
+		}
+	})
+
+	t.Run("Certificates are ignored without service accounts", func(t *testing.T) {
+		w := makeClientCertRequest(SetupTestRouter(), http.MethodPost, registerURL, "billing-service")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"REST_API/models"
+	"REST_API/problem"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// fail hands err to handleErrors. message is the detail sent to the
+// client if err turns out to be an internal error.
+func fail(c *gin.Context, err error, message string) {
+	_ = c.Error(err).SetMeta(message)
+	c.Abort()
+}
+
+// handleErrors is the single place where errors recorded with fail become
+// problem responses. Domain errors from models map to their status code;
+// anything else is logged with its cause and reported as a generic 500
+// that carries the request ID.
+func handleErrors(c *gin.Context) {
+	c.Next()
+
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+	last := c.Errors.Last()
+
+	var domainErr *models.Error
+	if errors.As(last.Err, &domainErr) {
+		details := problem.New(c, domainStatus(domainErr), domainErr.Message)
+		for _, field := range domainErr.Fields {
+			details.Errors = append(details.Errors, problem.FieldError{Field: field.Field, Message: field.Message})
+		}
+		problem.Write(c, details)
+		return
+	}
+
+	message, ok := last.Meta.(string)
+	if !ok {
+		message = "Internal server error"
+	}
+	slog.ErrorContext(c.Request.Context(), message, slog.Any("error", last.Err))
+	problem.Abort(c, http.StatusInternalServerError, message)
+}
+
+func domainStatus(err *models.Error) int {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrValidation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// bindJSON binds the request body into obj and validates it with the
+// model rules. On failure it responds with a 400 problem listing every
+// invalid field and returns false.
+func bindJSON(c *gin.Context, obj any, message string) bool {
+	return bindJSONContext(c, c.Request.Context(), obj, message)
+}
+
+// bindJSONContext is bindJSON with a validation context, for example one
+// from models.Creating.
+func bindJSONContext(c *gin.Context, ctx context.Context, obj any, message string) bool {
+	err := c.ShouldBindJSON(obj)
+	if tooLarge(c, err) {
+		return false
+	}
+	if err == nil {
+		err = models.Validate(ctx, obj)
+	}
+	if err == nil {
+		return true
+	}
+
+	details := problem.New(c, http.StatusBadRequest, message)
+	var domainErr *models.Error
+	if errors.As(err, &domainErr) {
+		for _, field := range domainErr.Fields {
+			details.Errors = append(details.Errors, problem.FieldError{Field: field.Field, Message: field.Message})
+		}
+	}
+	problem.Write(c, details)
+	return false
+}
+
+// tooLarge responds with 413 and returns true if err comes from a body
+// over the limit set by limitBody.
+func tooLarge(c *gin.Context, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+	problem.Abort(c, http.StatusRequestEntityTooLarge, "Request body must be at most "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+	return true
+}
+
+// useModelValidation turns off gin's own validator. Request bodies are
+// validated by bindJSON through models.Validate instead, so the HTTP API
+// and the models share one rule set and nothing is validated twice.
+func useModelValidation() {
+	binding.Validator = nil
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"REST_API/problem"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeProblem checks the problem media type and decodes the body
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder, expectedStatus int) problem.Details {
+	assert.Equal(t, expectedStatus, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+	var details problem.Details
+	err := json.Unmarshal(w.Body.Bytes(), &details)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedStatus, details.Status)
+	assert.Equal(t, http.StatusText(expectedStatus), details.Title)
+	assert.Equal(t, w.Header().Get("X-Request-ID"), details.RequestID)
+	return details
+}
+
+// Test RFC 7807 problem responses
+func TestProblemResponses(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	users := GetTestUsers()
+	owner := users["testuser"]
+	other := users["user1"]
+	ownerToken := GenerateTestJWT(t, owner.ID, owner.Email)
+	otherToken := GenerateTestJWT(t, other.ID, other.Email)
+	event := createTestEvent(t, owner.ID)
+	eventURL := "/events/" + strconv.FormatInt(event.ID, 10)
+
+	t.Run("Not found", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/events/999", "", nil)
+
+		details := decodeProblem(t, w, http.StatusNotFound)
+		assert.Equal(t, "Event not found", details.Detail)
+		assert.Equal(t, "/events/999", details.Instance)
+	})
+
+	t.Run("Field errors", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/events", ownerToken, gin.H{
+			"location":  "Somewhere",
+			"date_time": time.Now().Add(time.Hour),
+		})
+
+		details := decodeProblem(t, w, http.StatusBadRequest)
+		assert.Equal(t, "Invalid event data", details.Detail)
+		assert.Contains(t, details.Errors, problem.FieldError{Field: "name", Message: "is required"})
+		assert.Contains(t, details.Errors, problem.FieldError{Field: "description", Message: "is required"})
+	})
+
+	t.Run("Update by another user is forbidden", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPut, eventURL, otherToken, gin.H{
+			"name":        "Hijacked",
+			"description": "Not mine",
+			"location":    "Elsewhere",
+			"date_time":   time.Now().Add(time.Hour),
+		})
+
+		details := decodeProblem(t, w, http.StatusForbidden)
+		assert.Equal(t, "Only the owner can change this event", details.Detail)
+	})
+
+	t.Run("Delete by another user is forbidden", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodDelete, eventURL, otherToken, nil)
+		decodeProblem(t, w, http.StatusForbidden)
+	})
+
+	t.Run("Update with invalid body does not update", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPut, eventURL, ownerToken, gin.H{"name": "Only a name"})
+		decodeProblem(t, w, http.StatusBadRequest)
+
+		w = makeJSONRequest(t, router, http.MethodGet, eventURL, "", nil)
+		assert.Contains(t, w.Body.String(), `"name":"Test Event"`)
+	})
+
+	t.Run("Body too large", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/events", ownerToken, gin.H{
+			"name":        "Too large",
+			"description": strings.Repeat("x", 1<<20),
+			"location":    "Somewhere",
+			"date_time":   time.Now().Add(time.Hour),
+		})
+
+		details := decodeProblem(t, w, http.StatusRequestEntityTooLarge)
+		assert.Equal(t, "Request body must be at most 1048576 bytes", details.Detail)
+	})
+
+	t.Run("Missing authentication", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/events", "", nil)
+
+		details := decodeProblem(t, w, http.StatusUnauthorized)
+		assert.Equal(t, "Authentication required", details.Detail)
+		assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Unknown route", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/does-not-exist", "", nil)
+		decodeProblem(t, w, http.StatusNotFound)
+	})
+}
@@ -2,49 +2,71 @@ package routes
 
 import (
 	"REST_API/models"
+	"REST_API/problem"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+// eventID parses the :id path parameter, responding with a 400 problem
+// when it is not a number.
+func eventID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		problem.Abort(c, http.StatusBadRequest, "Invalid event ID")
+		return 0, false
+	}
+	return id, true
+}
+
 func getEvents(c *gin.Context) {
-	events, err := models.GetAllEvents()
+	// Read before the events, so a change in between is not hidden
+	// behind an older Last-Modified.
+	lastModified, err := models.EventsLastModified(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err, "Events could not be retrieved")
+		return
+	}
+	if notModified(c, lastModified) {
+		return
+	}
+
+	events, err := models.GetAllEvents(c.Request.Context())
+	if err != nil {
+		fail(c, err, "Events could not be retrieved")
 		return
 	}
 	c.JSON(http.StatusOK, events)
 }
 
 func getEventByID(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+	id, ok := eventID(c)
+	if !ok {
 		return
 	}
 
-	event, err := models.GetEventByID(id)
+	event, err := models.GetEventByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		fail(c, err, "Event could not be retrieved")
+		return
+	}
+	if notModified(c, event.UpdatedAt) {
 		return
 	}
 	c.JSON(http.StatusOK, event)
 }
 
 func createEvent(c *gin.Context) {
-
 	var event models.Event
-	err := c.ShouldBindJSON(&event)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event data"})
+	if !bindJSONContext(c, models.Creating(c.Request.Context()), &event, "Invalid event data") {
 		return
 	}
 
 	event.UserID = c.GetInt64("userId")
-	err = event.Save()
+	err := event.Save(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Event could not be created"})
+		fail(c, err, "Event could not be created")
 		return
 	}
 
@@ -52,35 +74,35 @@ func createEvent(c *gin.Context) {
 }
 
 func updateEvents(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+	id, ok := eventID(c)
+	if !ok {
 		return
 	}
 
-	userIds := c.GetInt64("userId")
-	event, err := models.GetEventByID(id)
+	event, err := models.GetEventByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Event not found"})
+		fail(c, err, "Event could not be updated")
 		return
 	}
 
-	if event.UserID != userIds {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	err = event.CheckOwner(c.GetInt64("userId"))
+	if err != nil {
+		fail(c, err, "")
 		return
 	}
 
 	var updatedEvent models.Event
-	err = c.ShouldBindJSON(&updatedEvent)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event data"})
+	if !bindJSON(c, &updatedEvent, "Invalid event data") {
+		return
 	}
 
 	updatedEvent.ID = id
+	updatedEvent.UserID = event.UserID
+	updatedEvent.CreatedAt = event.CreatedAt
 
-	err = updatedEvent.Update()
+	err = updatedEvent.Update(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Event could not be updated"})
+		fail(c, err, "Event could not be updated")
 		return
 	}
 
@@ -88,26 +110,26 @@ func updateEvents(c *gin.Context) {
 }
 
 func deleteEvent(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+	id, ok := eventID(c)
+	if !ok {
 		return
 	}
 
-	userIds := c.GetInt64("userId")
-	event, err := models.GetEventByID(id)
+	event, err := models.GetEventByID(c.Request.Context(), id)
 	if err != nil {
+		fail(c, err, "Event could not be deleted")
 		return
 	}
 
-	if event.UserID != userIds {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	err = event.CheckOwner(c.GetInt64("userId"))
+	if err != nil {
+		fail(c, err, "")
 		return
 	}
 
-	err = event.Delete()
+	err = event.Delete(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Event could not be deleted"})
+		fail(c, err, "Event could not be deleted")
 		return
 	}
 
@@ -2,14 +2,17 @@ package routes
 
 import (
 	"REST_API/models"
+	"REST_API/problem"
 	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,7 +26,7 @@ func createTestEvent(t *testing.T, userID int64) *models.Event {
 		UserID:      userID,
 	}
 
-	err := event.Save()
+	err := event.Save(t.Context())
 	if err != nil {
 		t.Fatalf("Failed to create test event: %v", err)
 	}
@@ -93,7 +96,7 @@ func TestGetEventByID(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "Event not found")
+		assert.Contains(t, response["detail"], "Event not found")
 	})
 
 	t.Run("Get event by malformed ID", func(t *testing.T) {
@@ -107,7 +110,7 @@ func TestGetEventByID(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "Invalid event ID")
+		assert.Contains(t, response["detail"], "Invalid event ID")
 	})
 }
 
@@ -184,7 +187,40 @@ func TestCreateEvent(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "Invalid event data")
+		assert.Contains(t, response["detail"], "Invalid event data")
+	})
+
+	t.Run("Create event reports every invalid field", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/events", token, gin.H{
+			"name":        strings.Repeat("x", 101),
+			"description": "   ",
+			"location":    "Somewhere",
+			"date_time":   time.Now().Add(-time.Hour),
+		})
+
+		details := decodeProblem(t, w, http.StatusBadRequest)
+		assert.ElementsMatch(t, []problem.FieldError{
+			{Field: "name", Message: "must be at most 100 characters"},
+			{Field: "description", Message: "is required"},
+			{Field: "date_time", Message: "must be in the future"},
+		}, details.Errors)
+	})
+
+	t.Run("Create event trims whitespace", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/events", token, gin.H{
+			"name":        "  Padded Event  ",
+			"description": "Description\n",
+			"location":    "\tSomewhere",
+			"date_time":   time.Now().Add(time.Hour),
+		})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var createdEvent models.Event
+		err := json.Unmarshal(w.Body.Bytes(), &createdEvent)
+		assert.NoError(t, err)
+		assert.Equal(t, "Padded Event", createdEvent.Name)
+		assert.Equal(t, "Description", createdEvent.Description)
+		assert.Equal(t, "Somewhere", createdEvent.Location)
 	})
 }
 
@@ -240,7 +276,7 @@ func TestUpdateEvent(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
 }
 
@@ -273,7 +309,7 @@ func TestDeleteEvent(t *testing.T) {
 		assert.Contains(t, response["message"], "Event deleted successfully")
 
 		// Verify the event is actually deleted
-		_, err = models.GetEventByID(event.ID)
+		_, err = models.GetEventByID(t.Context(), event.ID)
 		assert.Error(t, err)
 	})
 }
@@ -0,0 +1,274 @@
+package routes
+
+import (
+	"REST_API/tracing"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type graphQLResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// graphQL posts query to /graphql and decodes the response
+func graphQL(t *testing.T, router *gin.Engine, token, query string, variables map[string]any) graphQLResponse {
+	body := gin.H{"query": query}
+	if variables != nil {
+		body["variables"] = variables
+	}
+	w := makeJSONRequest(t, router, http.MethodPost, "/graphql", token, body)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response graphQLResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+// errorCode returns the code of the only error in response
+func errorCode(t *testing.T, response graphQLResponse) string {
+	if !assert.Len(t, response.Errors, 1) {
+		return ""
+	}
+	code, _ := response.Errors[0].Extensions["code"].(string)
+	return code
+}
+
+// Test reading events with their organizer and attendees in one request
+func TestGraphQL_Query(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	users := GetTestUsers()
+	first := createTestEventForRegistration(t, users["testuser"].ID)
+	second := createTestEventForRegistration(t, users["user1"].ID)
+	createTestEventForRegistration(t, users["user1"].ID)
+	assert.NoError(t, first.Register(t.Context(), users["user1"].ID))
+	assert.NoError(t, first.Register(t.Context(), users["user2"].ID))
+
+	query := `query($after: String) {
+		events(first: 2, after: $after) {
+			nodes { id organizer { id email } attendeeCount registered }
+			pageInfo { endCursor hasNextPage }
+		}
+	}`
+
+	t.Run("Anonymous", func(t *testing.T) {
+		response := graphQL(t, router, "", query, nil)
+		assert.Empty(t, response.Errors)
+
+		events := response.Data["events"].(map[string]any)
+		nodes := events["nodes"].([]any)
+		assert.Len(t, nodes, 2)
+		assert.Equal(t, map[string]any{
+			"id":            strconv.FormatInt(first.ID, 10),
+			"organizer":     map[string]any{"id": "1", "email": nil},
+			"attendeeCount": float64(2),
+			"registered":    false,
+		}, nodes[0])
+		assert.Equal(t, float64(0), nodes[1].(map[string]any)["attendeeCount"])
+		assert.Equal(t, true, events["pageInfo"].(map[string]any)["hasNextPage"])
+	})
+
+	t.Run("Next page", func(t *testing.T) {
+		response := graphQL(t, router, "", query, nil)
+		cursor := response.Data["events"].(map[string]any)["pageInfo"].(map[string]any)["endCursor"]
+
+		response = graphQL(t, router, "", query, map[string]any{"after": cursor})
+		assert.Empty(t, response.Errors)
+		events := response.Data["events"].(map[string]any)
+		assert.Len(t, events["nodes"].([]any), 1)
+		assert.Equal(t, false, events["pageInfo"].(map[string]any)["hasNextPage"])
+	})
+
+	t.Run("Caller's own view", func(t *testing.T) {
+		token := GenerateTestJWT(t, users["user1"].ID, users["user1"].Email)
+		response := graphQL(t, router, token, `query($id: ID!) {
+			event(id: $id) { organizer { email } registered }
+			me { email }
+		}`, map[string]any{"id": second.ID})
+		assert.Empty(t, response.Errors)
+		assert.Equal(t, map[string]any{
+			"event": map[string]any{"organizer": map[string]any{"email": users["user1"].Email}, "registered": false},
+			"me":    map[string]any{"email": users["user1"].Email},
+		}, response.Data)
+	})
+
+	t.Run("Event not found", func(t *testing.T) {
+		response := graphQL(t, router, "", `{ event(id: 999) { id } }`, nil)
+		assert.Equal(t, "NOT_FOUND", errorCode(t, response))
+		assert.Equal(t, map[string]any{"event": nil}, response.Data)
+	})
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		response := graphQL(t, router, "", query, map[string]any{"after": "bogus"})
+		assert.Equal(t, "BAD_USER_INPUT", errorCode(t, response))
+	})
+
+	t.Run("me requires a user", func(t *testing.T) {
+		response := graphQL(t, router, "", `{ me { id } }`, nil)
+		assert.Equal(t, "UNAUTHENTICATED", errorCode(t, response))
+	})
+}
+
+// Test that mutations apply the same authorization as the REST endpoints
+func TestGraphQL_Mutations(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	users := GetTestUsers()
+	ownerToken := GenerateTestJWT(t, users["testuser"].ID, users["testuser"].Email)
+	otherToken := GenerateTestJWT(t, users["user1"].ID, users["user1"].Email)
+
+	input := map[string]any{
+		"name":        "GraphQL Meetup",
+		"description": "Talks about schemas",
+		"location":    "Room 1",
+		"dateTime":    time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339),
+	}
+	create := `mutation($input: EventInput!) { createEvent(input: $input) { id name organizer { id } } }`
+
+	var eventID string
+	t.Run("Create", func(t *testing.T) {
+		response := graphQL(t, router, ownerToken, create, map[string]any{"input": input})
+		assert.Empty(t, response.Errors)
+
+		event := response.Data["createEvent"].(map[string]any)
+		assert.Equal(t, "GraphQL Meetup", event["name"])
+		assert.Equal(t, map[string]any{"id": "1"}, event["organizer"])
+		eventID = event["id"].(string)
+	})
+
+	t.Run("Create requires authentication", func(t *testing.T) {
+		response := graphQL(t, router, "", create, map[string]any{"input": input})
+		assert.Equal(t, "UNAUTHENTICATED", errorCode(t, response))
+	})
+
+	t.Run("Invalid token is rejected before the query runs", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/graphql", "Bearer invalid", gin.H{"query": "{ me { id } }"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Create validates the input", func(t *testing.T) {
+		past := map[string]any{"name": "", "description": "d", "location": "l", "dateTime": "2000-01-01T00:00:00Z"}
+		response := graphQL(t, router, ownerToken, create, map[string]any{"input": past})
+		assert.Equal(t, "BAD_USER_INPUT", errorCode(t, response))
+		assert.ElementsMatch(t, []any{
+			map[string]any{"field": "name", "message": "is required"},
+			map[string]any{"field": "dateTime", "message": "must be in the future"},
+		}, response.Errors[0].Extensions["fields"])
+	})
+
+	update := `mutation($id: ID!, $input: EventInput!) { updateEvent(id: $id, input: $input) { name } }`
+
+	t.Run("Only the owner can update", func(t *testing.T) {
+		changed := map[string]any{"name": "Renamed", "description": "d", "location": "l", "dateTime": input["dateTime"]}
+		response := graphQL(t, router, otherToken, update, map[string]any{"id": eventID, "input": changed})
+		assert.Equal(t, "FORBIDDEN", errorCode(t, response))
+
+		response = graphQL(t, router, ownerToken, update, map[string]any{"id": eventID, "input": changed})
+		assert.Empty(t, response.Errors)
+		assert.Equal(t, map[string]any{"updateEvent": map[string]any{"name": "Renamed"}}, response.Data)
+	})
+
+	register := `mutation($id: ID!) { registerForEvent(id: $id) { attendeeCount registered } }`
+
+	t.Run("Register", func(t *testing.T) {
+		response := graphQL(t, router, otherToken, register, map[string]any{"id": eventID})
+		assert.Empty(t, response.Errors)
+		assert.Equal(t, map[string]any{"registerForEvent": map[string]any{"attendeeCount": float64(1), "registered": true}}, response.Data)
+
+		response = graphQL(t, router, otherToken, register, map[string]any{"id": eventID})
+		assert.Equal(t, "CONFLICT", errorCode(t, response))
+	})
+
+	t.Run("Unregister", func(t *testing.T) {
+		response := graphQL(t, router, otherToken, `mutation($id: ID!) { unregisterFromEvent(id: $id) { attendeeCount } }`, map[string]any{"id": eventID})
+		assert.Empty(t, response.Errors)
+		assert.Equal(t, map[string]any{"unregisterFromEvent": map[string]any{"attendeeCount": float64(0)}}, response.Data)
+	})
+
+	t.Run("API keys need the scope", func(t *testing.T) {
+		key, _ := createTestAPIKey(t, router, ownerToken, gin.H{"name": "events only", "scopes": []string{"events:write"}})
+
+		response := graphQL(t, router, "ApiKey "+key, register, map[string]any{"id": eventID})
+		assert.Equal(t, "FORBIDDEN", errorCode(t, response))
+		assert.Equal(t, "API key lacks scope registrations:write", response.Errors[0].Message)
+
+		response = graphQL(t, router, "ApiKey "+key, `{ me { id } }`, nil)
+		assert.Equal(t, "FORBIDDEN", errorCode(t, response))
+	})
+}
+
+// Test the depth and complexity limits and malformed requests
+func TestGraphQL_Limits(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+
+	t.Run("Too complex", func(t *testing.T) {
+		response := graphQL(t, router, "", `{ events(first: 100) {
+			nodes { id name description location dateTime createdAt updatedAt attendeeCount organizer { id displayName avatarUrl } }
+		} }`, nil)
+		assert.Equal(t, "QUERY_TOO_COMPLEX", errorCode(t, response))
+		assert.Nil(t, response.Data)
+	})
+
+	t.Run("Page too large", func(t *testing.T) {
+		response := graphQL(t, router, "", `{ events(first: 500) { nodes { id } } }`, nil)
+		assert.Equal(t, "BAD_USER_INPUT", errorCode(t, response))
+	})
+
+	t.Run("Invalid query", func(t *testing.T) {
+		response := graphQL(t, router, "", `{ events { unknown } }`, nil)
+		assert.Len(t, response.Errors, 1)
+		assert.Nil(t, response.Data)
+	})
+
+	t.Run("Not a GraphQL request", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/graphql", "", gin.H{"mutation": "{}"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// Test that the organizers and attendee counts of a page are each loaded
+// with a single query
+func TestGraphQL_Batching(t *testing.T) {
+	exporter := tracing.InMemory()
+
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	users := GetTestUsers()
+	for _, organizer := range []string{"testuser", "user1", "user2"} {
+		event := createTestEventForRegistration(t, users[organizer].ID)
+		assert.NoError(t, event.Register(t.Context(), users["logintest"].ID))
+	}
+
+	exporter.Reset()
+	response := graphQL(t, router, "", `{ events { nodes { attendeeCount organizer { id } } } }`, nil)
+	assert.Empty(t, response.Errors)
+	assert.Len(t, response.Data["events"].(map[string]any)["nodes"], 3)
+
+	spans := map[string]int{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name]++
+	}
+	assert.Equal(t, 1, spans["ListEvents"])
+	assert.Equal(t, 1, spans["CountRegistrations"])
+	assert.Equal(t, 1, spans["GetUsersByIDs"])
+	assert.Zero(t, spans["GetUserByID"])
+}
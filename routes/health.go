@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"REST_API/db"
+	"REST_API/problem"
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// draining is set once shutdown starts so /readyz takes the instance out
+// of rotation while in-flight requests finish.
+var draining atomic.Bool
+
+// drain is closed while draining, to end the event streams, which would
+// otherwise hold up shutdown until it times out.
+var (
+	drainMu sync.Mutex
+	drain   = make(chan struct{})
+)
+
+// SetDraining marks the server as shutting down.
+func SetDraining(value bool) {
+	drainMu.Lock()
+	defer drainMu.Unlock()
+
+	if draining.Swap(value) == value {
+		return
+	}
+	if value {
+		close(drain)
+	} else {
+		drain = make(chan struct{})
+	}
+}
+
+// drained returns a channel that is closed once the server is draining.
+func drained() <-chan struct{} {
+	drainMu.Lock()
+	defer drainMu.Unlock()
+	return drain
+}
+
+// metricsAuth only lets scrapers with the bearer token through, since the
+// metrics reveal traffic and internals that are not meant for clients.
+func metricsAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			problem.Abort(c, http.StatusNotFound, "Metrics are disabled")
+			return
+		}
+
+		sent, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			problem.Abort(c, http.StatusUnauthorized, "A valid metrics token is required")
+			return
+		}
+		c.Next()
+	}
+}
+
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func readyz(c *gin.Context) {
+	if draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "Server is shutting down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	err := db.DB.PingContext(ctx)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "Database is unreachable"})
+		return
+	}
+
+	version, err := db.SchemaVersion(db.DB)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "Could not read schema version"})
+		return
+	}
+	if version != db.LatestSchemaVersion() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":         "unavailable",
+			"error":          "Database migrations are not current",
+			"schema_version": version,
+			"latest_version": db.LatestSchemaVersion(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "schema_version": version})
+}
+
+type buildInfo struct {
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	Modified  bool   `json:"modified"`
+}
+
+// readBuildInfo is a variable so tests can supply build metadata, which
+// is not embedded in test binaries.
+var readBuildInfo = debug.ReadBuildInfo
+
+func version(c *gin.Context) {
+	info, ok := readBuildInfo()
+	if !ok {
+		problem.Abort(c, http.StatusInternalServerError, "Build information is not available")
+		return
+	}
+
+	result := buildInfo{
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			result.Revision = setting.Value
+		case "vcs.time":
+			result.BuildTime = setting.Value
+		case "vcs.modified":
+			result.Modified = setting.Value == "true"
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
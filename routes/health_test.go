@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"REST_API/db"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test GET /healthz and GET /readyz
+func TestHealthEndpoints(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+
+	t.Run("Liveness", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/healthz", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+	})
+
+	t.Run("Ready with current migrations", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/readyz", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"ok"`)
+	})
+
+	t.Run("Not ready with pending migrations", func(t *testing.T) {
+		_, err := db.DB.Exec("PRAGMA user_version = 1")
+		assert.NoError(t, err)
+		defer func() {
+			_, _ = db.DB.Exec("PRAGMA user_version = " + strconv.Itoa(db.LatestSchemaVersion()))
+		}()
+
+		w := makeJSONRequest(t, router, http.MethodGet, "/readyz", "", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "migrations")
+	})
+
+	t.Run("Not ready while draining", func(t *testing.T) {
+		SetDraining(true)
+		defer SetDraining(false)
+
+		w := makeJSONRequest(t, router, http.MethodGet, "/readyz", "", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+		w = makeJSONRequest(t, router, http.MethodGet, "/healthz", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code, "liveness is unaffected by draining")
+	})
+
+	t.Run("Not ready without a database", func(t *testing.T) {
+		err := testDB.testDB.Close()
+		assert.NoError(t, err)
+
+		w := makeJSONRequest(t, router, http.MethodGet, "/readyz", "", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "Database is unreachable")
+	})
+}
+
+// Test GET /version
+func TestVersion(t *testing.T) {
+	router := SetupTestRouter()
+
+	original := readBuildInfo
+	defer func() { readBuildInfo = original }()
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			GoVersion: "go1.25.0",
+			Main:      debug.Module{Path: "REST_API", Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "abc123"},
+				{Key: "vcs.time", Value: "2026-01-02T03:04:05Z"},
+				{Key: "vcs.modified", Value: "true"},
+			},
+		}, true
+	}
+
+	w := makeJSONRequest(t, router, http.MethodGet, "/version", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var info buildInfo
+	err := json.Unmarshal(w.Body.Bytes(), &info)
+	assert.NoError(t, err)
+	assert.Equal(t, buildInfo{
+		Module:    "REST_API",
+		Version:   "v1.2.3",
+		GoVersion: "go1.25.0",
+		Revision:  "abc123",
+		BuildTime: "2026-01-02T03:04:05Z",
+		Modified:  true,
+	}, info)
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return nil, false }
+	w = makeJSONRequest(t, router, http.MethodGet, "/version", "", nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"REST_API/models"
+	"REST_API/problem"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLease is how long a key stays claimed by a request that has
+// not finished. It outlasts the default write timeout, so only a request lost with
+// its server leaves the claim to expire and be taken over by a retry.
+const idempotencyLease = time.Minute
+
+// recordingWriter keeps a copy of the response body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent makes a request with an Idempotency-Key header safe to retry.
+// Keys are scoped to the user. The first response is stored for
+// routeConfig.IdempotencyTTL and replayed to retries of the same request;
+// reusing a key for a different request is rejected with 422. Server
+// errors are not stored, so a retry runs the request again.
+func idempotent(c *gin.Context) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		c.Next()
+		return
+	}
+	if !validIdempotencyKey(key) {
+		problem.Abort(c, http.StatusBadRequest, "Idempotency-Key must be at most 255 printable characters")
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if tooLarge(c, err) {
+		return
+	}
+	if err != nil {
+		problem.Abort(c, http.StatusBadRequest, "Request body could not be read")
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	ctx := c.Request.Context()
+	userId := c.GetInt64("userId")
+	fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+	claimId, stored, err := models.BeginIdempotentRequest(ctx, userId, key, fingerprint, idempotencyLease)
+	switch {
+	case errors.Is(err, models.ErrIdempotencyKeyReused):
+		problem.Abort(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+		return
+	case errors.Is(err, models.ErrIdempotencyKeyInFlight):
+		c.Header("Retry-After", "1")
+		problem.Abort(c, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+		return
+	case err != nil:
+		fail(c, err, "Request could not be processed")
+		return
+	case stored != nil:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+		return
+	}
+
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	// The outcome is recorded even if the client has gone, or the key
+	// would stay claimed until the lease runs out.
+	ctx = context.WithoutCancel(ctx)
+	// Errors recorded with fail are only written by handleErrors, after
+	// this returns; they are released like server errors and run again.
+	if !writer.Written() || writer.Status() >= http.StatusInternalServerError {
+		err = models.ReleaseIdempotencyKey(ctx, claimId)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not release idempotency key", "error", err)
+		}
+		return
+	}
+
+	err = models.CompleteIdempotentRequest(ctx, claimId, models.IdempotentResponse{
+		Status:      writer.Status(),
+		ContentType: writer.Header().Get("Content-Type"),
+		Body:        writer.body.Bytes(),
+	}, routeConfig.IdempotencyTTL)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not store idempotent response", "error", err)
+	}
+}
+
+// requestFingerprint identifies a request, so that a key cannot be reused
+// for another endpoint or body.
+func requestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// validIdempotencyKey allows printable ASCII, such as UUIDs, up to 255
+// characters.
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	return !strings.ContainsFunc(key, func(r rune) bool {
+		return r < ' ' || r > '~'
+	})
+}
@@ -0,0 +1,164 @@
+package routes
+
+import (
+	"REST_API/db"
+	"REST_API/models"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeIdempotentRequest sends a JSON request with an Idempotency-Key header
+func makeIdempotentRequest(t *testing.T, router *gin.Engine, method, url, token, key string, body any) *httptest.ResponseRecorder {
+	jsonData, err := json.Marshal(body)
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(method, url, bytes.NewReader(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Idempotency-Key", key)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test Idempotency-Key on POST /events and POST /events/:id/register
+func TestIdempotencyKey(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+	newEvent := gin.H{
+		"name":        "Retried Event",
+		"description": "Created once",
+		"location":    "Test Location",
+		"date_time":   time.Now().Add(24 * time.Hour).Format(time.RFC3339),
+	}
+	countEvents := func() int {
+		return countRows(t, "SELECT COUNT(*) FROM events WHERE name = ?", "Retried Event")
+	}
+
+	t.Run("Retried create replays the first response", func(t *testing.T) {
+		first := makeIdempotentRequest(t, router, http.MethodPost, "/events", token, "create-1", newEvent)
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+		retry := makeIdempotentRequest(t, router, http.MethodPost, "/events", token, "create-1", newEvent)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+		assert.JSONEq(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, 1, countEvents())
+	})
+
+	t.Run("Key reused with a different body", func(t *testing.T) {
+		changed := gin.H{"name": "Other Event", "description": "x", "location": "y", "date_time": newEvent["date_time"]}
+
+		w := makeIdempotentRequest(t, router, http.MethodPost, "/events", token, "create-1", changed)
+		problem := decodeProblem(t, w, http.StatusUnprocessableEntity)
+		assert.Contains(t, problem.Detail, "different request")
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM events WHERE name = ?", "Other Event"))
+	})
+
+	t.Run("Keys are scoped to the user", func(t *testing.T) {
+		other := GetTestUsers()["user1"]
+		otherToken := GenerateTestJWT(t, other.ID, other.Email)
+
+		w := makeIdempotentRequest(t, router, http.MethodPost, "/events", otherToken, "create-1", newEvent)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 2, countEvents())
+	})
+
+	t.Run("Retried registration is not a conflict", func(t *testing.T) {
+		event := createTestEvent(t, user.ID)
+		url := "/events/" + strconv.FormatInt(event.ID, 10) + "/register"
+
+		for range 2 {
+			w := makeIdempotentRequest(t, router, http.MethodPost, url, token, "register-1", nil)
+			assert.Equal(t, http.StatusCreated, w.Code)
+		}
+		verifyRegistrationCount(t, event.ID, user.ID, 1)
+	})
+
+	t.Run("Failed requests release the key", func(t *testing.T) {
+		w := makeIdempotentRequest(t, router, http.MethodPost, "/events/999/register", token, "release-1", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = makeIdempotentRequest(t, router, http.MethodPost, "/events", token, "release-1", newEvent)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("Expired keys run again", func(t *testing.T) {
+		before := countEvents()
+		_, err := db.DB.Exec("UPDATE idempotency_keys SET expires_at = ?", time.Now().Add(-time.Minute).UTC())
+		assert.NoError(t, err)
+
+		w := makeIdempotentRequest(t, router, http.MethodPost, "/events", token, "create-1", newEvent)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, before+1, countEvents())
+	})
+
+	t.Run("Request still in progress", func(t *testing.T) {
+		body, err := json.Marshal(newEvent)
+		assert.NoError(t, err)
+		fingerprint := requestFingerprint(http.MethodPost, "/events", body)
+		_, _, err = models.BeginIdempotentRequest(t.Context(), user.ID, "running-1", fingerprint, time.Hour)
+		assert.NoError(t, err)
+
+		w := makeIdempotentRequest(t, router, http.MethodPost, "/events", token, "running-1", newEvent)
+		decodeProblem(t, w, http.StatusConflict)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Abandoned claims are taken over", func(t *testing.T) {
+		body, err := json.Marshal(newEvent)
+		assert.NoError(t, err)
+		fingerprint := requestFingerprint(http.MethodPost, "/events", body)
+		_, _, err = models.BeginIdempotentRequest(t.Context(), user.ID, "abandoned-1", fingerprint, -time.Second)
+		assert.NoError(t, err)
+
+		before := countEvents()
+		w := makeIdempotentRequest(t, router, http.MethodPost, "/events", token, "abandoned-1", newEvent)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, before+1, countEvents())
+
+		var expiresAt time.Time
+		assert.NoError(t, db.DB.QueryRow("SELECT expires_at FROM idempotency_keys WHERE key = ?", "abandoned-1").Scan(&expiresAt))
+		assert.True(t, expiresAt.After(time.Now().Add(time.Hour)), "completed keys are kept for the TTL")
+	})
+
+	t.Run("Body too large", func(t *testing.T) {
+		large := gin.H{"name": "Large Event", "description": strings.Repeat("x", 1<<20)}
+
+		w := makeIdempotentRequest(t, router, http.MethodPost, "/events", token, "large-1", large)
+		decodeProblem(t, w, http.StatusRequestEntityTooLarge)
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM idempotency_keys WHERE key = ?", "large-1"))
+	})
+
+	t.Run("Invalid key", func(t *testing.T) {
+		w := makeIdempotentRequest(t, router, http.MethodPost, "/events", token, "bad\tkey", newEvent)
+		decodeProblem(t, w, http.StatusBadRequest)
+	})
+
+	t.Run("Requests without a key are not deduplicated", func(t *testing.T) {
+		before := countEvents()
+		for range 2 {
+			w := makeJSONRequest(t, router, http.MethodPost, "/events", token, newEvent)
+			assert.Equal(t, http.StatusCreated, w.Code)
+		}
+		assert.Equal(t, before+2, countEvents())
+	})
+}
@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"REST_API/logging"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureLogs sends the default logger to a buffer for the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(logging.NewContextHandler(slog.NewJSONHandler(&buf, nil))))
+	t.Cleanup(func() { slog.SetDefault(original) })
+	return &buf
+}
+
+// logRecords decodes every JSON record with the given message
+func logRecords(t *testing.T, buf *bytes.Buffer, message string) []map[string]any {
+	var records []map[string]any
+	scanner := bufio.NewScanner(strings.NewReader(buf.String()))
+	for scanner.Scan() {
+		var record map[string]any
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		assert.NoError(t, err)
+		if record["msg"] == message {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Test the X-Request-ID middleware
+func TestRequestID(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+
+	request := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		if requestID != "" {
+			req.Header.Set(logging.RequestIDHeader, requestID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Generated when missing", func(t *testing.T) {
+		first := request("").Header().Get(logging.RequestIDHeader)
+		second := request("").Header().Get(logging.RequestIDHeader)
+		assert.Len(t, first, 32)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("Propagated from the caller", func(t *testing.T) {
+		w := request("upstream-id-123")
+		assert.Equal(t, "upstream-id-123", w.Header().Get(logging.RequestIDHeader))
+	})
+
+	t.Run("Malformed IDs are replaced", func(t *testing.T) {
+		for _, id := range []string{strings.Repeat("a", 200), "has space", "new\nline"} {
+			w := request(id)
+			assert.NotEqual(t, id, w.Header().Get(logging.RequestIDHeader))
+			assert.Len(t, w.Header().Get(logging.RequestIDHeader), 32)
+		}
+	})
+}
+
+// Test the access log and internal error reporting
+func TestStructuredLogging(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+
+	t.Run("Access log records route, status, latency and user", func(t *testing.T) {
+		logs := captureLogs(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(logging.RequestIDHeader, "access-log-test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		records := logRecords(t, logs, "request")
+		if assert.Len(t, records, 1) {
+			record := records[0]
+			assert.Equal(t, "INFO", record["level"])
+			assert.Equal(t, "GET", record["method"])
+			assert.Equal(t, "/me", record["route"])
+			assert.Equal(t, float64(http.StatusOK), record["status"])
+			assert.Equal(t, float64(user.ID), record["user_id"])
+			assert.Equal(t, "access-log-test", record["request_id"])
+			assert.Contains(t, record, "latency")
+		}
+	})
+
+	t.Run("Missing event on delete returns 404", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodDelete, "/events/9999", token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"detail":"Event not found"`)
+	})
+
+	t.Run("Internal errors are logged, not returned", func(t *testing.T) {
+		logs := captureLogs(t)
+
+		err := testDB.testDB.Close()
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req.Header.Set(logging.RequestIDHeader, "internal-error-test")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), `"detail":"Events could not be retrieved"`)
+		assert.Contains(t, w.Body.String(), `"request_id":"internal-error-test"`)
+		assert.NotContains(t, w.Body.String(), "database is closed")
+
+		records := logRecords(t, logs, "Events could not be retrieved")
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "ERROR", records[0]["level"])
+			assert.Equal(t, "internal-error-test", records[0]["request_id"])
+			assert.Contains(t, records[0]["error"], "database is closed")
+		}
+
+		access := logRecords(t, logs, "request")
+		if assert.Len(t, access, 1) {
+			assert.Equal(t, "ERROR", access[0]["level"])
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package routes
+
+import (
+	"REST_API/db"
+	"REST_API/metrics"
+	"REST_API/models"
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMetricsToken = "scrape-secret"
+
+// setupMetricsRouter registers all routes with /metrics enabled
+func setupMetricsRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	config := DefaultConfig()
+	config.MetricsToken = testMetricsToken
+	RegisterRoutes(router, config)
+	return router
+}
+
+// scrapeMetric returns the value of the series named exactly by series,
+// or 0 when it has not been recorded yet
+func scrapeMetric(t *testing.T, router *gin.Engine, series string) float64 {
+	w := makeJSONRequest(t, router, http.MethodGet, "/metrics", "Bearer "+testMetricsToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), " ")
+		if found && name == series {
+			parsed, err := strconv.ParseFloat(value, 64)
+			assert.NoError(t, err)
+			return parsed
+		}
+	}
+	return 0
+}
+
+// Test GET /metrics
+func TestMetrics(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := setupMetricsRouter()
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+
+	t.Run("Token required", func(t *testing.T) {
+		for _, sent := range []string{"", "Bearer wrong", testMetricsToken} {
+			w := makeJSONRequest(t, router, http.MethodGet, "/metrics", sent, nil)
+			decodeProblem(t, w, http.StatusUnauthorized)
+			assert.Equal(t, `Bearer realm="metrics"`, w.Header().Get("WWW-Authenticate"))
+		}
+	})
+
+	t.Run("Disabled without a token", func(t *testing.T) {
+		w := makeJSONRequest(t, SetupTestRouter(), http.MethodGet, "/metrics", "Bearer "+testMetricsToken, nil)
+		decodeProblem(t, w, http.StatusNotFound)
+	})
+
+	t.Run("Requests are labelled by route template", func(t *testing.T) {
+		series := `rest_api_http_requests_total{method="GET",route="/events/:id",status="404"}`
+		before := scrapeMetric(t, router, series)
+
+		makeJSONRequest(t, router, http.MethodGet, "/events/9998", "", nil)
+		makeJSONRequest(t, router, http.MethodGet, "/events/9999", "", nil)
+
+		assert.Equal(t, before+2, scrapeMetric(t, router, series))
+		assert.Positive(t, scrapeMetric(t, router,
+			`rest_api_http_request_duration_seconds_count{method="GET",route="/events/:id"}`))
+	})
+
+	t.Run("Unknown paths share one series", func(t *testing.T) {
+		series := `rest_api_http_requests_total{method="GET",route="unmatched",status="404"}`
+		before := scrapeMetric(t, router, series)
+
+		makeJSONRequest(t, router, http.MethodGet, "/does-not-exist/1", "", nil)
+		makeJSONRequest(t, router, http.MethodGet, "/does-not-exist/2", "", nil)
+
+		assert.Equal(t, before+2, scrapeMetric(t, router, series))
+	})
+
+	t.Run("Login results", func(t *testing.T) {
+		success := `rest_api_logins_total{method="password",result="success"}`
+		failure := `rest_api_logins_total{method="password",result="failure"}`
+		successBefore := scrapeMetric(t, router, success)
+		failureBefore := scrapeMetric(t, router, failure)
+
+		login := GetTestUsers()["logintest"]
+		w := makeJSONRequest(t, router, http.MethodPost, "/login", "", gin.H{"email": login.Email, "password": login.Password})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = makeJSONRequest(t, router, http.MethodPost, "/login", "", gin.H{"email": login.Email, "password": "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		assert.Equal(t, successBefore+1, scrapeMetric(t, router, success))
+		assert.Equal(t, failureBefore+1, scrapeMetric(t, router, failure))
+	})
+
+	t.Run("Registrations", func(t *testing.T) {
+		event := createTestEventForRegistration(t, user.ID)
+		id := strconv.FormatInt(event.ID, 10)
+		registered := `rest_api_event_registrations_total{action="register"}`
+		unregistered := `rest_api_event_registrations_total{action="unregister"}`
+		eventRegistered := `rest_api_event_registrations_by_event_total{action="register",event_id="` + id + `"}`
+		registeredBefore := scrapeMetric(t, router, registered)
+		unregisteredBefore := scrapeMetric(t, router, unregistered)
+		eventRegisteredBefore := scrapeMetric(t, router, eventRegistered)
+
+		w := makeRegistrationRequest(router, http.MethodPost, id, token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = makeRegistrationRequest(router, http.MethodDelete, id, token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, registeredBefore+1, scrapeMetric(t, router, registered))
+		assert.Equal(t, unregisteredBefore+1, scrapeMetric(t, router, unregistered))
+		assert.Equal(t, eventRegisteredBefore+1, scrapeMetric(t, router, eventRegistered))
+	})
+
+	t.Run("Per-event series are bounded", func(t *testing.T) {
+		metrics.ConfigureEventSeries(1)
+		defer metrics.ConfigureEventSeries(1000)
+		first := strconv.FormatInt(createTestEventForRegistration(t, user.ID).ID, 10)
+		second := strconv.FormatInt(createTestEventForRegistration(t, user.ID).ID, 10)
+
+		w := makeRegistrationRequest(router, http.MethodPost, first, token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = makeRegistrationRequest(router, http.MethodPost, second, token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		scrape := makeJSONRequest(t, router, http.MethodGet, "/metrics", "Bearer "+testMetricsToken, nil)
+		assert.NotContains(t, scrape.Body.String(), `event_id="`+first+`"`, "the least recently active event is dropped")
+		assert.Contains(t, scrape.Body.String(), `event_id="`+second+`"`)
+	})
+
+	t.Run("Database pool gauges", func(t *testing.T) {
+		assert.Equal(t, float64(db.DB.Stats().OpenConnections), scrapeMetric(t, router, "rest_api_db_open_connections"))
+		assert.Equal(t, float64(db.DB.Stats().WaitCount), scrapeMetric(t, router, "rest_api_db_wait_count_total"))
+
+		w := makeJSONRequest(t, router, http.MethodGet, "/metrics", "Bearer "+testMetricsToken, nil)
+		assert.Contains(t, w.Body.String(), "rest_api_db_in_use_connections")
+	})
+
+	t.Run("Event cache", func(t *testing.T) {
+		event := createTestEvent(t, user.ID)
+		url := "/events/" + strconv.FormatInt(event.ID, 10)
+		hitsBefore := scrapeMetric(t, router, "rest_api_event_cache_hits_total")
+		missesBefore := scrapeMetric(t, router, "rest_api_event_cache_misses_total")
+		loadsBefore := scrapeMetric(t, router, "rest_api_event_cache_loads_total")
+
+		for range 3 {
+			w := makeJSONRequest(t, router, http.MethodGet, url, "", nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		assert.Equal(t, missesBefore+1, scrapeMetric(t, router, "rest_api_event_cache_misses_total"))
+		assert.Equal(t, loadsBefore+1, scrapeMetric(t, router, "rest_api_event_cache_loads_total"))
+		assert.Equal(t, hitsBefore+2, scrapeMetric(t, router, "rest_api_event_cache_hits_total"))
+		assert.Equal(t, float64(models.EventCacheStats().Entries), scrapeMetric(t, router, "rest_api_event_cache_entries"))
+	})
+}
@@ -0,0 +1,181 @@
+package routes
+
+import (
+	"REST_API/auth"
+	"REST_API/metrics"
+	"REST_API/models"
+	"REST_API/oidc"
+	"REST_API/problem"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var oidcStates oidc.StateStore = oidc.NewMemoryStateStore()
+
+// oidcBindingCookie holds the AuthRequest.Binding of the login the browser
+// started.
+const oidcBindingCookie = "oidc_binding"
+
+// startOIDC remembers request and binds it to the caller's browser.
+func startOIDC(c *gin.Context, request oidc.AuthRequest) error {
+	err := oidcStates.Put(request)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcBindingCookie,
+		Value:    request.Binding,
+		Path:     "/",
+		MaxAge:   int(routeConfig.OIDCStateTTL.Seconds()),
+		Secure:   c.Request.TLS != nil,
+		HttpOnly: true,
+		// Lax still sends it on the provider's top-level redirect back.
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// boundToBrowser reports whether the callback comes from the browser that
+// started request, and clears the cookie either way.
+func boundToBrowser(c *gin.Context, request oidc.AuthRequest) bool {
+	binding, err := c.Cookie(oidcBindingCookie)
+	http.SetCookie(c.Writer, &http.Cookie{Name: oidcBindingCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	return err == nil && subtle.ConstantTimeCompare([]byte(binding), []byte(request.Binding)) == 1
+}
+
+type identityLink struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+// linkAuthorization tells the client where to send the browser to link a
+// login; the provider then redirects it to the callback.
+type linkAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+func oidcLogin(c *gin.Context) {
+	provider, ok := oidc.Lookup(c.Param("provider"))
+	if !ok {
+		problem.Abort(c, http.StatusNotFound, "Unknown login provider")
+		return
+	}
+
+	request, err := oidc.NewAuthRequest(provider.Name(), routeConfig.OIDCStateTTL)
+	if err != nil {
+		fail(c, err, "Login could not be started")
+		return
+	}
+
+	err = startOIDC(c, request)
+	if err != nil {
+		fail(c, err, "Login could not be started")
+		return
+	}
+
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(request.State, request.Nonce, request.CodeChallenge()))
+}
+
+// linkIdentity starts linking a provider login to the caller's account.
+// The caller confirms it like other sensitive changes, since a linked
+// login can sign in to the account from then on.
+func linkIdentity(c *gin.Context) {
+	provider, ok := oidc.Lookup(c.Param("provider"))
+	if !ok {
+		problem.Abort(c, http.StatusNotFound, "Unknown login provider")
+		return
+	}
+
+	var input identityLink
+	if !bindJSON(c, &input, "Invalid link data") {
+		return
+	}
+
+	user := models.User{ID: c.GetInt64("userId")}
+	err := user.Reauthenticate(reauthContext(c), input.CurrentPassword)
+	if err != nil {
+		fail(c, err, "")
+		return
+	}
+
+	request, err := oidc.NewAuthRequest(provider.Name(), routeConfig.OIDCStateTTL)
+	if err != nil {
+		fail(c, err, "Linking could not be started")
+		return
+	}
+	request.LinkUserID = user.ID
+
+	err = startOIDC(c, request)
+	if err != nil {
+		fail(c, err, "Linking could not be started")
+		return
+	}
+
+	c.JSON(http.StatusOK, linkAuthorization{
+		AuthorizationURL: provider.AuthCodeURL(request.State, request.Nonce, request.CodeChallenge()),
+	})
+}
+
+func oidcCallback(c *gin.Context) {
+	provider, ok := oidc.Lookup(c.Param("provider"))
+	if !ok {
+		problem.Abort(c, http.StatusNotFound, "Unknown login provider")
+		return
+	}
+
+	if c.Query("error") != "" {
+		problem.Abort(c, http.StatusUnauthorized, "Login was denied by the provider")
+		return
+	}
+
+	request, ok := oidcStates.Take(c.Query("state"))
+	if !ok || request.Provider != provider.Name() {
+		problem.Abort(c, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+	if !boundToBrowser(c, request) {
+		problem.Abort(c, http.StatusBadRequest, "Login was started in another browser")
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(c.Request.Context(), c.Query("code"), request.CodeVerifier)
+	if err != nil {
+		metrics.RecordLogin(metrics.LoginOIDC, false)
+		problem.Abort(c, http.StatusUnauthorized, "Login could not be completed")
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(c.Request.Context(), rawIDToken, request.Nonce)
+	if err != nil {
+		metrics.RecordLogin(metrics.LoginOIDC, false)
+		problem.Abort(c, http.StatusUnauthorized, "Login could not be completed")
+		return
+	}
+
+	if request.LinkUserID != 0 {
+		err = models.LinkIdentity(c.Request.Context(), request.LinkUserID, provider.Name(), claims.Subject, claims.Email)
+		if err != nil {
+			fail(c, err, "Login could not be linked")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Login linked successfully"})
+		return
+	}
+
+	user, err := models.LoginWithIdentity(c.Request.Context(), provider.Name(), claims.Subject, claims.Email, claims.EmailVerified)
+	metrics.RecordLogin(metrics.LoginOIDC, err == nil)
+	if err != nil {
+		fail(c, err, "Login could not be completed")
+		return
+	}
+
+	token, err := auth.GenerateToken(user.Email, user.ID)
+	if err != nil {
+		fail(c, err, "Could not generate token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User logged in successfully", "token": token})
+}
@@ -0,0 +1,329 @@
+package routes
+
+import (
+	"REST_API/auth"
+	"REST_API/db"
+	"REST_API/oidc"
+	"REST_API/oidc/oidctest"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupMockOIDC starts a mock provider and registers it as "mock"
+func setupMockOIDC(t *testing.T) *oidctest.Provider {
+	mock := oidctest.NewProvider("test-client", "test-secret")
+
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Name:         "mock",
+		Issuer:       mock.Issuer(),
+		ClientID:     mock.ClientID,
+		ClientSecret: mock.ClientSecret,
+		RedirectURL:  "http://localhost/auth/mock/callback",
+	}, mock.Server.Client())
+	if err != nil {
+		t.Fatalf("Failed to discover mock provider: %v", err)
+	}
+	oidc.Register(provider)
+
+	t.Cleanup(func() {
+		oidc.Unregister("mock")
+		mock.Close()
+	})
+
+	return mock
+}
+
+// oidcFlow is what the browser brings back to the callback: the query
+// from the provider and the cookies set when the login started
+type oidcFlow struct {
+	query   url.Values
+	cookies []*http.Cookie
+}
+
+// startOIDCLogin follows the login redirect through the mock provider
+func startOIDCLogin(t *testing.T, router *gin.Engine, mock *oidctest.Provider) oidcFlow {
+	req := httptest.NewRequest(http.MethodGet, "/auth/mock/login", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	return oidcFlow{
+		query:   authorizeAtMock(t, mock, w.Header().Get("Location")),
+		cookies: w.Result().Cookies(),
+	}
+}
+
+// startOIDCLink starts linking a login for the holder of token
+func startOIDCLink(t *testing.T, router *gin.Engine, mock *oidctest.Provider, token, password string) oidcFlow {
+	w := makeJSONRequest(t, router, http.MethodPost, "/me/identities/mock", token, gin.H{"current_password": password})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var link linkAuthorization
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+
+	return oidcFlow{
+		query:   authorizeAtMock(t, mock, link.AuthorizationURL),
+		cookies: w.Result().Cookies(),
+	}
+}
+
+// authorizeAtMock sends the browser to an authorization URL of the mock
+// provider and returns the callback query it is redirected back with
+func authorizeAtMock(t *testing.T, mock *oidctest.Provider, authorizationURL string) url.Values {
+	client := mock.Server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := client.Get(authorizationURL)
+	if err != nil {
+		t.Fatalf("Authorization request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid callback URL: %v", err)
+	}
+	return callback.Query()
+}
+
+// finishOIDCLogin calls our callback endpoint like the browser would
+func finishOIDCLogin(router *gin.Engine, flow oidcFlow) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/auth/mock/callback?"+flow.query.Encode(), nil)
+	for _, cookie := range flow.cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// tokenUserID validates the JWT in a login response and returns its user ID
+func tokenUserID(t *testing.T, w *httptest.ResponseRecorder) int64 {
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	token, _ := response["token"].(string)
+	userID, err := auth.ValidateToken(token)
+	assert.NoError(t, err)
+	return userID
+}
+
+// Test GET /auth/:provider/login and /auth/:provider/callback
+func TestOIDCLogin(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	mock := setupMockOIDC(t)
+
+	t.Run("Login redirect uses PKCE, state and nonce", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/auth/mock/login", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		assert.NoError(t, err)
+		query := location.Query()
+		assert.Equal(t, "S256", query.Get("code_challenge_method"))
+		assert.NotEmpty(t, query.Get("code_challenge"))
+		assert.NotEmpty(t, query.Get("state"))
+		assert.NotEmpty(t, query.Get("nonce"))
+	})
+
+	t.Run("First login creates the user, later logins reuse it", func(t *testing.T) {
+		mock.SetUser(oidctest.User{Subject: "new-sub", Email: "new-oidc@example.com", EmailVerified: true})
+
+		w := finishOIDCLogin(router, startOIDCLogin(t, router, mock))
+		assert.Equal(t, http.StatusOK, w.Code)
+		firstID := tokenUserID(t, w)
+
+		w = finishOIDCLogin(router, startOIDCLogin(t, router, mock))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, firstID, tokenUserID(t, w))
+
+		assert.Equal(t, 1, countRows(t, "SELECT COUNT(*) FROM users WHERE email = ?", "new-oidc@example.com"))
+	})
+
+	t.Run("Verified email links to the existing user", func(t *testing.T) {
+		existing := GetTestUsers()["testuser"]
+		_, err := db.DB.Exec("UPDATE users SET email_verified = 1 WHERE id = ?", existing.ID)
+		assert.NoError(t, err)
+		mock.SetUser(oidctest.User{Subject: "existing-sub", Email: existing.Email, EmailVerified: true})
+
+		w := finishOIDCLogin(router, startOIDCLogin(t, router, mock))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, existing.ID, tokenUserID(t, w))
+	})
+
+	t.Run("Email our account has not verified is not linked", func(t *testing.T) {
+		// Anyone could have signed up with this email.
+		existing := GetTestUsers()["user2"]
+		mock.SetUser(oidctest.User{Subject: "victim-sub", Email: existing.Email, EmailVerified: true})
+
+		w := finishOIDCLogin(router, startOIDCLogin(t, router, mock))
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "link this login")
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM user_identities WHERE subject = ?", "victim-sub"))
+	})
+
+	t.Run("Unverified email of an existing user is not linked", func(t *testing.T) {
+		existing := GetTestUsers()["user1"]
+		mock.SetUser(oidctest.User{Subject: "attacker-sub", Email: existing.Email, EmailVerified: false})
+
+		w := finishOIDCLogin(router, startOIDCLogin(t, router, mock))
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("State cannot be replayed", func(t *testing.T) {
+		mock.SetUser(oidctest.User{Subject: "replay-sub", Email: "replay@example.com", EmailVerified: true})
+
+		flow := startOIDCLogin(t, router, mock)
+		w := finishOIDCLogin(router, flow)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = finishOIDCLogin(router, flow)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Callback from another browser is rejected", func(t *testing.T) {
+		// An attacker's login, sent to a victim whose browser did not
+		// start it
+		mock.SetUser(oidctest.User{Subject: "attacker-csrf", Email: "attacker-csrf@example.com", EmailVerified: true})
+		flow := startOIDCLogin(t, router, mock)
+		assert.Len(t, flow.cookies, 1)
+		assert.True(t, flow.cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, flow.cookies[0].SameSite)
+
+		w := finishOIDCLogin(router, oidcFlow{query: flow.query})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		other := startOIDCLogin(t, router, mock)
+		w = finishOIDCLogin(router, oidcFlow{query: flow.query, cookies: other.cookies})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "the cookie of another login does not match")
+	})
+
+	t.Run("Unknown state is rejected", func(t *testing.T) {
+		w := finishOIDCLogin(router, oidcFlow{query: url.Values{"code": {"x"}, "state": {"forged"}}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Nonce mismatch is rejected", func(t *testing.T) {
+		mock.SetUser(oidctest.User{Subject: "nonce-sub", Email: "nonce@example.com", EmailVerified: true})
+		mock.SetNonce("not-the-nonce-we-sent")
+		defer mock.SetNonce("")
+
+		w := finishOIDCLogin(router, startOIDCLogin(t, router, mock))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Provider error is reported", func(t *testing.T) {
+		w := finishOIDCLogin(router, oidcFlow{query: url.Values{"error": {"access_denied"}}})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Unknown provider", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/auth/unknown/login", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// Test POST /me/identities/:provider
+func TestLinkIdentity(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	mock := setupMockOIDC(t)
+	user := GetTestUsers()["user2"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+
+	t.Run("Needs the current password", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/me/identities/mock", token, gin.H{"current_password": "wrongpassword"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeJSONRequest(t, router, http.MethodPost, "/me/identities/unknown", token, gin.H{"current_password": user.Password})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Links the login to the signed-in user", func(t *testing.T) {
+		mock.SetUser(oidctest.User{Subject: "linked-sub", Email: user.Email, EmailVerified: false})
+
+		w := finishOIDCLogin(router, startOIDCLink(t, router, mock, token, user.Password))
+		assertResponseAndMessage(t, w, http.StatusOK, "Login linked successfully", "message")
+
+		w = finishOIDCLogin(router, startOIDCLogin(t, router, mock))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, user.ID, tokenUserID(t, w), "the linked login signs in to the account")
+	})
+
+	t.Run("A login linked to another user is rejected", func(t *testing.T) {
+		other := GetTestUsers()["user1"]
+		otherToken := GenerateTestJWT(t, other.ID, other.Email)
+
+		w := finishOIDCLogin(router, startOIDCLink(t, router, mock, otherToken, other.Password))
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+// Test that accounts created through single sign-on confirm sensitive
+// changes by logging in again rather than with a password
+func TestPasswordlessReauthentication(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	mock := setupMockOIDC(t)
+	mock.SetUser(oidctest.User{Subject: "sso-only", Email: "sso-only@example.com", EmailVerified: true})
+
+	login := func() string {
+		w := finishOIDCLogin(router, startOIDCLogin(t, router, mock))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]string
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response["token"]
+	}
+
+	t.Run("An older login must be renewed", func(t *testing.T) {
+		original := recentLogin
+		recentLogin = 0
+		defer func() { recentLogin = original }()
+
+		w := makeJSONRequest(t, router, http.MethodPost, "/me/email", login(), gin.H{"email": "sso-new@example.com"})
+		assertResponseAndMessage(t, w, http.StatusForbidden, "Log in again", "detail")
+
+		w = makeJSONRequest(t, router, http.MethodDelete, "/me", login(), gin.H{"current_password": ""})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("A recent login confirms changes", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/me/email", login(), gin.H{"email": "sso-new@example.com"})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		w = makeJSONRequest(t, router, http.MethodPost, "/me/password", login(), gin.H{"new_password": "a new password"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = makeJSONRequest(t, router, http.MethodPost, "/me/password", login(), gin.H{"new_password": "another password"})
+		assert.Equal(t, http.StatusForbidden, w.Code, "with a password set, the password is required")
+	})
+
+	t.Run("Account deletion", func(t *testing.T) {
+		mock.SetUser(oidctest.User{Subject: "sso-delete", Email: "sso-delete@example.com", EmailVerified: true})
+
+		w := makeJSONRequest(t, router, http.MethodDelete, "/me", login(), gin.H{})
+		assertResponseAndMessage(t, w, http.StatusOK, "Account deleted successfully", "message")
+		assert.Equal(t, 0, countRows(t, "SELECT COUNT(*) FROM users WHERE email = ?", "sso-delete@example.com"))
+	})
+}
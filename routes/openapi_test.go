@@ -0,0 +1,204 @@
+package routes
+
+import (
+	"REST_API/models"
+	"REST_API/openapi"
+	"REST_API/problem"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loadSpec decodes the embedded OpenAPI document
+func loadSpec(t *testing.T) map[string]any {
+	var spec map[string]any
+	assert.NoError(t, json.Unmarshal(openapi.Spec, &spec))
+	return spec
+}
+
+// ginPathParam matches :name and *name path segments
+var ginPathParam = regexp.MustCompile(`[:*]([A-Za-z_]+)`)
+
+// Test that every registered route is documented and nothing else is.
+// The deprecated unversioned aliases of /v1 routes are not documented.
+func TestOpenAPI_MatchesRoutes(t *testing.T) {
+	router := SetupTestRouter()
+
+	routes := map[string]bool{}
+	for _, route := range router.Routes() {
+		path := ginPathParam.ReplaceAllString(route.Path, "{$1}")
+		routes[route.Method+" "+path] = true
+	}
+	var registered []string
+	for route := range routes {
+		method, path, _ := strings.Cut(route, " ")
+		if !routes[method+" /v1"+path] {
+			registered = append(registered, route)
+		}
+	}
+
+	var documented []string
+	for path, item := range loadSpec(t)["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(registered)
+	sort.Strings(documented)
+	assert.Equal(t, registered, documented, "routes and openapi/openapi.json have diverged")
+}
+
+// Test that every $ref points at an existing component
+func TestOpenAPI_References(t *testing.T) {
+	spec := loadSpec(t)
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch node := node.(type) {
+		case map[string]any:
+			if ref, ok := node["$ref"].(string); ok {
+				assert.True(t, resolves(spec, ref), "unresolved reference %s", ref)
+			}
+			for _, child := range node {
+				walk(child)
+			}
+		case []any:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+
+	operationIDs := map[string]bool{}
+	for _, item := range spec["paths"].(map[string]any) {
+		for _, operation := range item.(map[string]any) {
+			id := operation.(map[string]any)["operationId"].(string)
+			assert.False(t, operationIDs[id], "duplicate operationId %s", id)
+			operationIDs[id] = true
+		}
+	}
+}
+
+// resolves reports whether a local JSON pointer such as
+// #/components/schemas/Event exists in spec
+func resolves(spec map[string]any, ref string) bool {
+	var node any = spec
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return false
+		}
+		node, ok = object[key]
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Test that response schemas list exactly the JSON fields of their types
+func TestOpenAPI_SchemasMatchModels(t *testing.T) {
+	schemas := loadSpec(t)["components"].(map[string]any)["schemas"].(map[string]any)
+
+	types := map[string]any{
+		"Event":             models.Event{},
+		"User":              models.User{},
+		"APIKey":            models.APIKey{},
+		"Identity":          models.Identity{},
+		"Registration":      models.Registration{},
+		"UserExport":        models.UserExport{},
+		"BuildInfo":         buildInfo{},
+		"Problem":           problem.Details{},
+		"FieldError":        problem.FieldError{},
+		"Credentials":       credentials{},
+		"Signup":            signupCredentials{},
+		"ProfileUpdate":     profileUpdate{},
+		"PasswordChange":    passwordChange{},
+		"EmailChange":       emailChange{},
+		"EmailVerification": emailVerification{},
+		"AccountDeletion":   accountDeletion{},
+		"IdentityLink":      identityLink{},
+		"LinkAuthorization": linkAuthorization{},
+		"APIKeyInput":       apiKeyInput{},
+		"EventChange":       eventChange{},
+	}
+	for name, value := range types {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := schemas[name].(map[string]any)
+			if !assert.True(t, ok, "schema %s is missing", name) {
+				return
+			}
+
+			var documented []string
+			for property := range schema["properties"].(map[string]any) {
+				documented = append(documented, property)
+			}
+			sort.Strings(documented)
+			assert.Equal(t, jsonFields(reflect.TypeOf(value)), documented)
+		})
+	}
+}
+
+// jsonFields returns the sorted JSON names of a struct's encoded fields
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for field := range typ.Fields() {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Test GET /openapi.json and GET /docs
+func TestOpenAPI_Endpoints(t *testing.T) {
+	router := SetupTestRouter()
+
+	t.Run("Document", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/openapi.json", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var spec map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+		assert.Equal(t, "3.1.0", spec["openapi"])
+	})
+
+	t.Run("Swagger UI", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/docs", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+		policy := w.Header().Get("Content-Security-Policy")
+		assert.Equal(t, openapi.UIContentSecurityPolicy, policy)
+		assert.Contains(t, policy, "frame-ancestors 'none'")
+		assert.Equal(t, 2, strings.Count(w.Body.String(), `crossorigin="anonymous"`), "CDN assets are fetched without credentials")
+
+		// The inline bootstrap script must match the hash the policy allows
+		_, script, _ := strings.Cut(w.Body.String(), "<script>")
+		script, _, _ = strings.Cut(script, "</script>")
+		sum := sha256.Sum256([]byte(script))
+		assert.Contains(t, policy, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	})
+
+	t.Run("Other routes keep the strict policy", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/healthz", "", nil)
+		assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", w.Header().Get("Content-Security-Policy"))
+	})
+}
@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"REST_API/auth"
+	"REST_API/mail"
+	"REST_API/models"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recentLogin is how long after logging in a token confirms sensitive
+// changes to an account without a password. Logging in again through the
+// provider starts a new window. It is a variable so tests can shorten it.
+var recentLogin = 5 * time.Minute
+
+// reauthContext returns the request context, marked as a recent login if
+// the caller's token was issued within recentLogin.
+func reauthContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	principal, ok := auth.CurrentPrincipal(c)
+	if ok && principal.Method == auth.MethodToken && time.Since(principal.IssuedAt) < recentLogin {
+		return models.RecentLogin(ctx)
+	}
+	return ctx
+}
+
+type profileUpdate struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100" mod:"trim"`
+	AvatarURL   *string `json:"avatar_url" binding:"omitempty,url,max=2048" mod:"trim"`
+	Locale      *string `json:"locale" binding:"omitempty,bcp47_language_tag"`
+}
+
+// CurrentPassword is checked by the model rather than required here:
+// accounts without a password leave it empty and confirm with a recent
+// login instead.
+type passwordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type emailChange struct {
+	Email           string `json:"email" binding:"required,email,max=254" mod:"trim"`
+	CurrentPassword string `json:"current_password"`
+}
+
+type emailVerification struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func getProfile(c *gin.Context) {
+	user, err := models.GetUserByID(c.Request.Context(), c.GetInt64("userId"))
+	if err != nil {
+		fail(c, err, "Profile could not be retrieved")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func updateProfile(c *gin.Context) {
+	var input profileUpdate
+	if !bindJSON(c, &input, "Invalid profile data") {
+		return
+	}
+
+	user, err := models.GetUserByID(c.Request.Context(), c.GetInt64("userId"))
+	if err != nil {
+		fail(c, err, "Profile could not be retrieved")
+		return
+	}
+
+	if input.DisplayName != nil {
+		user.DisplayName = *input.DisplayName
+	}
+	if input.AvatarURL != nil {
+		user.AvatarURL = *input.AvatarURL
+	}
+	if input.Locale != nil {
+		user.Locale = *input.Locale
+	}
+
+	err = user.UpdateProfile(c.Request.Context())
+	if err != nil {
+		fail(c, err, "Profile could not be updated")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func changePassword(c *gin.Context) {
+	var input passwordChange
+	if !bindJSON(c, &input, "Invalid password data") {
+		return
+	}
+
+	user := models.User{ID: c.GetInt64("userId")}
+	err := user.ChangePassword(reauthContext(c), input.CurrentPassword, input.NewPassword)
+	if err != nil {
+		fail(c, err, "Password could not be changed")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+func requestEmailChange(c *gin.Context) {
+	var input emailChange
+	if !bindJSON(c, &input, "Invalid email data") {
+		return
+	}
+
+	user := models.User{ID: c.GetInt64("userId")}
+	token, err := user.RequestEmailChange(reauthContext(c), input.CurrentPassword, input.Email)
+	if err != nil {
+		fail(c, err, "Email change could not be requested")
+		return
+	}
+
+	err = mail.Send(input.Email, "Confirm your new email address",
+		"Use this token to confirm your new email address:\n\n"+token+"\n")
+	if err != nil {
+		fail(c, err, "Verification email could not be sent")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+func verifyEmail(c *gin.Context) {
+	var input emailVerification
+	if !bindJSON(c, &input, "Invalid verification data") {
+		return
+	}
+
+	user, err := models.ConfirmEmailChange(c.Request.Context(), input.Token)
+	if err != nil {
+		fail(c, err, "Email could not be verified")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,216 @@
+package routes
+
+import (
+	"REST_API/db"
+	"REST_API/mail"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureSender records sent mail instead of delivering it
+type captureSender struct {
+	to   []string
+	body []string
+}
+
+func (s *captureSender) Send(to, _, body string) error {
+	s.to = append(s.to, to)
+	s.body = append(s.body, body)
+	return nil
+}
+
+// lastToken extracts the verification token from the most recent message
+func (s *captureSender) lastToken() string {
+	lines := strings.Split(strings.TrimSpace(s.body[len(s.body)-1]), "\n")
+	return lines[len(lines)-1]
+}
+
+// makeJSONRequest sends body as JSON with an optional Authorization header
+func makeJSONRequest(t *testing.T, router *gin.Engine, method, url, token string, body any) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		assert.NoError(t, err)
+		reader = bytes.NewReader(jsonData)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, url, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test GET /me and PATCH /me
+func TestProfile(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+
+	t.Run("Get profile never exposes password", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/me", token, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "password")
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, user.Email, response["email"])
+	})
+
+	t.Run("Get profile without token", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/me", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Patch updates only given fields", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPatch, "/me", token, gin.H{
+			"display_name": "Test User",
+			"locale":       "sv-SE",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = makeJSONRequest(t, router, http.MethodPatch, "/me", token, gin.H{
+			"avatar_url": "https://example.com/avatar.png",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "Test User", response["display_name"])
+		assert.Equal(t, "sv-SE", response["locale"])
+		assert.Equal(t, "https://example.com/avatar.png", response["avatar_url"])
+	})
+
+	t.Run("Patch rejects invalid values", func(t *testing.T) {
+		invalid := []gin.H{
+			{"avatar_url": "not a url"},
+			{"locale": "not_a_locale!"},
+			{"display_name": strings.Repeat("x", 101)},
+		}
+
+		for _, body := range invalid {
+			w := makeJSONRequest(t, router, http.MethodPatch, "/me", token, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, "body %v", body)
+		}
+	})
+}
+
+// Test POST /me/password
+func TestChangePassword(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+
+	t.Run("Wrong current password", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/me/password", token, gin.H{
+			"current_password": "wrongpassword",
+			"new_password":     "newpassword123",
+		})
+		assertResponseAndMessage(t, w, http.StatusForbidden, "Current password is incorrect", "detail")
+	})
+
+	t.Run("Too short new password", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/me/password", token, gin.H{
+			"current_password": user.Password,
+			"new_password":     "short",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Successful change", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/me/password", token, gin.H{
+			"current_password": user.Password,
+			"new_password":     "newpassword123",
+		})
+		assertResponseAndMessage(t, w, http.StatusOK, "Password changed successfully", "message")
+
+		w = makeJSONRequest(t, router, http.MethodPost, "/login", "", credentials{Email: user.Email, Password: user.Password})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = makeJSONRequest(t, router, http.MethodPost, "/login", "", credentials{Email: user.Email, Password: "newpassword123"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+// Test POST /me/email and POST /verify-email
+func TestChangeEmail(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	sender := &captureSender{}
+	mail.SetSender(sender)
+	defer mail.SetSender(mail.LogSender{})
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+
+	t.Run("Email already taken", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/me/email", token, gin.H{
+			"email":            GetTestUsers()["user1"].Email,
+			"current_password": user.Password,
+		})
+		assertResponseAndMessage(t, w, http.StatusConflict, "Email already in use", "detail")
+	})
+
+	t.Run("Wrong current password", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/me/email", token, gin.H{
+			"email":            "changed@example.com",
+			"current_password": "wrongpassword",
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/verify-email", "", gin.H{"token": "bogus"})
+		assertResponseAndMessage(t, w, http.StatusBadRequest, "Invalid or expired token", "detail")
+	})
+
+	t.Run("Email changes only after verification", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/me/email", token, gin.H{
+			"email":            "changed@example.com",
+			"current_password": user.Password,
+		})
+		assertResponseAndMessage(t, w, http.StatusAccepted, "Verification email sent", "message")
+		assert.Equal(t, []string{"changed@example.com"}, sender.to)
+
+		var email string
+		err := db.DB.QueryRow("SELECT email FROM users WHERE id = ?", user.ID).Scan(&email)
+		assert.NoError(t, err)
+		assert.Equal(t, user.Email, email)
+
+		verificationToken := sender.lastToken()
+		w = makeJSONRequest(t, router, http.MethodPost, "/verify-email", "", gin.H{"token": verificationToken})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		err = db.DB.QueryRow("SELECT email FROM users WHERE id = ?", user.ID).Scan(&email)
+		assert.NoError(t, err)
+		assert.Equal(t, "changed@example.com", email)
+
+		// Tokens are single use
+		w = makeJSONRequest(t, router, http.MethodPost, "/verify-email", "", gin.H{"token": verificationToken})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"REST_API/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupRateLimitedRouter registers all routes with the given limits
+func setupRateLimitedRouter(limits RateLimits) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	config := DefaultConfig()
+	config.RateLimits = limits
+	RegisterRoutes(router, config)
+	return router
+}
+
+// makeRequestFrom sends a request from the given client address
+func makeRequestFrom(router *gin.Engine, method, url, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, nil)
+	req.RemoteAddr = remoteAddr
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test rate limiting per route group and caller
+func TestRateLimiting(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	twoPerMinute := ratelimit.Rate{Requests: 2, Period: time.Minute}
+	router := setupRateLimitedRouter(RateLimits{
+		Public: ratelimit.Policy{Anonymous: twoPerMinute, User: ratelimit.Rate{Requests: 5, Period: time.Minute}},
+		Events: ratelimit.Policy{User: twoPerMinute, APIKey: twoPerMinute},
+	})
+
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+	event := createTestEvent(t, user.ID)
+	registerURL := "/events/" + strconv.FormatInt(event.ID, 10) + "/register"
+
+	t.Run("Anonymous clients are limited per IP", func(t *testing.T) {
+		for remaining := 1; remaining >= 0; remaining-- {
+			w := makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.1:1234", nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, strconv.Itoa(remaining), w.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+		}
+
+		w := makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.1:1234", nil)
+		details := decodeProblem(t, w, http.StatusTooManyRequests)
+		assert.Equal(t, "Rate limit exceeded, retry in 30 seconds", details.Detail)
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+		assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+
+		w = makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.2:1234", nil)
+		assert.Equal(t, http.StatusOK, w.Code, "another client has its own bucket")
+	})
+
+	t.Run("Signed-in readers get the user rate", func(t *testing.T) {
+		bearer := http.Header{"Authorization": {token}}
+		w := makeRequestFrom(router, http.MethodGet, "/v1/events", "192.0.2.1:1234", bearer)
+		assert.Equal(t, http.StatusOK, w.Code, "not counted in the exhausted IP bucket")
+		assert.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+
+		w = makeRequestFrom(router, http.MethodGet, "/v1/events", "192.0.2.1:1234", http.Header{"Authorization": {"Bearer invalid"}})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "credentials that are sent must be valid")
+	})
+
+	t.Run("Groups have separate buckets", func(t *testing.T) {
+		w := makeRequestFrom(router, http.MethodPost, "/login", "192.0.2.1:1234", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"), "the auth group is unlimited here")
+	})
+
+	t.Run("Users and API keys are limited separately", func(t *testing.T) {
+		key, _ := createTestAPIKey(t, router, token, gin.H{"name": "limited", "scopes": allScopes})
+		bearer := http.Header{"Authorization": {token}}
+		apiKey := http.Header{"X-Api-Key": {key}}
+
+		for range 2 {
+			w := makeRequestFrom(router, http.MethodPost, registerURL, "192.0.2.3:1234", bearer)
+			assert.NotEqual(t, http.StatusTooManyRequests, w.Code)
+		}
+		w := makeRequestFrom(router, http.MethodPost, registerURL, "192.0.2.4:1234", bearer)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "a user is limited across addresses")
+
+		w = makeRequestFrom(router, http.MethodDelete, registerURL, "192.0.2.3:1234", apiKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	})
+
+	t.Run("Operations endpoints are not limited", func(t *testing.T) {
+		for range 3 {
+			w := makeRequestFrom(router, http.MethodGet, "/healthz", "192.0.2.1:1234", nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+		}
+	})
+}
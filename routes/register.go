@@ -1,55 +1,55 @@
 package routes
 
 import (
+	"REST_API/metrics"
 	"REST_API/models"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 func registerEvent(c *gin.Context) {
 	userId := c.GetInt64("userId")
-	eventId, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+	eventId, ok := eventID(c)
+	if !ok {
 		return
 	}
 
-	event, err := models.GetEventByID(eventId)
+	event, err := models.GetEventByID(c.Request.Context(), eventId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Event not found"})
+		fail(c, err, "Event could not be registered")
 		return
 	}
 
-	err = event.Register(userId)
+	err = event.Register(c.Request.Context(), userId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Event could not be registered"})
+		fail(c, err, "Event could not be registered")
 		return
 	}
+	metrics.RecordRegistration(event.ID, true)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Event registered successfully"})
 }
 
 func unregisterEvent(c *gin.Context) {
 	userId := c.GetInt64("userId")
-	eventId, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+	eventId, ok := eventID(c)
+	if !ok {
 		return
 	}
 
-	event, err := models.GetEventByID(eventId)
+	event, err := models.GetEventByID(c.Request.Context(), eventId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Event not found"})
+		fail(c, err, "Event could not be unregistered")
 		return
 	}
 
-	err = event.Unregister(userId)
+	err = event.Unregister(c.Request.Context(), userId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Event could not be unregistered"})
+		fail(c, err, "Event could not be unregistered")
 		return
 	}
+	metrics.RecordRegistration(event.ID, false)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Event unregistered successfully"})
 }
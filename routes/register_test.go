@@ -24,7 +24,7 @@ func createTestEventForRegistration(t *testing.T, userID int64) *models.Event {
 		UserID:      userID,
 	}
 
-	err := event.Save()
+	err := event.Save(t.Context())
 	if err != nil {
 		t.Fatalf("Failed to create test event: %v", err)
 	}
@@ -86,12 +86,12 @@ func TestRegisterEvent(t *testing.T) {
 
 	t.Run("Register for non-existent event", func(t *testing.T) {
 		w := makeRegistrationRequest(router, http.MethodPost, "999", token)
-		assertResponseAndMessage(t, w, http.StatusInternalServerError, "Event not found", "error")
+		assertResponseAndMessage(t, w, http.StatusNotFound, "Event not found", "detail")
 	})
 
 	t.Run("Register with invalid event ID", func(t *testing.T) {
 		w := makeRegistrationRequest(router, http.MethodPost, "invalid", token)
-		assertResponseAndMessage(t, w, http.StatusBadRequest, "Invalid event ID", "error")
+		assertResponseAndMessage(t, w, http.StatusBadRequest, "Invalid event ID", "detail")
 	})
 
 	t.Run("Register without authentication", func(t *testing.T) {
@@ -107,7 +107,7 @@ func TestRegisterEvent(t *testing.T) {
 		assert.Equal(t, http.StatusCreated, w1.Code)
 
 		w2 := makeRegistrationRequest(router, http.MethodPost, eventID, token)
-		assertResponseAndMessage(t, w2, http.StatusInternalServerError, "Event could not be registered", "error")
+		assertResponseAndMessage(t, w2, http.StatusConflict, "Already registered for this event", "detail")
 	})
 }
 
@@ -125,7 +125,7 @@ func TestUnregisterEvent(t *testing.T) {
 	user := testUsers["user2"]
 	token := GenerateTestJWT(t, user.ID, user.Email)
 
-	err := event.Register(user.ID)
+	err := event.Register(t.Context(), user.ID)
 	assert.NoError(t, err)
 
 	t.Run("Successful event unregistration", func(t *testing.T) {
@@ -136,12 +136,12 @@ func TestUnregisterEvent(t *testing.T) {
 
 	t.Run("Unregister from non-existent event", func(t *testing.T) {
 		w := makeRegistrationRequest(router, http.MethodDelete, "999", token)
-		assertResponseAndMessage(t, w, http.StatusInternalServerError, "Event not found", "error")
+		assertResponseAndMessage(t, w, http.StatusNotFound, "Event not found", "detail")
 	})
 
 	t.Run("Unregister with invalid event ID", func(t *testing.T) {
 		w := makeRegistrationRequest(router, http.MethodDelete, "invalid", token)
-		assertResponseAndMessage(t, w, http.StatusBadRequest, "Invalid event ID", "error")
+		assertResponseAndMessage(t, w, http.StatusBadRequest, "Invalid event ID", "detail")
 	})
 
 	t.Run("Unregister without authentication", func(t *testing.T) {
@@ -222,7 +222,7 @@ func TestRegistrationWithMultipleUsers(t *testing.T) {
 
 	t.Run("User can only unregister their own registration", func(t *testing.T) {
 		newEvent := createTestEventForRegistration(t, 1)
-		err := newEvent.Register(user2.ID)
+		err := newEvent.Register(t.Context(), user2.ID)
 		assert.NoError(t, err)
 
 		// User 2 unregisters (should work)
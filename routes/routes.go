@@ -2,24 +2,147 @@ package routes
 
 import (
 	"REST_API/auth"
+	"REST_API/compress"
+	"REST_API/graphql"
+	"REST_API/logging"
+	"REST_API/metrics"
+	"REST_API/models"
+	"REST_API/openapi"
+	"REST_API/problem"
+	"REST_API/ratelimit"
+	"REST_API/security"
+	"REST_API/tracing"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(server *gin.Engine) {
-	// Events
-	server.GET("/events", getEvents)
-	server.GET("/events/:id", getEventByID)
-
-	authenticated := server.Group("/")
-	authenticated.Use(auth.Authenticate)
-	authenticated.POST("/events", createEvent)
-	authenticated.PUT("/events/:id", updateEvents)
-	authenticated.DELETE("/events/:id", deleteEvent)
-	authenticated.POST("/events/:id/register", registerEvent)
-	authenticated.DELETE("/events/:id/register", unregisterEvent)
-
-	// Users
-	server.POST("/signup", signup)
-	server.POST("/login", login)
+type Config struct {
+	// OIDCStateTTL is how long a single sign-on login may take between
+	// the redirect to the provider and the callback.
+	OIDCStateTTL time.Duration
+	RateLimits   RateLimits
+	// RateLimitStore holds the rate limit buckets. Nil uses an in-process
+	// store.
+	RateLimitStore  ratelimit.Store
+	CORS            security.CORSConfig
+	SecurityHeaders security.HeadersConfig
+	Compression     compress.Config
+	// CacheMaxAge is how long browsers and CDNs may use a public event
+	// response without revalidating it. Zero makes them revalidate with
+	// If-Modified-Since every time.
+	CacheMaxAge time.Duration
+	// IdempotencyTTL is how long responses to requests with an
+	// Idempotency-Key are kept for replay.
+	IdempotencyTTL time.Duration
+	// ServiceAccounts map TLS client certificates to users. They are only
+	// used when the server verifies client certificates.
+	ServiceAccounts []models.ServiceAccount
+	// UnversionedRoutes also serves the /v1 API at the root, as it was
+	// before versioning, with deprecation headers announcing
+	// UnversionedSunset. A zero UnversionedSunset leaves Sunset out.
+	UnversionedRoutes bool
+	UnversionedSunset time.Time
+	GraphQL           graphql.Config
+	// MetricsToken is the bearer token that /metrics requires. Empty
+	// disables /metrics.
+	MetricsToken string
+	// MaxBodyBytes is the largest request body handlers read; larger
+	// bodies are rejected with 413. Zero leaves bodies unlimited.
+	MaxBodyBytes int64
+}
+
+// RateLimits holds the policy of each rate limited route group. The zero
+// policy leaves a group unlimited. Operations endpoints are never limited.
+type RateLimits struct {
+	// Public covers reading events.
+	Public ratelimit.Policy
+	// Auth covers signup, login, email verification and single sign-on.
+	Auth ratelimit.Policy
+	// Events covers creating, changing and registering for events.
+	Events ratelimit.Policy
+	// Account covers /me and API key management.
+	Account ratelimit.Policy
+	// GraphQL covers every query and mutation sent to /graphql.
+	GraphQL ratelimit.Policy
+}
+
+func DefaultConfig() Config {
+	return Config{
+		OIDCStateTTL:      10 * time.Minute,
+		IdempotencyTTL:    24 * time.Hour,
+		MaxBodyBytes:      1 << 20,
+		Compression:       compress.Config{Enabled: true, MinSize: 1024},
+		UnversionedRoutes: true,
+		GraphQL:           graphql.DefaultConfig(),
+	}
+}
+
+var routeConfig = DefaultConfig()
+
+func RegisterRoutes(server *gin.Engine, config Config) {
+	routeConfig = config
+	auth.SetAPIKeyValidator(models.AuthenticateAPIKey)
+	auth.SetClientCertValidator(nil)
+	if len(config.ServiceAccounts) > 0 {
+		auth.SetClientCertValidator(models.ClientCertAuthenticator(config.ServiceAccounts))
+	}
+	useModelValidation()
+
+	// Installed first so every route below is traced, logged and measured.
+	// Tracing comes first so log records carry the trace ID.
+	server.Use(
+		tracing.Middleware(),
+		logging.RequestIDMiddleware,
+		logging.AccessLog,
+		logging.Recovery,
+		metrics.Middleware,
+		compress.Middleware(config.Compression),
+		handleErrors,
+		security.Headers(config.SecurityHeaders),
+		security.CORS(config.CORS),
+		limitBody(config.MaxBodyBytes),
+	)
+	server.NoRoute(func(c *gin.Context) {
+		problem.Abort(c, http.StatusNotFound, "Resource not found")
+	})
+
+	store := config.RateLimitStore
+	if store == nil {
+		store = ratelimit.NewMemoryStore()
+	}
+	limit := func(group string, policy ratelimit.Policy) gin.HandlerFunc {
+		return ratelimit.Middleware(store, group, policy)
+	}
+
+	// Operations
+	server.GET("/healthz", healthz)
+	server.GET("/readyz", readyz)
+	server.GET("/version", version)
+	server.GET("/metrics", metricsAuth(config.MetricsToken), gin.WrapH(metrics.Handler()))
+	server.GET("/openapi.json", openapi.Handler)
+	server.GET("/docs", security.ContentSecurityPolicy(openapi.UIContentSecurityPolicy), openapi.UI)
+
+	// The API. Operations endpoints above are not versioned.
+	v1.register(server.Group("/v1"), limit, config.RateLimits)
+	if config.UnversionedRoutes {
+		v1.register(server.Group("/", deprecated(config.UnversionedSunset)), limit, config.RateLimits)
+	}
+
+	// A GraphQL schema evolves by deprecating fields rather than through
+	// URL versions, so it is served next to /v1 instead of under it.
+	server.POST("/graphql", auth.AuthenticateOptional, limit("graphql", config.RateLimits.GraphQL), graphql.Handler(config.GraphQL))
+}
+
+// limitBody makes reading more than max bytes of a request body fail with
+// an *http.MaxBytesError, which handlers answer with 413. Zero leaves
+// bodies unlimited.
+func limitBody(max int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if max > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+		}
+		c.Next()
+	}
 }
@@ -0,0 +1,157 @@
+package routes
+
+import (
+	"REST_API/ratelimit"
+	"REST_API/security"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupCORSRouter registers all routes with the given CORS settings
+func setupCORSRouter(cors security.CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	config := DefaultConfig()
+	config.CORS = cors
+	RegisterRoutes(router, config)
+	return router
+}
+
+// Test CORS preflight and actual requests
+func TestCORS(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := setupCORSRouter(security.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	preflight := func(origin string) http.Header {
+		return http.Header{
+			"Origin":                         {origin},
+			"Access-Control-Request-Method":  {"POST"},
+			"Access-Control-Request-Headers": {"authorization,content-type"},
+		}
+	}
+
+	t.Run("Preflight from an allowed origin", func(t *testing.T) {
+		w := makeRequestFrom(router, http.MethodOptions, "/events", "192.0.2.1:1234", preflight("https://app.example.com"))
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Authorization, Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+		assert.Contains(t, w.Header().Values("Vary"), "Origin")
+	})
+
+	t.Run("Preflight from a subdomain wildcard", func(t *testing.T) {
+		w := makeRequestFrom(router, http.MethodOptions, "/events/1", "192.0.2.1:1234", preflight("https://shop.example.org"))
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://shop.example.org", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Preflight from another origin is rejected", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.example.com", "https://example.org", "https://evil-example.org"} {
+			w := makeRequestFrom(router, http.MethodOptions, "/events", "192.0.2.1:1234", preflight(origin))
+			assert.Equal(t, http.StatusForbidden, w.Code, origin)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+	})
+
+	t.Run("Actual request from an allowed origin", func(t *testing.T) {
+		w := makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.1:1234", http.Header{"Origin": {"https://app.example.com"}})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+		assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "RateLimit-Remaining")
+	})
+
+	t.Run("Actual request from another origin gets no CORS headers", func(t *testing.T) {
+		w := makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.1:1234", http.Header{"Origin": {"https://evil.example.com"}})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Any origin without credentials", func(t *testing.T) {
+		router := setupCORSRouter(security.CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}})
+
+		w := makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.1:1234", http.Header{"Origin": {"https://anywhere.example"}})
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("Disabled without allowed origins", func(t *testing.T) {
+		router := SetupTestRouter()
+
+		w := makeRequestFrom(router, http.MethodOptions, "/events", "192.0.2.1:1234", preflight("https://app.example.com"))
+		assert.NotEqual(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+// Test protective headers on success and error responses
+func TestSecurityHeaders(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	config := DefaultConfig()
+	config.SecurityHeaders = security.HeadersConfig{HSTSMaxAge: 24 * time.Hour}
+	RegisterRoutes(router, config)
+
+	for _, url := range []string{"/events", "/events/999", "/does-not-exist"} {
+		w := makeRequestFrom(router, http.MethodGet, url, "192.0.2.1:1234", nil)
+
+		assert.Equal(t, "max-age=86400; includeSubDomains", w.Header().Get("Strict-Transport-Security"), url)
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"), url)
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"), url)
+		assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", w.Header().Get("Content-Security-Policy"), url)
+	}
+
+	t.Run("HSTS can be turned off", func(t *testing.T) {
+		w := makeRequestFrom(SetupTestRouter(), http.MethodGet, "/events", "192.0.2.1:1234", nil)
+		assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	})
+}
+
+// Test that X-Forwarded-For is only believed from trusted proxies
+func TestTrustedProxies(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := setupRateLimitedRouter(RateLimits{
+		Public: ratelimit.Policy{Anonymous: ratelimit.Rate{Requests: 1, Period: time.Minute}},
+	})
+	err := router.SetTrustedProxies([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+
+	t.Run("Forwarded address from a trusted proxy is used", func(t *testing.T) {
+		for _, client := range []string{"203.0.113.1", "203.0.113.2"} {
+			w := makeRequestFrom(router, http.MethodGet, "/events", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {client}})
+			assert.Equal(t, http.StatusOK, w.Code, "each forwarded client has its own bucket")
+		}
+	})
+
+	t.Run("Forwarded address from anyone else is ignored", func(t *testing.T) {
+		w := makeRequestFrom(router, http.MethodGet, "/events", "198.51.100.1:1234", http.Header{"X-Forwarded-For": {"203.0.113.3"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = makeRequestFrom(router, http.MethodGet, "/events", "198.51.100.1:1234", http.Header{"X-Forwarded-For": {"203.0.113.4"}})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "a spoofed header does not get a fresh bucket")
+	})
+}
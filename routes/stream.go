@@ -0,0 +1,160 @@
+package routes
+
+import (
+	"REST_API/models"
+	"REST_API/pubsub"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Event streams use Server-Sent Events. Clients that lose the connection
+// reconnect after streamRetry with the ID of the last change they
+// received, and are sent what they missed.
+const (
+	streamRetry = 3 * time.Second
+	// streamHeartbeat keeps proxies from closing an idle stream.
+	streamHeartbeat = 15 * time.Second
+)
+
+// eventChange is the data of a change message.
+type eventChange struct {
+	Type    models.ChangeType `json:"type"`
+	EventID int64             `json:"event_id"`
+	// Event is omitted once the event is deleted.
+	Event *models.Event `json:"event,omitempty"`
+	// AttendeeCount is only sent with registrations changes.
+	AttendeeCount *int      `json:"attendee_count,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// streamEvents streams the changes of all events.
+func streamEvents(c *gin.Context) {
+	changes, resumed := subscribeEventChanges(c)
+	defer changes.Close()
+
+	streamChanges(c, changes, resumed, 0)
+}
+
+// streamEvent streams the changes of one event, and ends once the event
+// is deleted.
+func streamEvent(c *gin.Context) {
+	id, ok := eventID(c)
+	if !ok {
+		return
+	}
+
+	// Subscribed first, so a change right after the lookup is not missed.
+	changes, resumed := subscribeEventChanges(c)
+	defer changes.Close()
+
+	_, err := models.GetEventByID(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err, "Event could not be retrieved")
+		return
+	}
+
+	streamChanges(c, changes, resumed, id)
+}
+
+// subscribeEventChanges resumes after the Last-Event-ID a reconnecting
+// client sends. It returns false if the client missed changes that can no
+// longer be replayed.
+func subscribeEventChanges(c *gin.Context) (*pubsub.Subscription[models.EventChange], bool) {
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		return models.SubscribeEventChanges(), true
+	}
+
+	lastID, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil {
+		return models.SubscribeEventChanges(), false
+	}
+	return models.SubscribeEventChangesAfter(lastID)
+}
+
+// streamChanges writes changes to the client until it disconnects, the
+// server drains or the subscription is dropped for lagging behind; the
+// client then reconnects and catches up from the history. A non-zero
+// eventID only streams the changes of that event.
+func streamChanges(c *gin.Context, changes *pubsub.Subscription[models.EventChange], resumed bool, eventID int64) {
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// Keeps nginx from buffering the stream.
+	header.Set("X-Accel-Buffering", "no")
+
+	// The server timeouts are meant for requests, not streams.
+	controller := http.NewResponseController(c.Writer)
+	_ = controller.SetReadDeadline(time.Time{})
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	c.Status(http.StatusOK)
+	_, err := fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetry.Milliseconds())
+	if err != nil {
+		return
+	}
+	if !resumed {
+		// Tells the client to reload rather than trust its copy.
+		_, err = fmt.Fprint(c.Writer, "event: reset\ndata: {\"message\":\"Missed changes are no longer available\"}\n\n")
+		if err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case msg, ok := <-changes.C():
+			if !ok {
+				return
+			}
+			change := msg.Value
+			if eventID != 0 && change.EventID != eventID {
+				continue
+			}
+			err = writeChange(c, msg.ID, change)
+			if err != nil || (eventID != 0 && change.Type == models.EventDeleted) {
+				return
+			}
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		case <-drained():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeChange(c *gin.Context, id uint64, change models.EventChange) error {
+	data := eventChange{
+		Type:    change.Type,
+		EventID: change.EventID,
+		Time:    change.At,
+	}
+	if change.Type != models.EventDeleted {
+		data.Event = &change.Event
+	}
+	if change.Type == models.EventRegistrations {
+		data.AttendeeCount = &change.AttendeeCount
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", id, change.Type, encoded)
+	return err
+}
@@ -0,0 +1,190 @@
+package routes
+
+import (
+	"REST_API/models"
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sseMessage struct {
+	id    string
+	event string
+	data  map[string]any
+}
+
+// openStream starts an event stream, optionally resuming after
+// lastEventID. The returned reader fails rather than hang if no message
+// arrives.
+func openStream(t *testing.T, server *httptest.Server, path, lastEventID string) (*http.Response, *bufio.Reader) {
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+path, nil)
+	assert.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// readMessage returns the next message with an event name, skipping the
+// retry setting and heartbeats.
+func readMessage(t *testing.T, r *bufio.Reader) sseMessage {
+	var msg sseMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		field, value, _ := strings.Cut(strings.TrimSuffix(line, "\n"), ": ")
+		switch field {
+		case "":
+			if msg.event != "" {
+				return msg
+			}
+		case "id":
+			msg.id = value
+		case "event":
+			msg.event = value
+		case "data":
+			assert.NoError(t, json.Unmarshal([]byte(value), &msg.data))
+		}
+	}
+}
+
+// Test GET /v1/events/stream and GET /v1/events/:id/stream
+func TestEventStreams(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	// Short timeouts show that streams outlive them.
+	server := httptest.NewUnstartedServer(SetupTestRouter())
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	users := GetTestUsers()
+	event := createTestEventForRegistration(t, users["testuser"].ID)
+	other := createTestEventForRegistration(t, users["testuser"].ID)
+	eventPath := "/v1/events/" + strconv.FormatInt(event.ID, 10) + "/stream"
+
+	var updatedID string
+	t.Run("All events", func(t *testing.T) {
+		resp, stream := openStream(t, server, "/v1/events/stream", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+		time.Sleep(200 * time.Millisecond)
+		event.Name = "Renamed"
+		assert.NoError(t, event.Update(t.Context()))
+		assert.NoError(t, other.Register(t.Context(), users["user1"].ID))
+
+		msg := readMessage(t, stream)
+		assert.Equal(t, "updated", msg.event)
+		assert.Equal(t, float64(event.ID), msg.data["event_id"])
+		assert.Equal(t, "Renamed", msg.data["event"].(map[string]any)["name"])
+		assert.NotContains(t, msg.data, "attendee_count")
+		updatedID = msg.id
+
+		msg = readMessage(t, stream)
+		assert.Equal(t, "registrations", msg.event)
+		assert.Equal(t, float64(other.ID), msg.data["event_id"])
+		assert.Equal(t, float64(1), msg.data["attendee_count"])
+	})
+
+	t.Run("Resumes after Last-Event-ID", func(t *testing.T) {
+		_, stream := openStream(t, server, "/v1/events/stream", updatedID)
+
+		msg := readMessage(t, stream)
+		assert.Equal(t, "registrations", msg.event, "the missed change is replayed")
+		assert.Equal(t, float64(other.ID), msg.data["event_id"])
+	})
+
+	t.Run("Reset when changes were missed", func(t *testing.T) {
+		for _, lastEventID := range []string{"1", "bogus"} {
+			_, stream := openStream(t, server, "/v1/events/stream", lastEventID)
+			msg := readMessage(t, stream)
+			assert.Equal(t, "reset", msg.event, lastEventID)
+		}
+	})
+
+	t.Run("Unknown event", func(t *testing.T) {
+		resp, _ := openStream(t, server, "/v1/events/999/stream", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("One event until it is deleted", func(t *testing.T) {
+		_, stream := openStream(t, server, eventPath, "")
+
+		assert.NoError(t, other.Unregister(t.Context(), users["user1"].ID))
+		assert.NoError(t, event.Register(t.Context(), users["user1"].ID))
+		assert.NoError(t, event.Delete(t.Context()))
+
+		msg := readMessage(t, stream)
+		assert.Equal(t, "registrations", msg.event, "changes of other events are skipped")
+		assert.Equal(t, float64(event.ID), msg.data["event_id"])
+
+		msg = readMessage(t, stream)
+		assert.Equal(t, "deleted", msg.event)
+		assert.NotContains(t, msg.data, "event")
+
+		rest, err := io.ReadAll(stream)
+		assert.NoError(t, err)
+		assert.Empty(t, rest, "the stream ends")
+	})
+
+	t.Run("Deleting the owner's account", func(t *testing.T) {
+		owned := createTestEventForRegistration(t, users["user2"].ID)
+		attended := createTestEventForRegistration(t, users["user1"].ID)
+		assert.NoError(t, attended.Register(t.Context(), users["user2"].ID))
+		cancelled := createTestEventForRegistration(t, users["logintest"].ID)
+
+		_, stream := openStream(t, server, "/v1/events/stream", "")
+
+		owner := models.User{ID: users["user2"].ID}
+		assert.NoError(t, owner.DeleteAccount(t.Context(), users["user2"].Password, models.DeleteEvents, ""))
+
+		msg := readMessage(t, stream)
+		assert.Equal(t, "deleted", msg.event)
+		assert.Equal(t, float64(owned.ID), msg.data["event_id"])
+
+		msg = readMessage(t, stream)
+		assert.Equal(t, "registrations", msg.event, "the owner's registrations are removed")
+		assert.Equal(t, float64(attended.ID), msg.data["event_id"])
+		assert.Equal(t, float64(0), msg.data["attendee_count"])
+
+		owner = models.User{ID: users["logintest"].ID}
+		assert.NoError(t, owner.DeleteAccount(t.Context(), users["logintest"].Password, models.CancelEvents, ""))
+
+		msg = readMessage(t, stream)
+		assert.Equal(t, "updated", msg.event)
+		assert.Equal(t, float64(cancelled.ID), msg.data["event_id"])
+		assert.Contains(t, msg.data["event"], "cancelled_at")
+	})
+
+	t.Run("Draining ends streams", func(t *testing.T) {
+		_, stream := openStream(t, server, "/v1/events/stream", "")
+
+		SetDraining(true)
+		defer SetDraining(false)
+
+		_, err := io.ReadAll(stream)
+		assert.NoError(t, err)
+	})
+}
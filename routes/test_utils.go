@@ -3,8 +3,10 @@ package routes
 import (
 	"REST_API/auth"
 	"REST_API/db"
+	"REST_API/models"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3"
@@ -23,10 +25,12 @@ func SetupTestDB(t *testing.T) *CommonTestDB {
 	originalDB := db.DB
 
 	// Create an in-memory database
-	testDB, err := sql.Open("sqlite3", ":memory:")
+	testDB, err := db.Open(":memory:")
 	if err != nil {
 		t.Fatalf("Failed to create test database: %v", err)
 	}
+	// Every connection to :memory: opens a separate database
+	testDB.SetMaxOpenConns(1)
 
 	// Enable foreign key constraints
 	_, err = testDB.Exec("PRAGMA foreign_keys = ON")
@@ -40,8 +44,10 @@ func SetupTestDB(t *testing.T) *CommonTestDB {
 	// Create test users with hashed passwords
 	createTestUsers(t, testDB)
 
-	// Replace the global DB with test DB
+	// Replace the global DB with test DB and forget events cached from
+	// the previous one
 	db.DB = testDB
+	models.ConfigureEventCache(1000, time.Minute)
 
 	return &CommonTestDB{
 		originalDB: originalDB,
@@ -61,52 +67,12 @@ func (ctdb *CommonTestDB) Cleanup() {
 	db.DB = ctdb.originalDB
 }
 
-// createTables creates all required tables for testing
+// createTables applies the production migrations so tests always run
+// against the current schema
 func createTables(t *testing.T, testDB *sql.DB) {
-	// Users' table
-	createUsersTable := `
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			email TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL
-		)`
-
-	_, err := testDB.Exec(createUsersTable)
+	err := db.Migrate(testDB)
 	if err != nil {
-		t.Fatalf("Failed to create users table: %v", err)
-	}
-
-	// Events table
-	createEventsTable := `
-		CREATE TABLE events (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			description TEXT NOT NULL,
-			location TEXT NOT NULL,
-			date_time DATETIME NOT NULL,
-			user_id INTEGER,
-			FOREIGN KEY(user_id) REFERENCES users(id)
-		)`
-
-	_, err = testDB.Exec(createEventsTable)
-	if err != nil {
-		t.Fatalf("Failed to create events table: %v", err)
-	}
-
-	// Registration table
-	createRegistrationTable := `
-		CREATE TABLE registrations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			event_id INTEGER,
-			user_id INTEGER,
-			FOREIGN KEY(event_id) REFERENCES events(id),
-			FOREIGN KEY(user_id) REFERENCES users(id),
-			UNIQUE(event_id, user_id)
-		)`
-
-	_, err = testDB.Exec(createRegistrationTable)
-	if err != nil {
-		t.Fatalf("Failed to create registrations table: %v", err)
+		t.Fatalf("Failed to migrate test database: %v", err)
 	}
 }
 
@@ -144,7 +110,7 @@ func SetupTestRouter() *gin.Engine {
 	router := gin.New()
 
 	// Register all routes
-	RegisterRoutes(router)
+	RegisterRoutes(router, DefaultConfig())
 
 	return router
 }
@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"REST_API/tracing"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// findSpan returns the first recorded span with the given name
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("No span named %q", name)
+	return tracetest.SpanStub{}
+}
+
+// Test spans from the request down to the SQL statements
+func TestTracing(t *testing.T) {
+	exporter := tracing.InMemory()
+
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	router := SetupTestRouter()
+	user := GetTestUsers()["testuser"]
+	event := createTestEventForRegistration(t, user.ID)
+
+	t.Run("Request, model and query spans form one trace", func(t *testing.T) {
+		exporter.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, "/events/"+strconv.FormatInt(event.ID, 10), nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		spans := exporter.GetSpans()
+		server := findSpan(t, spans, "GET /events/:id")
+		model := findSpan(t, spans, "GetEventByID")
+
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String(), "the caller's trace is continued")
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+		assert.Equal(t, server.SpanContext.SpanID(), model.Parent.SpanID())
+
+		var query *tracetest.SpanStub
+		for i, span := range spans {
+			if strings.HasPrefix(span.Name, "sql.") && span.Parent.SpanID() == model.SpanContext.SpanID() {
+				query = &spans[i]
+			}
+		}
+		if assert.NotNil(t, query, "the SQL statement is a child of the model span") {
+			assert.Equal(t, server.SpanContext.TraceID(), query.SpanContext.TraceID())
+		}
+	})
+
+	t.Run("Authenticated writes are traced", func(t *testing.T) {
+		exporter.Reset()
+		token := GenerateTestJWT(t, user.ID, user.Email)
+
+		w := makeRegistrationRequest(router, http.MethodPost, strconv.FormatInt(event.ID, 10), token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		spans := exporter.GetSpans()
+		server := findSpan(t, spans, "POST /events/:id/register")
+		register := findSpan(t, spans, "Event.Register")
+		assert.Equal(t, server.SpanContext.TraceID(), register.SpanContext.TraceID())
+	})
+}
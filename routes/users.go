@@ -2,24 +2,37 @@ package routes
 
 import (
 	"REST_API/auth"
+	"REST_API/metrics"
 	"REST_API/models"
+	"REST_API/problem"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-func signup(c *gin.Context) {
-	var user models.User
+// credentials log a user in. The email is not validated, so accounts
+// created before signup checked addresses can still log in.
+type credentials struct {
+	Email    string `json:"email" binding:"required" mod:"trim"`
+	Password string `json:"password" binding:"required"`
+}
 
-	err := c.ShouldBindJSON(&user)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user data"})
+type signupCredentials struct {
+	Email    string `json:"email" binding:"required,email,max=254" mod:"trim"`
+	Password string `json:"password" binding:"required"`
+}
+
+func signup(c *gin.Context) {
+	var input signupCredentials
+	if !bindJSON(c, &input, "Invalid user data") {
 		return
 	}
 
-	err = user.Save()
+	user := models.User{Email: input.Email, Password: input.Password}
+
+	err := user.Save(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "User could not be saved"})
+		fail(c, err, "User could not be saved")
 		return
 	}
 
@@ -27,23 +40,23 @@ func signup(c *gin.Context) {
 }
 
 func login(c *gin.Context) {
-	var user models.User
-
-	err := c.ShouldBindJSON(&user)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user data"})
+	var input credentials
+	if !bindJSON(c, &input, "Invalid user data") {
 		return
 	}
 
-	err = user.ValidateCredentials()
+	user := models.User{Email: input.Email, Password: input.Password}
+
+	err := user.ValidateCredentials(c.Request.Context())
+	metrics.RecordLogin(metrics.LoginPassword, err == nil)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		problem.Abort(c, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
 	token, err := auth.GenerateToken(user.Email, user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		fail(c, err, "Could not generate token")
 		return
 	}
 
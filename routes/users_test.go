@@ -3,7 +3,7 @@ package routes
 import (
 	"REST_API/auth"
 	"REST_API/db"
-	"REST_API/models"
+	"REST_API/problem"
 	"bytes"
 	"encoding/json"
 	"net/http"
@@ -21,7 +21,7 @@ func TestSignup(t *testing.T) {
 	router := SetupTestRouter()
 
 	t.Run("Successful signup", func(t *testing.T) {
-		userData := models.User{
+		userData := signupCredentials{
 			Email:    "newuser@example.com",
 			Password: "password123",
 		}
@@ -60,11 +60,11 @@ func TestSignup(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "Invalid user data")
+		assert.Contains(t, response["detail"], "Invalid user data")
 	})
 
 	t.Run("Signup with missing email", func(t *testing.T) {
-		userData := models.User{
+		userData := signupCredentials{
 			Password: "password123",
 		}
 
@@ -81,11 +81,21 @@ func TestSignup(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "Invalid user data")
+		assert.Contains(t, response["detail"], "Invalid user data")
+	})
+
+	t.Run("Signup with invalid email", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/signup", "", signupCredentials{
+			Email:    "not-an-email",
+			Password: "password123",
+		})
+
+		details := decodeProblem(t, w, http.StatusBadRequest)
+		assert.Equal(t, []problem.FieldError{{Field: "email", Message: "must be a valid email address"}}, details.Errors)
 	})
 
 	t.Run("Signup with missing password", func(t *testing.T) {
-		userData := models.User{
+		userData := signupCredentials{
 			Email: "nopassword@example.com",
 		}
 
@@ -101,7 +111,7 @@ func TestSignup(t *testing.T) {
 	})
 
 	t.Run("Signup with duplicate email", func(t *testing.T) {
-		userData1 := models.User{
+		userData1 := signupCredentials{
 			Email:    "duplicate@example.com",
 			Password: "password123",
 		}
@@ -115,7 +125,7 @@ func TestSignup(t *testing.T) {
 
 		assert.Equal(t, http.StatusCreated, w1.Code)
 
-		userData2 := models.User{
+		userData2 := signupCredentials{
 			Email:    "duplicate@example.com",
 			Password: "differentpassword",
 		}
@@ -127,12 +137,12 @@ func TestSignup(t *testing.T) {
 		w2 := httptest.NewRecorder()
 		router.ServeHTTP(w2, req2)
 
-		assert.Equal(t, http.StatusInternalServerError, w2.Code)
+		assert.Equal(t, http.StatusConflict, w2.Code)
 
 		var response map[string]interface{}
 		err := json.Unmarshal(w2.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "User could not be saved")
+		assert.Contains(t, response["detail"], "Email already in use")
 	})
 }
 
@@ -148,7 +158,7 @@ func TestLogin(t *testing.T) {
 	loginUser := testUsers["logintest"]
 
 	t.Run("Successful login", func(t *testing.T) {
-		loginData := models.User{
+		loginData := credentials{
 			Email:    loginUser.Email,
 			Password: loginUser.Password,
 		}
@@ -181,7 +191,7 @@ func TestLogin(t *testing.T) {
 	})
 
 	t.Run("Login with wrong password", func(t *testing.T) {
-		loginData := models.User{
+		loginData := credentials{
 			Email:    loginUser.Email,
 			Password: "wrongpassword",
 		}
@@ -199,11 +209,11 @@ func TestLogin(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "Invalid credentials")
+		assert.Contains(t, response["detail"], "Invalid credentials")
 	})
 
 	t.Run("Login with non-existent email", func(t *testing.T) {
-		loginData := models.User{
+		loginData := credentials{
 			Email:    "nonexistent@example.com",
 			Password: "anypassword",
 		}
@@ -221,7 +231,7 @@ func TestLogin(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "Invalid credentials")
+		assert.Contains(t, response["detail"], "Invalid credentials")
 	})
 
 	t.Run("Login with invalid JSON", func(t *testing.T) {
@@ -236,11 +246,25 @@ func TestLogin(t *testing.T) {
 		var response map[string]interface{}
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Contains(t, response["error"], "Invalid user data")
+		assert.Contains(t, response["detail"], "Invalid user data")
+	})
+
+	t.Run("Login with an email signup would reject", func(t *testing.T) {
+		// Accounts from before signup validated emails
+		hashedPassword, err := auth.HashPassword("legacypassword")
+		assert.NoError(t, err)
+		_, err = db.DB.Exec("INSERT INTO users (email, password) VALUES (?, ?)", "legacy user@localhost", hashedPassword)
+		assert.NoError(t, err)
+
+		w := makeJSONRequest(t, router, http.MethodPost, "/login", "", credentials{
+			Email:    "legacy user@localhost",
+			Password: "legacypassword",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
 	})
 
 	t.Run("Login with missing credentials", func(t *testing.T) {
-		loginData := models.User{}
+		loginData := credentials{}
 
 		jsonData, _ := json.Marshal(loginData)
 
@@ -262,7 +286,7 @@ func TestSignupThenLogin(t *testing.T) {
 	router := SetupTestRouter()
 
 	t.Run("Complete user flow: signup then login", func(t *testing.T) {
-		userData := models.User{
+		userData := signupCredentials{
 			Email:    "integration@example.com",
 			Password: "integrationtest123",
 		}
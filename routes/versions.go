@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"REST_API/auth"
+	"REST_API/ratelimit"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// api is the handler of every versioned route. Versions share handlers
+// unless a response changes shape, so a /v2 starts as a copy of v1 and
+// replaces only the handlers that render differently.
+type api struct {
+	getEvents       gin.HandlerFunc
+	getEventByID    gin.HandlerFunc
+	streamEvents    gin.HandlerFunc
+	streamEvent     gin.HandlerFunc
+	createEvent     gin.HandlerFunc
+	updateEvent     gin.HandlerFunc
+	deleteEvent     gin.HandlerFunc
+	registerEvent   gin.HandlerFunc
+	unregisterEvent gin.HandlerFunc
+
+	signup       gin.HandlerFunc
+	login        gin.HandlerFunc
+	verifyEmail  gin.HandlerFunc
+	oidcLogin    gin.HandlerFunc
+	oidcCallback gin.HandlerFunc
+
+	getProfile         gin.HandlerFunc
+	updateProfile      gin.HandlerFunc
+	changePassword     gin.HandlerFunc
+	requestEmailChange gin.HandlerFunc
+	exportAccount      gin.HandlerFunc
+	deleteAccount      gin.HandlerFunc
+	linkIdentity       gin.HandlerFunc
+
+	createAPIKey gin.HandlerFunc
+	getAPIKeys   gin.HandlerFunc
+	deleteAPIKey gin.HandlerFunc
+}
+
+var v1 = api{
+	getEvents:       getEvents,
+	getEventByID:    getEventByID,
+	streamEvents:    streamEvents,
+	streamEvent:     streamEvent,
+	createEvent:     createEvent,
+	updateEvent:     updateEvents,
+	deleteEvent:     deleteEvent,
+	registerEvent:   registerEvent,
+	unregisterEvent: unregisterEvent,
+
+	signup:       signup,
+	login:        login,
+	verifyEmail:  verifyEmail,
+	oidcLogin:    oidcLogin,
+	oidcCallback: oidcCallback,
+
+	getProfile:         getProfile,
+	updateProfile:      updateProfile,
+	changePassword:     changePassword,
+	requestEmailChange: requestEmailChange,
+	exportAccount:      exportAccount,
+	deleteAccount:      deleteAccount,
+	linkIdentity:       linkIdentity,
+
+	createAPIKey: createAPIKey,
+	getAPIKeys:   getAPIKeys,
+	deleteAPIKey: deleteAPIKey,
+}
+
+// limiter returns the rate limit middleware of a route group. Every mount
+// of the API gets the same buckets, so a client cannot double its quota by
+// switching between /v1 and the unversioned paths.
+type limiter func(group string, policy ratelimit.Policy) gin.HandlerFunc
+
+// register mounts the API on root.
+func (a api) register(root *gin.RouterGroup, limit limiter, limits RateLimits) {
+	// Events. Credentials are optional here, but callers that send them
+	// are limited per user or API key rather than per IP.
+	public := root.Group("/")
+	public.Use(auth.AuthenticateOptional, limit("public", limits.Public))
+	public.GET("/events", a.getEvents)
+	public.GET("/events/:id", a.getEventByID)
+	public.GET("/events/stream", a.streamEvents)
+	public.GET("/events/:id/stream", a.streamEvent)
+
+	authenticated := root.Group("/")
+	authenticated.Use(auth.Authenticate)
+	events := authenticated.Group("/")
+	events.Use(limit("events", limits.Events))
+	events.POST("/events", auth.RequireScope(auth.ScopeEventsWrite), idempotent, a.createEvent)
+	events.PUT("/events/:id", auth.RequireScope(auth.ScopeEventsWrite), a.updateEvent)
+	events.DELETE("/events/:id", auth.RequireScope(auth.ScopeEventsWrite), a.deleteEvent)
+	events.POST("/events/:id/register", auth.RequireScope(auth.ScopeRegistrationsWrite), idempotent, a.registerEvent)
+	events.DELETE("/events/:id/register", auth.RequireScope(auth.ScopeRegistrationsWrite), a.unregisterEvent)
+
+	// Users
+	users := root.Group("/")
+	users.Use(auth.AuthenticateOptional, limit("auth", limits.Auth))
+	users.POST("/signup", a.signup)
+	users.POST("/login", a.login)
+	users.POST("/verify-email", a.verifyEmail)
+	users.GET("/auth/:provider/login", a.oidcLogin)
+	users.GET("/auth/:provider/callback", a.oidcCallback)
+
+	// Profile, only reachable with a user token
+	account := authenticated.Group("/")
+	account.Use(auth.RequireUserSession, limit("account", limits.Account))
+	account.GET("/me", a.getProfile)
+	account.PATCH("/me", a.updateProfile)
+	account.POST("/me/password", a.changePassword)
+	account.POST("/me/email", a.requestEmailChange)
+	account.GET("/me/export", a.exportAccount)
+	account.DELETE("/me", a.deleteAccount)
+	account.POST("/me/identities/:provider", a.linkIdentity)
+
+	// API keys
+	account.POST("/me/api-keys", a.createAPIKey)
+	account.GET("/me/api-keys", a.getAPIKeys)
+	account.DELETE("/me/api-keys/:id", a.deleteAPIKey)
+}
+
+// unversionedDeprecation is when the unversioned paths were deprecated in
+// favour of /v1.
+var unversionedDeprecation = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// deprecated marks responses of the unversioned aliases with Deprecation
+// (RFC 9745) and, unless sunset is zero, Sunset (RFC 8594), and links to
+// the same resource under /v1.
+func deprecated(sunset time.Time) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(unversionedDeprecation.Unix(), 10)
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("Deprecation", deprecation)
+		if !sunset.IsZero() {
+			header.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		header.Set("Link", `</v1`+c.Request.URL.EscapedPath()+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
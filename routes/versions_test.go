@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"REST_API/models"
+	"REST_API/ratelimit"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupVersionedRouter registers all routes with the given config changes
+func setupVersionedRouter(configure func(*Config)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	config := DefaultConfig()
+	configure(&config)
+	RegisterRoutes(router, config)
+	return router
+}
+
+// Test the /v1 API and its deprecated unversioned aliases
+func TestAPIVersions(t *testing.T) {
+	testDB := SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	sunset := time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+	router := setupVersionedRouter(func(config *Config) {
+		config.UnversionedSunset = sunset
+	})
+	user := GetTestUsers()["testuser"]
+	token := GenerateTestJWT(t, user.ID, user.Email)
+	newEvent := gin.H{
+		"name":        "Versioned Event",
+		"description": "Created under /v1",
+		"location":    "Test Location",
+		"date_time":   time.Now().Add(24 * time.Hour).Format(time.RFC3339),
+	}
+
+	t.Run("Versioned routes are not deprecated", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/v1/events", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Deprecation"))
+		assert.Empty(t, w.Header().Get("Sunset"))
+		assert.Empty(t, w.Header().Get("Link"))
+	})
+
+	t.Run("Unversioned routes announce their sunset", func(t *testing.T) {
+		event := createTestEvent(t, user.ID)
+		id := strconv.FormatInt(event.ID, 10)
+
+		w := makeJSONRequest(t, router, http.MethodGet, "/events/"+id, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "@"+strconv.FormatInt(unversionedDeprecation.Unix(), 10), w.Header().Get("Deprecation"))
+		assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+		assert.Equal(t, `</v1/events/`+id+`>; rel="successor-version"`, w.Header().Get("Link"))
+	})
+
+	t.Run("Errors from unversioned routes are marked too", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/events", "", newEvent)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Deprecation"))
+	})
+
+	t.Run("Both paths reach the same handlers", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodPost, "/v1/events", token, newEvent)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var created models.Event
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+		w = makeJSONRequest(t, router, http.MethodGet, "/events/"+strconv.FormatInt(created.ID, 10), "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var retrieved models.Event
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &retrieved))
+		assert.Equal(t, "Versioned Event", retrieved.Name)
+	})
+
+	t.Run("Operations endpoints are not versioned", func(t *testing.T) {
+		w := makeJSONRequest(t, router, http.MethodGet, "/healthz", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Deprecation"))
+
+		w = makeJSONRequest(t, router, http.MethodGet, "/v1/healthz", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Without a sunset date", func(t *testing.T) {
+		router := setupVersionedRouter(func(*Config) {})
+
+		w := makeJSONRequest(t, router, http.MethodGet, "/events", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Deprecation"))
+		assert.Empty(t, w.Header().Get("Sunset"))
+	})
+
+	t.Run("Unversioned routes turned off", func(t *testing.T) {
+		router := setupVersionedRouter(func(config *Config) {
+			config.UnversionedRoutes = false
+		})
+
+		w := makeJSONRequest(t, router, http.MethodGet, "/events", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = makeJSONRequest(t, router, http.MethodGet, "/v1/events", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Rate limits are shared between the paths", func(t *testing.T) {
+		router := setupVersionedRouter(func(config *Config) {
+			config.RateLimits.Public = ratelimit.Policy{Anonymous: ratelimit.Rate{Requests: 1, Period: time.Minute}}
+		})
+
+		w := makeRequestFrom(router, http.MethodGet, "/v1/events", "192.0.2.1:1234", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = makeRequestFrom(router, http.MethodGet, "/events", "192.0.2.1:1234", nil)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+}
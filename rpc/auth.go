@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"REST_API/auth"
+	eventsv1 "REST_API/proto/events/v1"
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicServices and publicMethods can be called without credentials.
+// Everything else needs a user.
+var (
+	publicServices = map[string]bool{
+		eventsv1.AuthService_ServiceDesc.ServiceName: true,
+		healthpb.Health_ServiceDesc.ServiceName:      true,
+		"grpc.reflection.v1.ServerReflection":        true,
+		"grpc.reflection.v1alpha.ServerReflection":   true,
+	}
+	publicMethods = map[string]bool{
+		eventsv1.EventService_ListEvents_FullMethodName:  true,
+		eventsv1.EventService_GetEvent_FullMethodName:    true,
+		eventsv1.EventService_WatchEvents_FullMethodName: true,
+	}
+)
+
+type userIDKey struct{}
+
+// authenticate checks the "authorization: Bearer <token>" metadata with
+// auth.ValidateToken and stores the user ID in the returned context.
+// Public methods may be called without credentials, but credentials that
+// are sent must be valid. On error ctx is returned unchanged.
+func authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		if isPublic(method) {
+			return ctx, nil
+		}
+		return ctx, status.Error(codes.Unauthenticated, "Authentication required")
+	}
+
+	scheme, token, found := strings.Cut(strings.TrimSpace(values[0]), " ")
+	if !found || !strings.EqualFold(scheme, "bearer") {
+		return ctx, status.Error(codes.Unauthenticated, `Authorization must be "Bearer <token>"`)
+	}
+
+	userId, err := auth.ValidateToken(strings.TrimSpace(token))
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, tokenErrorMessage(err))
+	}
+	return context.WithValue(ctx, userIDKey{}, userId), nil
+}
+
+func isPublic(method string) bool {
+	service, _, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	return publicServices[service] || publicMethods[method]
+}
+
+func tokenErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrExpiredToken):
+		return "The token has expired"
+	case errors.Is(err, auth.ErrInvalidClaims):
+		return "The token claims are invalid"
+	default:
+		return "The token is malformed or has an invalid signature"
+	}
+}
+
+// userID returns the authenticated user, or 0 for anonymous callers of
+// public methods.
+func userID(ctx context.Context) int64 {
+	userId, _ := ctx.Value(userIDKey{}).(int64)
+	return userId
+}
@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"REST_API/models"
+	"context"
+	"errors"
+	"log/slog"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusError is the gRPC counterpart of routes.handleErrors. Domain
+// errors keep their message and map to a status code, with the rejected
+// fields of a validation error as a BadRequest detail. fieldPrefix is
+// prepended to those fields, for example "event." when the model was
+// built from the request's event message. Anything else is logged with
+// message and reported as Internal.
+func statusError(ctx context.Context, err error, message, fieldPrefix string) error {
+	var domainErr *models.Error
+	if !errors.As(err, &domainErr) {
+		slog.ErrorContext(ctx, message, slog.Any("error", err))
+		return status.Error(codes.Internal, message)
+	}
+
+	st := status.New(domainCode(domainErr), domainErr.Message)
+	if len(domainErr.Fields) == 0 {
+		return st.Err()
+	}
+
+	details := &errdetails.BadRequest{}
+	for _, field := range domainErr.Fields {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fieldPrefix + field.Field,
+			Description: field.Message,
+		})
+	}
+	withDetails, detailsErr := st.WithDetails(details)
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// invalidArgument is a validation error for a single field that the
+// models never see, such as a missing message.
+func invalidArgument(field, description string) error {
+	return statusError(context.Background(), &models.Error{
+		Kind:    models.ErrValidation,
+		Message: "Invalid input",
+		Fields:  []models.FieldError{{Field: field, Message: description}},
+	}, "", "")
+}
+
+func domainCode(err *models.Error) codes.Code {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, models.ErrForbidden):
+		return codes.PermissionDenied
+	case errors.Is(err, models.ErrConflict):
+		return codes.AlreadyExists
+	case errors.Is(err, models.ErrValidation):
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,278 @@
+package rpc
+
+import (
+	"REST_API/metrics"
+	"REST_API/models"
+	eventsv1 "REST_API/proto/events/v1"
+	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Page sizes of ListEvents, the same as the GraphQL events field.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type eventService struct {
+	eventsv1.UnimplementedEventServiceServer
+	stopping <-chan struct{}
+}
+
+func (s *eventService) ListEvents(ctx context.Context, req *eventsv1.ListEventsRequest) (*eventsv1.ListEventsResponse, error) {
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize < 1 || pageSize > maxPageSize {
+		return nil, invalidArgument("page_size", "must be between 1 and 100")
+	}
+	afterID, ok := parsePageToken(req.GetPageToken())
+	if !ok {
+		return nil, invalidArgument("page_token", "is invalid")
+	}
+
+	// One more than requested tells whether there is a next page.
+	events, err := models.ListEvents(ctx, afterID, pageSize+1)
+	if err != nil {
+		return nil, statusError(ctx, err, "Events could not be retrieved", "")
+	}
+
+	response := &eventsv1.ListEventsResponse{}
+	if len(events) > pageSize {
+		events = events[:pageSize]
+		response.NextPageToken = pageToken(events[len(events)-1].ID)
+	}
+	for i := range events {
+		response.Events = append(response.Events, eventMessage(&events[i]))
+	}
+	return response, nil
+}
+
+func (s *eventService) GetEvent(ctx context.Context, req *eventsv1.GetEventRequest) (*eventsv1.Event, error) {
+	event, err := models.GetEventByID(ctx, req.GetId())
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be retrieved", "")
+	}
+	return eventMessage(event), nil
+}
+
+func (s *eventService) CreateEvent(ctx context.Context, req *eventsv1.CreateEventRequest) (*eventsv1.Event, error) {
+	if req.GetEvent() == nil {
+		return nil, invalidArgument("event", "is required")
+	}
+
+	event := eventFromInput(req.GetEvent())
+	event.UserID = userID(ctx)
+	err := event.Save(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be created", "event.")
+	}
+	return eventMessage(&event), nil
+}
+
+func (s *eventService) UpdateEvent(ctx context.Context, req *eventsv1.UpdateEventRequest) (*eventsv1.Event, error) {
+	event, err := models.GetEventByID(ctx, req.GetId())
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be updated", "")
+	}
+	err = event.CheckOwner(userID(ctx))
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be updated", "")
+	}
+	if req.GetEvent() == nil {
+		return nil, invalidArgument("event", "is required")
+	}
+
+	updatedEvent := eventFromInput(req.GetEvent())
+	updatedEvent.ID = event.ID
+	updatedEvent.UserID = event.UserID
+	updatedEvent.CreatedAt = event.CreatedAt
+	err = updatedEvent.Update(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be updated", "event.")
+	}
+	return eventMessage(&updatedEvent), nil
+}
+
+func (s *eventService) DeleteEvent(ctx context.Context, req *eventsv1.DeleteEventRequest) (*emptypb.Empty, error) {
+	event, err := models.GetEventByID(ctx, req.GetId())
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be deleted", "")
+	}
+	err = event.CheckOwner(userID(ctx))
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be deleted", "")
+	}
+
+	err = event.Delete(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be deleted", "")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *eventService) RegisterForEvent(ctx context.Context, req *eventsv1.RegisterForEventRequest) (*emptypb.Empty, error) {
+	event, err := models.GetEventByID(ctx, req.GetEventId())
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be registered", "")
+	}
+
+	err = event.Register(ctx, userID(ctx))
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be registered", "")
+	}
+	metrics.RecordRegistration(event.ID, true)
+	return &emptypb.Empty{}, nil
+}
+
+func (s *eventService) UnregisterFromEvent(ctx context.Context, req *eventsv1.UnregisterFromEventRequest) (*emptypb.Empty, error) {
+	event, err := models.GetEventByID(ctx, req.GetEventId())
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be unregistered", "")
+	}
+
+	err = event.Unregister(ctx, userID(ctx))
+	if err != nil {
+		return nil, statusError(ctx, err, "Event could not be unregistered", "")
+	}
+	metrics.RecordRegistration(event.ID, false)
+	return &emptypb.Empty{}, nil
+}
+
+// WatchEvents sends the response header once it is subscribed, so a
+// client that waits for the header knows that every change made after
+// that is delivered.
+func (s *eventService) WatchEvents(req *eventsv1.WatchEventsRequest, stream grpc.ServerStreamingServer[eventsv1.EventChange]) error {
+	watched := map[int64]bool{}
+	for _, id := range req.GetEventIds() {
+		watched[id] = true
+	}
+
+	changes := models.SubscribeEventChanges()
+	defer changes.Close()
+
+	err := stream.SendHeader(metadata.MD{})
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case msg, ok := <-changes.C():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "Too many changes to keep up with; reload and watch again")
+			}
+			if len(watched) > 0 && !watched[msg.Value.EventID] {
+				continue
+			}
+			err = stream.Send(changeMessage(msg.Value))
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "Server is shutting down")
+		}
+	}
+}
+
+func eventFromInput(input *eventsv1.EventInput) models.Event {
+	event := models.Event{
+		Name:        input.GetName(),
+		Description: input.GetDescription(),
+		Location:    input.GetLocation(),
+	}
+	// An unset date_time stays zero and fails validation as missing.
+	if input.GetDateTime() != nil {
+		event.DateTime = input.GetDateTime().AsTime()
+	}
+	return event
+}
+
+func eventMessage(event *models.Event) *eventsv1.Event {
+	return &eventsv1.Event{
+		Id:          event.ID,
+		Name:        event.Name,
+		Description: event.Description,
+		Location:    event.Location,
+		DateTime:    timestamp(event.DateTime),
+		UserId:      event.UserID,
+		CreatedAt:   timestamp(event.CreatedAt),
+		UpdatedAt:   timestamp(event.UpdatedAt),
+		CancelledAt: cancelledAt(event),
+	}
+}
+
+func cancelledAt(event *models.Event) *timestamppb.Timestamp {
+	if event.CancelledAt == nil {
+		return nil
+	}
+	return timestamppb.New(*event.CancelledAt)
+}
+
+var changeTypes = map[models.ChangeType]eventsv1.EventChange_Type{
+	models.EventCreated:       eventsv1.EventChange_TYPE_CREATED,
+	models.EventUpdated:       eventsv1.EventChange_TYPE_UPDATED,
+	models.EventDeleted:       eventsv1.EventChange_TYPE_DELETED,
+	models.EventRegistrations: eventsv1.EventChange_TYPE_REGISTRATIONS_CHANGED,
+}
+
+func changeMessage(change models.EventChange) *eventsv1.EventChange {
+	message := &eventsv1.EventChange{
+		Type:    changeTypes[change.Type],
+		EventId: change.EventID,
+		Time:    timestamppb.New(change.At),
+	}
+	if change.Type == models.EventRegistrations {
+		message.AttendeeCount = int32(change.AttendeeCount)
+	}
+	if change.Type != models.EventDeleted {
+		message.Event = eventMessage(&change.Event)
+	}
+	return message
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// Page tokens are opaque to clients; they encode the last event ID of
+// the page.
+const pageTokenPrefix = "event:"
+
+func pageToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.FormatInt(id, 10)))
+}
+
+// parsePageToken returns the event ID to continue after, 0 for an empty
+// token.
+func parsePageToken(token string) (int64, bool) {
+	if token == "" {
+		return 0, true
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, false
+	}
+	id, found := strings.CutPrefix(string(decoded), pageTokenPrefix)
+	if !found {
+		return 0, false
+	}
+	afterID, err := strconv.ParseInt(id, 10, 64)
+	return afterID, err == nil && afterID >= 0
+}
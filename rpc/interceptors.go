@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"REST_API/logging"
+	"context"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDMetadata carries the request ID in both directions, like the
+// X-Request-ID header of the REST API.
+const requestIDMetadata = "x-request-id"
+
+// unaryInterceptor assigns a request ID, authenticates and rate limits the
+// caller, turns a panic into an Internal error and logs one record per
+// call.
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	start := time.Now()
+	ctx = withRequestID(ctx)
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = panicked(ctx, recovered)
+		}
+		logCall(ctx, info.FullMethod, start, err)
+	}()
+
+	ctx, err = authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	err = s.limit(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor is unaryInterceptor for streaming calls.
+func (s *Server) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx := withRequestID(stream.Context())
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = panicked(ctx, recovered)
+		}
+		logCall(ctx, info.FullMethod, start, err)
+	}()
+
+	ctx, err = authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return err
+	}
+	err = s.limit(ctx, info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, contextStream{stream, ctx})
+}
+
+// contextStream replaces the context of a stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// withRequestID reuses a well-formed request ID sent by the caller, or
+// generates one, and sends it back in the response header.
+func withRequestID(ctx context.Context) context.Context {
+	var sent string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadata); len(values) > 0 {
+			sent = values[0]
+		}
+	}
+
+	ctx, id := logging.WithRequestID(ctx, sent)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id))
+	return ctx
+}
+
+func panicked(ctx context.Context, recovered any) error {
+	slog.ErrorContext(ctx, "panic",
+		slog.Any("error", recovered),
+		slog.String("stack", string(debug.Stack())))
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+// logCall logs a call like logging.AccessLog logs a request: errors on
+// the server side at error level, everything else at info.
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if host := clientIP(ctx); host != "" {
+		attrs = append(attrs, slog.String("client_ip", host))
+	}
+	if userId := userID(ctx); userId != 0 {
+		attrs = append(attrs, slog.Int64("user_id", userId))
+	}
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unimplemented:
+		level = slog.LevelError
+	}
+	slog.LogAttrs(ctx, level, "rpc", attrs...)
+}
+
+// clientIP is the address of the caller without the port, or "" if it is
+// unknown.
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	eventsv1 "REST_API/proto/events/v1"
+	"REST_API/ratelimit"
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RateLimits holds the policy of each rate limited group of methods. They
+// are the groups of the REST API, so a store shared with the HTTP server
+// gives a caller one budget across both. The zero policy leaves a group
+// unlimited; health checks and reflection are never limited.
+type RateLimits struct {
+	// Public covers ListEvents, GetEvent and WatchEvents.
+	Public ratelimit.Policy
+	// Auth covers AuthService.
+	Auth ratelimit.Policy
+	// Events covers creating, changing and registering for events.
+	Events ratelimit.Policy
+}
+
+// limit counts the call against the bucket of its group and caller: the
+// user if a token was sent, the client IP otherwise. Callers with an
+// empty bucket get RESOURCE_EXHAUSTED with a RetryInfo detail.
+//
+// If the store fails the call is let through, like in
+// ratelimit.Middleware.
+func (s *Server) limit(ctx context.Context, method string) error {
+	group, policy := s.rateLimitGroup(method)
+	key, rate := "ip:"+clientIP(ctx), policy.Anonymous
+	if userId := userID(ctx); userId != 0 {
+		key, rate = "user:"+strconv.FormatInt(userId, 10), policy.User
+	}
+	if group == "" || rate.Unlimited() {
+		return nil
+	}
+
+	result, err := s.rateLimitStore.Take(ctx, group+":"+key, rate)
+	if err != nil {
+		slog.WarnContext(ctx, "rate limit store failed", slog.String("group", group), slog.Any("error", err))
+		return nil
+	}
+	if result.Allowed {
+		return nil
+	}
+
+	retryAfter := int64(math.Ceil(result.RetryAfter.Seconds()))
+	st := status.New(codes.ResourceExhausted, "Rate limit exceeded, retry in "+strconv.FormatInt(retryAfter, 10)+" seconds")
+	withDetails, detailsErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(result.RetryAfter)})
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+func (s *Server) rateLimitGroup(method string) (string, ratelimit.Policy) {
+	service, _, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	switch {
+	case service == eventsv1.AuthService_ServiceDesc.ServiceName:
+		return "auth", s.rateLimits.Auth
+	case publicMethods[method]:
+		return "public", s.rateLimits.Public
+	case service == eventsv1.EventService_ServiceDesc.ServiceName:
+		return "events", s.rateLimits.Events
+	default:
+		return "", ratelimit.Policy{}
+	}
+}
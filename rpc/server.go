@@ -0,0 +1,107 @@
+// Package rpc serves the event API over gRPC next to the HTTP server. It
+// shares the models, JWTs and validation of the REST API; the service
+// definitions and the generated client are in REST_API/proto/events/v1.
+package rpc
+
+import (
+	eventsv1 "REST_API/proto/events/v1"
+	"REST_API/ratelimit"
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server is a gRPC server with EventService, AuthService, the standard
+// health service and server reflection registered.
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+	// stopping is closed when Shutdown starts, ending open watches that
+	// would otherwise keep the server from stopping gracefully.
+	stopping chan struct{}
+	stopOnce sync.Once
+
+	rateLimits     RateLimits
+	rateLimitStore ratelimit.Store
+}
+
+// Config configures a Server. The zero Config serves plaintext without
+// rate limits.
+type Config struct {
+	// TLS is used for connections; nil serves plaintext.
+	TLS        *tls.Config
+	RateLimits RateLimits
+	// RateLimitStore holds the rate limit buckets. Nil uses an in-process
+	// store.
+	RateLimitStore ratelimit.Store
+}
+
+func New(config Config) *Server {
+	s := &Server{
+		health:         health.NewServer(),
+		stopping:       make(chan struct{}),
+		rateLimits:     config.RateLimits,
+		rateLimitStore: config.RateLimitStore,
+	}
+	if s.rateLimitStore == nil {
+		s.rateLimitStore = ratelimit.NewMemoryStore()
+	}
+
+	options := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	}
+	if config.TLS != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(config.TLS)))
+	}
+	s.grpc = grpc.NewServer(options...)
+
+	eventsv1.RegisterEventServiceServer(s.grpc, &eventService{stopping: s.stopping})
+	eventsv1.RegisterAuthServiceServer(s.grpc, authService{})
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	reflection.Register(s.grpc)
+	return s
+}
+
+// Serve accepts connections on lis until Shutdown is called, after which
+// it returns nil.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Drain reports NOT_SERVING to health checks, while calls are still
+// served.
+func (s *Server) Drain() {
+	s.health.Shutdown()
+}
+
+// Shutdown reports NOT_SERVING to health checks, ends open watches and
+// waits for running calls to finish. When ctx is done first the remaining
+// connections are closed and ctx's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+	s.stopOnce.Do(func() { close(s.stopping) })
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
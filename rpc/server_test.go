@@ -0,0 +1,268 @@
+package rpc
+
+import (
+	eventsv1 "REST_API/proto/events/v1"
+	"REST_API/ratelimit"
+	"REST_API/routes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// startServer serves a test database over an in-memory connection and
+// returns a client connection to it
+func startServer(t *testing.T) (*Server, *grpc.ClientConn) {
+	return startServerWithConfig(t, Config{})
+}
+
+func startServerWithConfig(t *testing.T, config Config) (*Server, *grpc.ClientConn) {
+	testDB := routes.SetupTestDB(t)
+	t.Cleanup(testDB.Cleanup)
+
+	lis := bufconn.Listen(1 << 20)
+	server := New(config)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(func() { _ = server.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return server, conn
+}
+
+// withToken sends token as the caller's credentials
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// fieldViolations returns the rejected fields of a BadRequest detail
+func fieldViolations(err error) map[string]string {
+	fields := map[string]string{}
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.GetFieldViolations() {
+				fields[violation.GetField()] = violation.GetDescription()
+			}
+		}
+	}
+	return fields
+}
+
+func eventInput(name string) *eventsv1.EventInput {
+	return &eventsv1.EventInput{
+		Name:        name,
+		Description: "Talks about protobuf",
+		Location:    "Room 2",
+		DateTime:    timestamppb.New(time.Now().Add(48 * time.Hour)),
+	}
+}
+
+func TestAuthService(t *testing.T) {
+	_, conn := startServer(t)
+	client := eventsv1.NewAuthServiceClient(conn)
+	users := routes.GetTestUsers()
+
+	t.Run("Signup", func(t *testing.T) {
+		_, err := client.Signup(t.Context(), &eventsv1.SignupRequest{Email: "grpc@example.com", Password: "secret123"})
+		assert.NoError(t, err)
+
+		_, err = client.Signup(t.Context(), &eventsv1.SignupRequest{Email: "grpc@example.com", Password: "secret123"})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+		_, err = client.Signup(t.Context(), &eventsv1.SignupRequest{Email: "not an email", Password: "secret123"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, map[string]string{"email": "must be a valid email address"}, fieldViolations(err))
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		response, err := client.Login(t.Context(), &eventsv1.LoginRequest{Email: users["testuser"].Email, Password: users["testuser"].Password})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, response.GetToken())
+
+		_, err = client.Login(t.Context(), &eventsv1.LoginRequest{Email: users["testuser"].Email, Password: "wrong"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	_, conn := startServerWithConfig(t, Config{RateLimits: RateLimits{
+		Auth: ratelimit.Policy{Anonymous: ratelimit.Rate{Requests: 2, Period: time.Minute}},
+	}})
+	client := eventsv1.NewAuthServiceClient(conn)
+	login := &eventsv1.LoginRequest{Email: "attacker@example.com", Password: "guess"}
+
+	for range 2 {
+		_, err := client.Login(t.Context(), login)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+
+	_, err := client.Login(t.Context(), login)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range status.Convert(err).Details() {
+		retryInfo, _ = detail.(*errdetails.RetryInfo)
+	}
+	if assert.NotNil(t, retryInfo) {
+		assert.Positive(t, retryInfo.GetRetryDelay().AsDuration())
+	}
+
+	_, err = eventsv1.NewEventServiceClient(conn).ListEvents(t.Context(), &eventsv1.ListEventsRequest{})
+	assert.NoError(t, err, "other groups have their own limits")
+}
+
+func TestEventService(t *testing.T) {
+	_, conn := startServer(t)
+	client := eventsv1.NewEventServiceClient(conn)
+	users := routes.GetTestUsers()
+	owner := withToken(t.Context(), routes.GenerateTestJWT(t, users["testuser"].ID, users["testuser"].Email))
+	other := withToken(t.Context(), routes.GenerateTestJWT(t, users["user1"].ID, users["user1"].Email))
+
+	t.Run("Writes need a token", func(t *testing.T) {
+		_, err := client.CreateEvent(t.Context(), &eventsv1.CreateEventRequest{Event: eventInput("Anonymous")})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = client.ListEvents(withToken(t.Context(), "invalid"), &eventsv1.ListEventsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "invalid credentials are rejected on public methods")
+	})
+
+	t.Run("Create validates the event", func(t *testing.T) {
+		input := eventInput(" ")
+		input.DateTime = timestamppb.New(time.Now().Add(-time.Hour))
+		_, err := client.CreateEvent(owner, &eventsv1.CreateEventRequest{Event: input})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, map[string]string{"event.name": "is required", "event.date_time": "must be in the future"}, fieldViolations(err))
+
+		_, err = client.CreateEvent(owner, &eventsv1.CreateEventRequest{})
+		assert.Equal(t, map[string]string{"event": "is required"}, fieldViolations(err))
+	})
+
+	var ids []int64
+	for _, name := range []string{"gRPC Meetup", "Protobuf Workshop", "Streaming Talk"} {
+		event, err := client.CreateEvent(owner, &eventsv1.CreateEventRequest{Event: eventInput(name)})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, users["testuser"].ID, event.GetUserId())
+		ids = append(ids, event.GetId())
+	}
+
+	t.Run("Get and list", func(t *testing.T) {
+		event, err := client.GetEvent(t.Context(), &eventsv1.GetEventRequest{Id: ids[0]})
+		assert.NoError(t, err)
+		assert.Equal(t, "gRPC Meetup", event.GetName())
+
+		page, err := client.ListEvents(t.Context(), &eventsv1.ListEventsRequest{PageSize: 2})
+		assert.NoError(t, err)
+		assert.Len(t, page.GetEvents(), 2)
+		assert.NotEmpty(t, page.GetNextPageToken())
+
+		page, err = client.ListEvents(t.Context(), &eventsv1.ListEventsRequest{PageSize: 2, PageToken: page.GetNextPageToken()})
+		assert.NoError(t, err)
+		assert.Equal(t, ids[2], page.GetEvents()[0].GetId())
+		assert.Empty(t, page.GetNextPageToken())
+
+		_, err = client.ListEvents(t.Context(), &eventsv1.ListEventsRequest{PageToken: "bogus"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = client.ListEvents(t.Context(), &eventsv1.ListEventsRequest{PageSize: 500})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Only the owner can update and delete", func(t *testing.T) {
+		_, err := client.UpdateEvent(other, &eventsv1.UpdateEventRequest{Id: ids[0], Event: eventInput("Renamed")})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		_, err = client.DeleteEvent(other, &eventsv1.DeleteEventRequest{Id: ids[0]})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		event, err := client.UpdateEvent(owner, &eventsv1.UpdateEventRequest{Id: ids[0], Event: eventInput("Renamed")})
+		assert.NoError(t, err)
+		assert.Equal(t, "Renamed", event.GetName())
+		assert.NotNil(t, event.GetCreatedAt())
+
+		_, err = client.DeleteEvent(owner, &eventsv1.DeleteEventRequest{Id: ids[2]})
+		assert.NoError(t, err)
+		_, err = client.GetEvent(t.Context(), &eventsv1.GetEventRequest{Id: ids[2]})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Registrations", func(t *testing.T) {
+		_, err := client.RegisterForEvent(other, &eventsv1.RegisterForEventRequest{EventId: ids[1]})
+		assert.NoError(t, err)
+		_, err = client.RegisterForEvent(other, &eventsv1.RegisterForEventRequest{EventId: ids[1]})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+		_, err = client.UnregisterFromEvent(other, &eventsv1.UnregisterFromEventRequest{EventId: ids[1]})
+		assert.NoError(t, err)
+		_, err = client.RegisterForEvent(other, &eventsv1.RegisterForEventRequest{EventId: 999})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestWatchEvents(t *testing.T) {
+	server, conn := startServer(t)
+	client := eventsv1.NewEventServiceClient(conn)
+	users := routes.GetTestUsers()
+	owner := withToken(t.Context(), routes.GenerateTestJWT(t, users["testuser"].ID, users["testuser"].Email))
+
+	watched, err := client.CreateEvent(owner, &eventsv1.CreateEventRequest{Event: eventInput("Watched")})
+	assert.NoError(t, err)
+	ignored, err := client.CreateEvent(owner, &eventsv1.CreateEventRequest{Event: eventInput("Ignored")})
+	assert.NoError(t, err)
+
+	stream, err := client.WatchEvents(t.Context(), &eventsv1.WatchEventsRequest{EventIds: []int64{watched.GetId()}})
+	assert.NoError(t, err)
+	// The header is sent once the server is subscribed.
+	header, err := stream.Header()
+	assert.NoError(t, err)
+	assert.Len(t, header.Get("x-request-id"), 1)
+
+	_, err = client.UpdateEvent(owner, &eventsv1.UpdateEventRequest{Id: ignored.GetId(), Event: eventInput("Still ignored")})
+	assert.NoError(t, err)
+	_, err = client.UpdateEvent(owner, &eventsv1.UpdateEventRequest{Id: watched.GetId(), Event: eventInput("Renamed")})
+	assert.NoError(t, err)
+	_, err = client.RegisterForEvent(owner, &eventsv1.RegisterForEventRequest{EventId: watched.GetId()})
+	assert.NoError(t, err)
+	_, err = client.DeleteEvent(owner, &eventsv1.DeleteEventRequest{Id: watched.GetId()})
+	assert.NoError(t, err)
+
+	change, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, eventsv1.EventChange_TYPE_UPDATED, change.GetType())
+	assert.Equal(t, "Renamed", change.GetEvent().GetName())
+
+	change, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, eventsv1.EventChange_TYPE_REGISTRATIONS_CHANGED, change.GetType())
+	assert.Equal(t, int32(1), change.GetAttendeeCount())
+
+	change, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, eventsv1.EventChange_TYPE_DELETED, change.GetType())
+	assert.Equal(t, watched.GetId(), change.GetEventId())
+	assert.Nil(t, change.GetEvent())
+
+	t.Run("Shutdown ends the stream", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, server.Shutdown(ctx))
+
+		_, err := stream.Recv()
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"REST_API/auth"
+	"REST_API/metrics"
+	"REST_API/models"
+	eventsv1 "REST_API/proto/events/v1"
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type authService struct {
+	eventsv1.UnimplementedAuthServiceServer
+}
+
+func (authService) Signup(ctx context.Context, req *eventsv1.SignupRequest) (*eventsv1.SignupResponse, error) {
+	if req.GetPassword() == "" {
+		return nil, invalidArgument("password", "is required")
+	}
+
+	user := models.User{Email: req.GetEmail(), Password: req.GetPassword()}
+	err := user.Save(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err, "User could not be saved", "")
+	}
+	return &eventsv1.SignupResponse{}, nil
+}
+
+func (authService) Login(ctx context.Context, req *eventsv1.LoginRequest) (*eventsv1.LoginResponse, error) {
+	user := models.User{Email: req.GetEmail(), Password: req.GetPassword()}
+
+	err := user.ValidateCredentials(ctx)
+	metrics.RecordLogin(metrics.LoginPassword, err == nil)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Invalid credentials")
+	}
+
+	token, err := auth.GenerateToken(user.Email, user.ID)
+	if err != nil {
+		return nil, statusError(ctx, err, "Could not generate token", "")
+	}
+	return &eventsv1.LoginResponse{Token: token}, nil
+}
@@ -0,0 +1,115 @@
+// Package security provides CORS handling and protective response headers.
+package security
+
+import (
+	"REST_API/problem"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig controls which browser origins may call the API. With no
+// allowed origins CORS is disabled and browsers apply the same-origin
+// policy.
+type CORSConfig struct {
+	// AllowedOrigins lists origins such as "https://app.example.com".
+	// "*" allows any origin and "https://*.example.com" any subdomain.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// exposedHeaders are response headers scripts on other origins may read.
+var exposedHeaders = []string{
+	"X-Request-ID",
+	"WWW-Authenticate",
+	"Retry-After",
+	"RateLimit-Policy",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"Idempotent-Replayed",
+	"Deprecation",
+	"Sunset",
+	"Link",
+}
+
+// CORS answers preflight requests and adds the Access-Control-* headers to
+// requests from allowed origins. Requests from other origins are passed on
+// without those headers, so the browser blocks the response; their
+// preflights are rejected with 403.
+func CORS(config CORSConfig) gin.HandlerFunc {
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	exposed := strings.Join(exposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || len(config.AllowedOrigins) == 0 {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !originAllowed(config.AllowedOrigins, origin) {
+			if preflight {
+				problem.Abort(c, http.StatusForbidden, "Origin not allowed")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if slices.Contains(config.AllowedOrigins, "*") && !config.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			header.Set("Access-Control-Expose-Headers", exposed)
+			c.Next()
+			return
+		}
+
+		header.Set("Access-Control-Allow-Methods", methods)
+		header.Set("Access-Control-Allow-Headers", headers)
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func originAllowed(allowed []string, origin string) bool {
+	for _, pattern := range allowed {
+		if pattern == "*" || strings.EqualFold(pattern, origin) {
+			return true
+		}
+
+		prefix, suffix, wildcard := strings.Cut(pattern, "*.")
+		if wildcard && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, "."+suffix) &&
+			len(origin) > len(prefix)+len(suffix)+1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCert creates a certificate for commonName signed by parent, or
+// self-signed when parent is nil
+func newCert(t *testing.T, commonName string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, any(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writeCert writes cert and its key as PEM files in dir
+func writeCert(t *testing.T, dir string, cert tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600)
+	require.NoError(t, err)
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+	require.NoError(t, err)
+	return certFile, keyFile
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	first := newCert(t, "first", nil)
+	certFile, keyFile := writeCert(t, dir, first)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	now := time.Now()
+	reloader.now = func() time.Time { return now }
+
+	serving := func() string {
+		cert, err := reloader.GetCertificate(nil)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+	assert.Equal(t, "first", serving())
+
+	// Renew the certificate with a later modification time
+	writeCert(t, dir, newCert(t, "second", nil))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+
+	t.Run("Files are not checked on every handshake", func(t *testing.T) {
+		assert.Equal(t, "first", serving())
+	})
+
+	t.Run("Renewed certificate is picked up", func(t *testing.T) {
+		now = now.Add(checkInterval)
+		assert.Equal(t, "second", serving())
+	})
+
+	t.Run("Broken renewal keeps the current certificate", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+		evenLater := later.Add(time.Minute)
+		require.NoError(t, os.Chtimes(keyFile, evenLater, evenLater))
+
+		now = now.Add(checkInterval)
+		assert.Equal(t, "second", serving())
+	})
+
+	t.Run("Missing files fail at startup", func(t *testing.T) {
+		_, err := NewCertReloader(filepath.Join(dir, "missing.pem"), keyFile)
+		assert.Error(t, err)
+	})
+}
+
+func TestNew_ClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCert(t, "Test CA", nil)
+	server := newCert(t, "localhost", &ca)
+	certFile, keyFile := writeCert(t, dir, server)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate[0]}), 0o600)
+	require.NoError(t, err)
+
+	tlsConfig, err := New(Config{CertFile: certFile, KeyFile: keyFile, MinVersion: tls.VersionTLS12, ClientCAFile: caFile})
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) == 0 {
+			_, _ = w.Write([]byte("anonymous"))
+			return
+		}
+		_, _ = w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+	}))
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	get := func(t *testing.T, certs ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:    roots,
+			ServerName: "localhost",
+			// Present the certificate even when the server does not list
+			// its CA as acceptable
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if len(certs) == 0 {
+					return &tls.Certificate{}, nil
+				}
+				return &certs[0], nil
+			},
+		}}}
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		return string(body[:n]), nil
+	}
+
+	t.Run("Certificate signed by the client CA", func(t *testing.T) {
+		body, err := get(t, newCert(t, "billing-service", &ca))
+		require.NoError(t, err)
+		assert.Equal(t, "billing-service", body)
+	})
+
+	t.Run("No certificate", func(t *testing.T) {
+		body, err := get(t)
+		require.NoError(t, err)
+		assert.Equal(t, "anonymous", body)
+	})
+
+	t.Run("Certificate from another CA is refused", func(t *testing.T) {
+		_, err := get(t, newCert(t, "intruder", nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("Old protocol versions are refused", func(t *testing.T) {
+		tlsConfig, err := New(Config{CertFile: certFile, KeyFile: keyFile, MinVersion: tls.VersionTLS13})
+		require.NoError(t, err)
+		ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+		ts.TLS = tlsConfig
+		ts.StartTLS()
+		defer ts.Close()
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:    roots,
+			ServerName: "localhost",
+			MaxVersion: tls.VersionTLS12,
+		}}}
+		_, err = client.Get(ts.URL)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("1.3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), v)
+
+	_, err = ParseVersion("1.1")
+	assert.Error(t, err)
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		httpsAddr string
+		url       string
+		location  string
+	}{
+		{":443", "http://api.example.com/events?page=2", "https://api.example.com/events?page=2"},
+		{":8443", "http://api.example.com:8080/events/1", "https://api.example.com:8443/events/1"},
+		{"0.0.0.0:443", "http://api.example.com:80/", "https://api.example.com/"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		RedirectHandler(tt.httpsAddr).ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.url, nil))
+
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code, tt.url)
+		assert.Equal(t, tt.location, w.Header().Get("Location"), tt.url)
+	}
+}
@@ -0,0 +1,109 @@
+// Package tracing sets up OpenTelemetry tracing. Incoming requests get a
+// server span that continues any W3C traceparent sent by the caller, and
+// spans started from the request context become its children.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+)
+
+const ServiceName = "rest-api"
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type Config struct {
+	// Exporter is one of ExporterNone, ExporterStdout or ExporterOTLP.
+	Exporter string
+	// OTLPEndpoint is the collector URL, for example
+	// http://localhost:4318. When empty the standard OTEL_EXPORTER_OTLP_*
+	// environment variables apply.
+	OTLPEndpoint string
+	// SampleRatio is the fraction of new traces that are recorded. Traces
+	// started by a caller follow the caller's sampling decision.
+	SampleRatio float64
+}
+
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+// Setup installs the global tracer provider. The returned function flushes
+// buffered spans and must be called on shutdown.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch config.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if config.OTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(config.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+var (
+	inMemoryOnce     sync.Once
+	inMemoryExporter *tracetest.InMemoryExporter
+)
+
+// InMemory installs a global tracer provider that records every span in
+// memory and returns its exporter. It is meant for tests; the provider is
+// installed once per process, so tests should Reset the exporter.
+func InMemory() *tracetest.InMemoryExporter {
+	inMemoryOnce.Do(func() {
+		inMemoryExporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(
+			sdktrace.WithSyncer(inMemoryExporter),
+			sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+		))
+	})
+	return inMemoryExporter
+}
+
+// Middleware starts a server span for every request, named after the
+// route template.
+func Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(ServiceName)
+}